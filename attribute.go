@@ -0,0 +1,150 @@
+package goller
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// Message attribute data types.
+const (
+	AttributeTypeString = "String"
+	AttributeTypeNumber = "Number"
+	AttributeTypeBinary = "Binary"
+)
+
+// ErrAttributeNotFound means the message wasn't sent with the attribute.
+var ErrAttributeNotFound = errors.New("attribute not found")
+
+// AttributeValue is a message attribute along with its data type.
+type AttributeValue struct {
+	// String, Number or Binary, optionally followed by a custom suffix; i.e. Number.int
+	DataType string
+
+	// Set for String & Number attributes.
+	StringValue string
+
+	// Set for Binary attributes.
+	BinaryValue []byte
+}
+
+// Type is the data type without any custom suffix.
+func (v AttributeValue) Type() string {
+	return strings.SplitN(v.DataType, ".", 2)[0]
+}
+
+// CustomType is the suffix after the data type, i.e. `int` for Number.int
+func (v AttributeValue) CustomType() string {
+	parts := strings.SplitN(v.DataType, ".", 2)
+	if len(parts) < 2 {
+		return ""
+	}
+
+	return parts[1]
+}
+
+// Attributes returns the custom attributes set on the message by the sender.
+func (j *sqsJob) Attributes() map[string]AttributeValue {
+	attrs := make(map[string]AttributeValue, len(j.msg.MessageAttributes))
+	for name, attr := range j.msg.MessageAttributes {
+		attrs[name] = AttributeValue{
+			DataType:    aws.StringValue(attr.DataType),
+			StringValue: aws.StringValue(attr.StringValue),
+			BinaryValue: attr.BinaryValue,
+		}
+	}
+
+	return attrs
+}
+
+// typedAttribute looks for the custom attribute, falling back to SQS defined attributes like Attribute(...).
+func (j *sqsJob) typedAttribute(attr string) (AttributeValue, error) {
+	if a, ok := j.msg.MessageAttributes[attr]; ok {
+		return AttributeValue{
+			DataType:    aws.StringValue(a.DataType),
+			StringValue: aws.StringValue(a.StringValue),
+			BinaryValue: a.BinaryValue,
+		}, nil
+	}
+
+	if a, ok := j.msg.Attributes[attr]; ok {
+		return AttributeValue{DataType: AttributeTypeString, StringValue: a}, nil
+	}
+
+	return AttributeValue{}, ErrAttributeNotFound
+}
+
+// textAttribute errors for Binary attributes.
+func (j *sqsJob) textAttribute(attr string) (AttributeValue, error) {
+	v, err := j.typedAttribute(attr)
+	if err != nil {
+		return v, err
+	}
+
+	if v.Type() == AttributeTypeBinary {
+		return v, fmt.Errorf("attribute %s is %s", attr, v.DataType)
+	}
+
+	return v, nil
+}
+
+// AttributeInt parses a Number, or String, attribute as a whole number.
+func (j *sqsJob) AttributeInt(attr string) (int64, error) {
+	v, err := j.textAttribute(attr)
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.ParseInt(v.StringValue, 10, 64)
+}
+
+// AttributeFloat parses a Number, or String, attribute.
+func (j *sqsJob) AttributeFloat(attr string) (float64, error) {
+	v, err := j.textAttribute(attr)
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.ParseFloat(v.StringValue, 64)
+}
+
+// AttributeBytes returns the value of a Binary attribute,
+// or the raw value of a String or Number attribute.
+func (j *sqsJob) AttributeBytes(attr string) ([]byte, error) {
+	v, err := j.typedAttribute(attr)
+	if err != nil {
+		return nil, err
+	}
+
+	if v.Type() == AttributeTypeBinary {
+		return v.BinaryValue, nil
+	}
+
+	return []byte(v.StringValue), nil
+}
+
+// AttributeTime parses a Number attribute as seconds since the epoch,
+// or a String attribute as RFC 3339.
+func (j *sqsJob) AttributeTime(attr string) (time.Time, error) {
+	v, err := j.textAttribute(attr)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if v.Type() == AttributeTypeNumber {
+		secs, err := strconv.ParseFloat(v.StringValue, 64)
+		if err != nil {
+			return time.Time{}, err
+		}
+
+		whole, frac := math.Modf(secs)
+		return time.Unix(int64(whole), int64(frac*float64(time.Second))), nil
+	}
+
+	return time.Parse(time.RFC3339Nano, v.StringValue)
+}
@@ -0,0 +1,103 @@
+package goller_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/rcrowe/goller"
+)
+
+func attributeJob() goller.Job {
+	msg := sqs.Message{
+		MessageId: aws.String("123"),
+		Attributes: map[string]string{
+			"ApproximateReceiveCount": "3",
+		},
+		MessageAttributes: map[string]sqs.MessageAttributeValue{
+			"name":    {DataType: aws.String("String"), StringValue: aws.String("rob")},
+			"count":   {DataType: aws.String("Number.int"), StringValue: aws.String("42")},
+			"ratio":   {DataType: aws.String("Number"), StringValue: aws.String("0.25")},
+			"payload": {DataType: aws.String("Binary"), BinaryValue: []byte{0xde, 0xad}},
+			"at":      {DataType: aws.String("String"), StringValue: aws.String("2020-01-02T03:04:05Z")},
+			"epoch":   {DataType: aws.String("Number"), StringValue: aws.String("1577934245.5")},
+		},
+	}
+
+	return goller.NewJob(goller.NewDefaultConfig("", 1), goller.NewNopLogger(), msg, &mockSQSClient{})
+}
+
+func TestAttributes(t *testing.T) {
+	attrs := attributeJob().Attributes()
+
+	if len(attrs) != 6 {
+		t.Fatalf("expected `6` attributes but got `%d`", len(attrs))
+	}
+	if count := attrs["count"]; count.Type() != "Number" || count.CustomType() != "int" || count.StringValue != "42" {
+		t.Errorf("expected Number.int attribute but got `%+v`", count)
+	}
+	if name := attrs["name"]; name.Type() != "String" || name.CustomType() != "" {
+		t.Errorf("expected String attribute but got `%+v`", name)
+	}
+	if payload := attrs["payload"]; !bytes.Equal(payload.BinaryValue, []byte{0xde, 0xad}) {
+		t.Errorf("expected binary value but got `%v`", payload.BinaryValue)
+	}
+}
+
+func TestTypedAttributes(t *testing.T) {
+	j := attributeJob()
+
+	if n, err := j.AttributeInt("count"); err != nil || n != 42 {
+		t.Errorf("expected `42` but got `%d` (%v)", n, err)
+	}
+	if n, err := j.AttributeInt("ApproximateReceiveCount"); err != nil || n != 3 {
+		t.Errorf("expected system attribute `3` but got `%d` (%v)", n, err)
+	}
+	if f, err := j.AttributeFloat("ratio"); err != nil || f != 0.25 {
+		t.Errorf("expected `0.25` but got `%f` (%v)", f, err)
+	}
+	if b, err := j.AttributeBytes("payload"); err != nil || !bytes.Equal(b, []byte{0xde, 0xad}) {
+		t.Errorf("expected binary value but got `%v` (%v)", b, err)
+	}
+	if b, err := j.AttributeBytes("name"); err != nil || string(b) != "rob" {
+		t.Errorf("expected raw string value but got `%v` (%v)", b, err)
+	}
+
+	expected := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	if at, err := j.AttributeTime("at"); err != nil || !at.Equal(expected) {
+		t.Errorf("expected `%s` but got `%s` (%v)", expected, at, err)
+	}
+	if at, err := j.AttributeTime("epoch"); err != nil || !at.Equal(expected.Add(500*time.Millisecond)) {
+		t.Errorf("expected `%s` but got `%s` (%v)", expected.Add(500*time.Millisecond), at, err)
+	}
+}
+
+func TestTypedAttributesMismatch(t *testing.T) {
+	j := attributeJob()
+
+	// Binary used to panic
+	if v, ok := j.Attribute("payload"); !ok || v != "\xde\xad" {
+		t.Errorf("expected raw bytes but got `%q`", v)
+	}
+
+	if _, err := j.AttributeInt("payload"); err == nil {
+		t.Error("expected error reading binary as int")
+	}
+	if _, err := j.AttributeInt("ratio"); err == nil {
+		t.Error("expected error reading fraction as int")
+	}
+	if _, err := j.AttributeFloat("name"); err == nil {
+		t.Error("expected error reading string as float")
+	}
+	if _, err := j.AttributeTime("payload"); err == nil {
+		t.Error("expected error reading binary as time")
+	}
+	if _, err := j.AttributeTime("name"); err == nil {
+		t.Error("expected error reading string as time")
+	}
+	if _, err := j.AttributeBytes("missing"); err != goller.ErrAttributeNotFound {
+		t.Errorf("expected ErrAttributeNotFound but got `%v`", err)
+	}
+}
@@ -0,0 +1,324 @@
+package goller
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"go.opentelemetry.io/otel/codes"
+)
+
+// BatchHandlerFunc receives every job from a receive, or a gathered batch, at once.
+type BatchHandlerFunc func(ctx context.Context, jobs []Job) BatchResult
+
+// BatchResult reports which jobs in the batch failed.
+// Failed jobs are backed off, the rest are deleted together using the batch API.
+// Jobs the handler has already deleted or released are left alone.
+type BatchResult struct {
+	// Fails every job in the batch.
+	Err error
+
+	// Failed jobs, keyed by job ID.
+	Failed map[string]error
+}
+
+// BatchError fails every job in the batch.
+func BatchError(err error) BatchResult {
+	return BatchResult{Err: err}
+}
+
+// Fail marks a single job in the batch as failed.
+func (r *BatchResult) Fail(j Job, err error) {
+	if r.Failed == nil {
+		r.Failed = make(map[string]error)
+	}
+
+	r.Failed[j.ID()] = err
+}
+
+// err returns why the job failed, nil if it succeeded.
+func (r BatchResult) err(j Job) error {
+	if r.Err != nil {
+		return r.Err
+	}
+
+	return r.Failed[j.ID()]
+}
+
+// SQS limit on the number of entries in a batch request, and messages in a receive.
+const sqsBatchLimit = 10
+
+// ListenBatch to new SQS jobs, passing them to the handler as a group.
+// Context allows you to gracefully shutdown the listener.
+//
+// ConsumerConfig.BatchSize & BatchWindow gather messages over several receives.
+// The rate & concurrency limiters only apply to Listen(...).
+func (w *sqsWorker) ListenBatch(ctx context.Context, handler BatchHandlerFunc) {
+	w.listen(ctx, func(ctx context.Context, consumer int, msgs []sqs.Message) {
+		w.handleBatch(ctx, consumer, msgs, handler)
+	})
+}
+
+// gather keeps receiving until the batch is full or the window has passed.
+func (w *sqsWorker) gather(ctx context.Context, id int, msgs []sqs.Message) []sqs.Message {
+	size := w.cfg.Consumer.BatchSize
+	if size < 1 {
+		size = int(w.cfg.Consumer.RetrievalMaxNumberOfMessages)
+	}
+
+	deadline := time.Now().Add(w.cfg.Consumer.BatchWindow)
+
+	for len(msgs) < size && ctx.Err() == nil {
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			break
+		}
+
+		// Long poll for no longer than what's left of the window
+		wait := int64(remaining / time.Second)
+		if wait > w.cfg.Consumer.RetrievalWaitTimeSeconds {
+			wait = w.cfg.Consumer.RetrievalWaitTimeSeconds
+		}
+
+		maxMessages := int64(size - len(msgs))
+		if maxMessages > sqsBatchLimit {
+			maxMessages = sqsBatchLimit
+		}
+
+		more, err := w.receiveMessages(id, maxMessages, wait)
+		if err != nil {
+			break
+		}
+
+		msgs = append(msgs, more...)
+
+		// Short polling an empty queue, don't spin until the deadline
+		if len(more) == 0 && wait == 0 {
+			break
+		}
+	}
+
+	w.log.WithField("count", len(msgs)).Debug("batch gathered")
+
+	return msgs
+}
+
+func (w *sqsWorker) handleBatch(ctx context.Context, consumer int, msgs []sqs.Message, handler BatchHandlerFunc) {
+	jobs := make([]Job, 0, len(msgs))
+	live := make([]sqs.Message, 0, len(msgs))
+	for _, msg := range msgs {
+		j := w.newJob(msg)
+		if logger := w.jobLogger(j); w.expire(j, logger) || w.notDue(j, msg, logger) {
+			continue
+		}
+
+		jobs = append(jobs, j)
+		live = append(live, msg)
+
+		inFlight := w.state.startJob(consumer, j, aws.StringValue(msg.ReceiptHandle))
+		defer w.state.finishJob(inFlight)
+	}
+	if w.dryRun() {
+		defer w.finishDryRun(msgs...)
+	}
+	if len(jobs) == 0 {
+		return
+	}
+	msgs = live
+
+	ctx, span := startBatchSpan(ctx, w.tracer, w.cfg.QueueURL, msgs)
+	defer span.End()
+
+	logger := w.log.WithFields(Fields{
+		"queue": queueName(w.cfg.QueueURL),
+		"count": len(jobs),
+	})
+	ctx = ContextWithLogger(ctx, logger)
+
+	logger.Debug("processing batch")
+	batchSize.Set(float64(len(jobs)))
+
+	// Circuit breaker decides whether the handler gets called
+	breaker := w.cfg.Consumer.CircuitBreaker
+	probe := false
+	if breaker != nil {
+		var ok bool
+		if ok, probe = breaker.allow(); !ok {
+			for i, j := range jobs {
+				w.requeue(j, msgs[i], breaker.retryIn(), w.jobLogger(j))
+			}
+			return
+		}
+	}
+
+	processed, errored := w.outcomeCounters()
+
+	start := time.Now()
+	result := w.callBatchHandler(ctx, handler, jobs, logger)
+	jobHandlerTimer.Set(time.Since(start).Seconds())
+
+	if result.Err != nil {
+		logger.WithError(result.Err).Error("batch handler errored")
+		span.RecordError(result.Err)
+		span.SetStatus(codes.Error, result.Err.Error())
+	}
+
+	var succeeded []Job
+	var probeErr error
+	for _, j := range jobs {
+		err := result.err(j)
+
+		// The whole batch is the probe, so it only has the one outcome
+		if probe {
+			if probeErr == nil {
+				probeErr = err
+			}
+		} else if breaker != nil {
+			breaker.record(false, err)
+		}
+
+		// Handler took care of the job itself
+		if j.Handled() {
+			processed.Inc()
+			continue
+		}
+
+		if err == nil {
+			succeeded = append(succeeded, j)
+			continue
+		}
+
+		jobLogger := w.jobLogger(j).WithError(err)
+		jobLogger.Error("job failed")
+		errored.Inc()
+
+		if err := j.Backoff(); err != nil {
+			jobLogger.WithError(err).Error("unable to backoff failed job")
+		}
+	}
+
+	if probe {
+		breaker.record(true, probeErr)
+	}
+
+	w.deleteBatch(succeeded, logger)
+}
+
+// callBatchHandler treats a panic as every job in the batch failing.
+func (w *sqsWorker) callBatchHandler(ctx context.Context, handler BatchHandlerFunc, jobs []Job, logger Logger) (result BatchResult) {
+	defer func() {
+		if r := recover(); r != nil {
+			err := fmt.Errorf("panic: %s", r)
+			logger.WithError(err).Error("batch handler paniced")
+			jobPanicTotal.Inc()
+
+			result = BatchError(err)
+		}
+	}()
+
+	return handler(ctx, jobs)
+}
+
+// deleteBatch deletes the jobs from SQS in as few requests as possible.
+func (w *sqsWorker) deleteBatch(jobs []Job, logger Logger) {
+	// Dry-run jobs record the delete instead
+	if w.dryRun() {
+		for _, j := range jobs {
+			if err := j.Delete(); err != nil {
+				w.jobLogger(j).WithError(err).Error("unable to delete job")
+				dryRunErrorTotal.Inc()
+				continue
+			}
+			dryRunProcessedTotal.Inc()
+		}
+		return
+	}
+
+	var batch []*sqsJob
+	for _, j := range jobs {
+		sj, ok := j.(*sqsJob)
+		if ok {
+			batch = append(batch, sj)
+			continue
+		}
+
+		// Wrapped jobs don't expose a receipt handle, so delete them one at a time
+		if err := j.Delete(); err != nil {
+			w.jobLogger(j).WithError(err).Error("unable to delete job")
+			jobErrorTotal.Inc()
+			continue
+		}
+		jobProcessedTotal.Inc()
+	}
+
+	for len(batch) > 0 {
+		n := len(batch)
+		if n > sqsBatchLimit {
+			n = sqsBatchLimit
+		}
+
+		w.deleteMessageBatch(batch[:n], logger)
+		batch = batch[n:]
+	}
+}
+
+func (w *sqsWorker) deleteMessageBatch(batch []*sqsJob, logger Logger) {
+	// Hold the jobs while deleting, so Shutdown can't abandon them mid-write.
+	// Jobs it already abandoned have been released, so are left alone.
+	jobs := make([]*sqsJob, 0, len(batch))
+	for _, j := range batch {
+		j.mu.Lock()
+		defer j.mu.Unlock()
+
+		if j.handled {
+			continue
+		}
+		jobs = append(jobs, j)
+	}
+	if len(jobs) == 0 {
+		return
+	}
+
+	entries := make([]sqs.DeleteMessageBatchRequestEntry, len(jobs))
+	for i, j := range jobs {
+		entries[i] = sqs.DeleteMessageBatchRequestEntry{
+			Id:            aws.String(strconv.Itoa(i)),
+			ReceiptHandle: j.msg.ReceiptHandle,
+		}
+	}
+
+	req := w.svc.DeleteMessageBatchRequest(&sqs.DeleteMessageBatchInput{
+		Entries:  entries,
+		QueueUrl: aws.String(w.cfg.QueueURL),
+	})
+
+	start := time.Now()
+	resp, err := req.Send()
+	sqsJobTimer.Set(time.Since(start).Seconds())
+
+	if err != nil {
+		logger.WithError(err).Error("unable to delete batch")
+		jobErrorTotal.Add(float64(len(jobs)))
+		return
+	}
+
+	for _, failed := range resp.Failed {
+		i, _ := strconv.Atoi(aws.StringValue(failed.Id))
+		w.jobLogger(jobs[i]).WithFields(Fields{
+			"code":  aws.StringValue(failed.Code),
+			"error": aws.StringValue(failed.Message),
+		}).Error("unable to delete job")
+		jobErrorTotal.Inc()
+	}
+
+	for _, deleted := range resp.Successful {
+		i, _ := strconv.Atoi(aws.StringValue(deleted.Id))
+		jobs[i].handled = true
+		jobProcessedTotal.Inc()
+	}
+
+	logger.WithField("deleted", len(resp.Successful)).Debug("batch deleted")
+}
@@ -0,0 +1,207 @@
+package goller_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/rcrowe/goller"
+)
+
+func (c *queueSQSClient) DeleteMessageBatchRequest(input *sqs.DeleteMessageBatchInput) sqs.DeleteMessageBatchRequest {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	out := sqs.DeleteMessageBatchOutput{}
+	for _, entry := range input.Entries {
+		c.deleted = append(c.deleted, aws.StringValue(entry.ReceiptHandle))
+		out.Successful = append(out.Successful, sqs.DeleteMessageBatchResultEntry{Id: entry.Id})
+	}
+	c.batches++
+
+	return sqs.DeleteMessageBatchRequest{
+		Request: &aws.Request{
+			Data: &out,
+		},
+	}
+}
+
+func (c *queueSQSClient) ChangeMessageVisibilityRequest(input *sqs.ChangeMessageVisibilityInput) sqs.ChangeMessageVisibilityRequest {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.released = append(c.released, aws.StringValue(input.ReceiptHandle))
+
+	return sqs.ChangeMessageVisibilityRequest{
+		Request: &aws.Request{
+			Data: &sqs.ChangeMessageVisibilityOutput{},
+		},
+	}
+}
+
+func newBatchSQSClient(count int) *queueSQSClient {
+	svc := newQueueSQSClient(count)
+	for i := range svc.messages {
+		svc.messages[i].Attributes = map[string]string{
+			string(sqs.MessageSystemAttributeNameApproximateReceiveCount): "1",
+		}
+	}
+
+	return svc
+}
+
+func TestBatchFailedJobsBackoff(t *testing.T) {
+	svc := newBatchSQSClient(3)
+
+	cfg := goller.NewDefaultConfig("foo", 1)
+	cfg.Consumer.RunOnce = true
+
+	var size int
+	w := goller.NewFromConfig(svc, cfg)
+	w.ListenBatch(context.Background(), func(ctx context.Context, jobs []goller.Job) goller.BatchResult {
+		size = len(jobs)
+
+		var result goller.BatchResult
+		result.Fail(jobs[1], errors.New("bad row"))
+		return result
+	})
+
+	if size != 3 {
+		t.Errorf("expected batch of `3` but got `%d`", size)
+	}
+	if svc.batches != 1 {
+		t.Errorf("expected a single batch delete but got `%d`", svc.batches)
+	}
+	if len(svc.deleted) != 2 || svc.deleted[0] != "handle-0" || svc.deleted[1] != "handle-2" {
+		t.Errorf("expected successful jobs to be deleted but got `%v`", svc.deleted)
+	}
+	if len(svc.released) != 1 || svc.released[0] != "handle-1" {
+		t.Errorf("expected failed job to be backed off but got `%v`", svc.released)
+	}
+}
+
+func TestBatchErrorAndPanic(t *testing.T) {
+	for name, handler := range map[string]goller.BatchHandlerFunc{
+		"error": func(ctx context.Context, jobs []goller.Job) goller.BatchResult {
+			return goller.BatchError(errors.New("transaction failed"))
+		},
+		"panic": func(ctx context.Context, jobs []goller.Job) goller.BatchResult {
+			panic("boom")
+		},
+	} {
+		svc := newBatchSQSClient(2)
+
+		cfg := goller.NewDefaultConfig("foo", 1)
+		cfg.Consumer.RunOnce = true
+
+		goller.NewFromConfig(svc, cfg).ListenBatch(context.Background(), handler)
+
+		if len(svc.deleted) != 0 {
+			t.Errorf("%s: expected no jobs to be deleted but got `%v`", name, svc.deleted)
+		}
+		if len(svc.released) != 2 {
+			t.Errorf("%s: expected every job to be backed off but got `%v`", name, svc.released)
+		}
+	}
+}
+
+func TestBatchHandlerHandledJobs(t *testing.T) {
+	svc := newBatchSQSClient(2)
+
+	cfg := goller.NewDefaultConfig("foo", 1)
+	cfg.Consumer.RunOnce = true
+
+	goller.NewFromConfig(svc, cfg).ListenBatch(context.Background(), func(ctx context.Context, jobs []goller.Job) goller.BatchResult {
+		jobs[0].Release(30)
+		return goller.BatchResult{}
+	})
+
+	if len(svc.deleted) != 1 || svc.deleted[0] != "handle-1" {
+		t.Errorf("expected only the unhandled job to be deleted but got `%v`", svc.deleted)
+	}
+}
+
+func TestBatchCircuitProbe(t *testing.T) {
+	breaker := goller.NewCircuitBreaker(goller.CircuitBreakerConfig{
+		MinRequests:  1,
+		OpenDuration: 10 * time.Millisecond,
+		Mode:         goller.CircuitRequeue,
+	})
+	openCircuit(t, breaker)
+	time.Sleep(15 * time.Millisecond)
+
+	svc := newBatchSQSClient(2)
+
+	cfg := goller.NewDefaultConfig("foo", 1)
+	cfg.RunOnce()
+	cfg.Consumer.RetrievalMaxNumberOfMessages = 2
+	cfg.Consumer.CircuitBreaker = breaker
+
+	// Half the probe batch failing still fails the probe
+	goller.NewFromConfig(svc, cfg).ListenBatch(context.Background(), func(ctx context.Context, jobs []goller.Job) goller.BatchResult {
+		var result goller.BatchResult
+		result.Fail(jobs[0], errors.New("bad row"))
+		return result
+	})
+
+	if state := breaker.State(); state != goller.CircuitOpen {
+		t.Errorf("expected failed probe batch to open the circuit but got `%s`", state)
+	}
+}
+
+func TestBatchGather(t *testing.T) {
+	svc := newBatchSQSClient(12)
+
+	cfg := goller.NewDefaultConfig("foo", 1)
+	cfg.Consumer.BatchSize = 12
+	cfg.Consumer.BatchWindow = time.Minute
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var sizes []int
+	goller.NewFromConfig(svc, cfg).ListenBatch(ctx, func(ctx context.Context, jobs []goller.Job) goller.BatchResult {
+		sizes = append(sizes, len(jobs))
+		cancel()
+		return goller.BatchResult{}
+	})
+
+	if len(sizes) != 1 || sizes[0] != 12 {
+		t.Errorf("expected a single batch of `12` but got `%v`", sizes)
+	}
+	if len(svc.Receives()) != 2 {
+		t.Errorf("expected batch to be gathered over `2` receives but got `%d`", len(svc.Receives()))
+	}
+	if svc.batches != 2 || len(svc.deleted) != 12 {
+		t.Errorf("expected `12` jobs deleted over `2` batch requests but got `%d` over `%d`", len(svc.deleted), svc.batches)
+	}
+}
+
+func TestBatchGatherWindow(t *testing.T) {
+	svc := newBatchSQSClient(1)
+
+	cfg := goller.NewDefaultConfig("foo", 1)
+	cfg.Consumer.BatchWindow = 100 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var sizes []int
+	goller.NewFromConfig(svc, cfg).ListenBatch(ctx, func(ctx context.Context, jobs []goller.Job) goller.BatchResult {
+		sizes = append(sizes, len(jobs))
+		cancel()
+		return goller.BatchResult{}
+	})
+
+	if len(sizes) != 1 || sizes[0] != 1 {
+		t.Errorf("expected the batch to be handled once the queue was empty but got `%v`", sizes)
+	}
+
+	receives := svc.Receives()
+	if len(receives) != 2 || aws.Int64Value(receives[1].WaitTimeSeconds) != 0 {
+		t.Errorf("expected a short poll within the window but got `%+v`", receives)
+	}
+}
@@ -0,0 +1,324 @@
+package goller
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+)
+
+// CircuitState is the state of the circuit breaker.
+type CircuitState string
+
+// States the circuit breaker moves between.
+const (
+	CircuitClosed   CircuitState = "closed"
+	CircuitOpen     CircuitState = "open"
+	CircuitHalfOpen CircuitState = "half-open"
+)
+
+// CircuitMode decides what consumers do while the circuit is open.
+type CircuitMode int
+
+const (
+	// CircuitStopReceiving stops consumers receiving from SQS while the circuit is open.
+	CircuitStopReceiving CircuitMode = iota
+
+	// CircuitRequeue keeps receiving, sending each message back to the queue
+	// so that it doesn't count towards the number of tries.
+	CircuitRequeue
+)
+
+// CircuitBreakerConfig configures when the circuit opens and how it recovers.
+type CircuitBreakerConfig struct {
+	// Ratio of handler errors, between 0 and 1, that opens the circuit.
+	// Default 0.5.
+	FailureThreshold float64
+
+	// Minimum number of handler calls within the window before the circuit can open.
+	// Default 10.
+	MinRequests int
+
+	// Handler calls are counted over this window.
+	// Default 1 minute.
+	Window time.Duration
+
+	// How long the circuit stays open before probing with a single message.
+	// Default 30 seconds.
+	OpenDuration time.Duration
+
+	// What consumers do while the circuit is open.
+	// Default CircuitStopReceiving.
+	Mode CircuitMode
+}
+
+// CircuitBreaker stops handlers being called while they keep failing, so that an outage
+// of a downstream dependency doesn't burn through tries & fill the dead letter queue.
+// Messages that reach the handler while the circuit is open are sent back to the queue
+// without counting towards the number of tries.
+type CircuitBreaker struct {
+	cfg CircuitBreakerConfig
+	log Logger
+
+	mu          sync.Mutex
+	state       CircuitState
+	openedAt    time.Time
+	windowStart time.Time
+	total       int
+	failures    int
+
+	// Half-open allows a single message through
+	probeReceiving bool
+	probeInFlight  bool
+}
+
+// NewCircuitBreaker creates a closed circuit breaker.
+func NewCircuitBreaker(cfg CircuitBreakerConfig) *CircuitBreaker {
+	if cfg.FailureThreshold <= 0 {
+		cfg.FailureThreshold = 0.5
+	}
+	if cfg.MinRequests < 1 {
+		cfg.MinRequests = 10
+	}
+	if cfg.Window <= 0 {
+		cfg.Window = time.Minute
+	}
+	if cfg.OpenDuration <= 0 {
+		cfg.OpenDuration = 30 * time.Second
+	}
+
+	circuitState.Set(0)
+
+	return &CircuitBreaker{
+		cfg:   cfg,
+		log:   NewNopLogger(),
+		state: CircuitClosed,
+	}
+}
+
+// State of the circuit.
+func (b *CircuitBreaker) State() CircuitState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.checkOpenDuration(time.Now())
+
+	return b.state
+}
+
+// transition must hold the lock.
+func (b *CircuitBreaker) transition(to CircuitState, now time.Time) {
+	if b.state == to {
+		return
+	}
+
+	b.log.WithFields(Fields{
+		"from": b.state,
+		"to":   to,
+	}).Info("circuit breaker state changed")
+
+	b.state = to
+	b.probeReceiving = false
+	b.probeInFlight = false
+
+	switch to {
+	case CircuitOpen:
+		b.openedAt = now
+		circuitOpenedTotal.Inc()
+		circuitState.Set(2)
+	case CircuitHalfOpen:
+		circuitState.Set(1)
+	case CircuitClosed:
+		b.windowStart = now
+		b.total = 0
+		b.failures = 0
+		circuitState.Set(0)
+	}
+}
+
+// checkOpenDuration moves to half-open once the circuit has been open long enough.
+// Must hold the lock.
+func (b *CircuitBreaker) checkOpenDuration(now time.Time) {
+	if b.state == CircuitOpen && now.Sub(b.openedAt) >= b.cfg.OpenDuration {
+		b.transition(CircuitHalfOpen, now)
+	}
+}
+
+// receivePermit decides whether a consumer can receive from SQS.
+// Returns how long to wait before asking again, and whether the receive is the half-open probe.
+func (b *CircuitBreaker) receivePermit() (wait time.Duration, probe bool) {
+	if b.cfg.Mode != CircuitStopReceiving {
+		return 0, false
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	b.checkOpenDuration(now)
+
+	switch b.state {
+	case CircuitOpen:
+		return b.cfg.OpenDuration - now.Sub(b.openedAt), false
+
+	case CircuitHalfOpen:
+		if b.probeReceiving || b.probeInFlight {
+			return time.Second, false
+		}
+
+		b.probeReceiving = true
+		return 0, true
+	}
+
+	return 0, false
+}
+
+// probeReceived lets another consumer try to receive the probe message
+// when nothing was received on the last attempt.
+// Otherwise the probe is in progress until the handler outcome is recorded.
+func (b *CircuitBreaker) probeReceived(count int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if count == 0 {
+		b.probeReceiving = false
+	}
+}
+
+// probeHandled lets another consumer receive a probe once the probe message has been handled.
+// The probe message may never reach the handler, i.e. it expired or wasn't due,
+// in which case no outcome is recorded & the circuit would stay half-open.
+func (b *CircuitBreaker) probeHandled() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == CircuitHalfOpen && !b.probeInFlight {
+		b.probeReceiving = false
+	}
+}
+
+// allow decides whether a job can be passed on to the handler.
+func (b *CircuitBreaker) allow() (ok bool, probe bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.checkOpenDuration(time.Now())
+
+	switch b.state {
+	case CircuitOpen:
+		return false, false
+
+	case CircuitHalfOpen:
+		if b.probeInFlight {
+			return false, false
+		}
+
+		b.probeInFlight = true
+		return true, true
+	}
+
+	return true, false
+}
+
+// record the outcome of the handler.
+func (b *CircuitBreaker) record(probe bool, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+
+	if probe {
+		if err != nil {
+			b.transition(CircuitOpen, now)
+		} else {
+			b.transition(CircuitClosed, now)
+		}
+		return
+	}
+
+	if b.state != CircuitClosed {
+		return
+	}
+
+	if now.Sub(b.windowStart) > b.cfg.Window {
+		b.windowStart = now
+		b.total = 0
+		b.failures = 0
+	}
+
+	b.total++
+	if err != nil {
+		b.failures++
+	}
+
+	if b.total >= b.cfg.MinRequests && float64(b.failures)/float64(b.total) >= b.cfg.FailureThreshold {
+		b.transition(CircuitOpen, now)
+	}
+}
+
+// retryIn is how long until the circuit will next be probed.
+func (b *CircuitBreaker) retryIn() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state != CircuitOpen {
+		return 0
+	}
+
+	return b.cfg.OpenDuration - time.Since(b.openedAt)
+}
+
+// waitForCircuit blocks a consumer from receiving while the circuit is open.
+// Returns false if the context is done while waiting.
+func (w *sqsWorker) waitForCircuit(ctx context.Context, id int, b *CircuitBreaker) (probe bool, ok bool) {
+	for {
+		wait, probe := b.receivePermit()
+		if wait <= 0 {
+			return probe, true
+		}
+
+		w.state.consumer(id, ConsumerCircuitOpen)
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return false, false
+		case <-timer.C:
+		}
+	}
+}
+
+// requeue sends a copy of the message back to the queue & deletes the original,
+// so the message doesn't count towards the number of tries.
+// The copy starts with a fresh ApproximateReceiveCount, which is what the queue's redrive policy counts,
+// so requeued jobs take longer to reach the dead letter queue. The tries made so far are carried over with
+// TriesAttribute, which only Job.Tries() (& so Backoff()) reads. See sendCopy(...) for what else is kept.
+// Falls back to releasing the job if that fails.
+func (w *sqsWorker) requeue(j Job, msg sqs.Message, delay time.Duration, logger Logger) {
+	circuitRequeuedTotal.Inc()
+
+	attrs := make(map[string]sqs.MessageAttributeValue, len(msg.MessageAttributes)+1)
+	for k, v := range msg.MessageAttributes {
+		attrs[k] = v
+	}
+	setTries(attrs, j)
+
+	secs := hopDelay(delay)
+	if err := w.sendCopy(msg, attrs, secs); err != nil {
+		logger.WithError(err).Error("circuit open. unable to requeue job, releasing instead")
+		if err := j.Release(secs); err != nil {
+			logger.WithError(err).Error("circuit open. unable to release job")
+		}
+		return
+	}
+
+	// The copy has been sent, so don't release the original otherwise we'll have 2
+	if err := j.Delete(); err != nil {
+		logger.WithError(err).Error("circuit open. unable to delete requeued job")
+		return
+	}
+
+	logger.WithField("delay", time.Duration(secs)*time.Second).Debug("circuit open. requeued job")
+}
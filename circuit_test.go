@@ -0,0 +1,343 @@
+package goller_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/sqsiface"
+	"github.com/rcrowe/goller"
+)
+
+type queueSQSClient struct {
+	sqsiface.SQSAPI
+	lock     sync.Mutex
+	messages []sqs.Message
+	receives []sqs.ReceiveMessageInput
+	sent     []sqs.SendMessageInput
+	deleted  []string
+	released []string
+	batches  int
+}
+
+func newQueueSQSClient(count int) *queueSQSClient {
+	c := &queueSQSClient{}
+	for i := 0; i < count; i++ {
+		c.messages = append(c.messages, sqs.Message{
+			MessageId:     aws.String(fmt.Sprintf("msg-%d", i)),
+			ReceiptHandle: aws.String(fmt.Sprintf("handle-%d", i)),
+			Body:          aws.String(fmt.Sprintf("body-%d", i)),
+		})
+	}
+	return c
+}
+
+func (c *queueSQSClient) ReceiveMessageRequest(input *sqs.ReceiveMessageInput) sqs.ReceiveMessageRequest {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.receives = append(c.receives, *input)
+
+	n := int(aws.Int64Value(input.MaxNumberOfMessages))
+	if n > len(c.messages) {
+		n = len(c.messages)
+	}
+	msgs := c.messages[:n]
+	c.messages = c.messages[n:]
+
+	return sqs.ReceiveMessageRequest{
+		Request: &aws.Request{
+			Data: &sqs.ReceiveMessageOutput{Messages: msgs},
+		},
+	}
+}
+
+func (c *queueSQSClient) SendMessageRequest(input *sqs.SendMessageInput) sqs.SendMessageRequest {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.sent = append(c.sent, *input)
+
+	return sqs.SendMessageRequest{
+		Request: &aws.Request{
+			Data: &sqs.SendMessageOutput{MessageId: aws.String("copy")},
+		},
+	}
+}
+
+func (c *queueSQSClient) DeleteMessageRequest(input *sqs.DeleteMessageInput) sqs.DeleteMessageRequest {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.deleted = append(c.deleted, aws.StringValue(input.ReceiptHandle))
+
+	return sqs.DeleteMessageRequest{
+		Request: &aws.Request{
+			Data: &sqs.DeleteMessageOutput{},
+		},
+	}
+}
+
+func (c *queueSQSClient) Receives() []sqs.ReceiveMessageInput {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return append([]sqs.ReceiveMessageInput{}, c.receives...)
+}
+
+// openCircuit trips the breaker with a single failing job.
+func openCircuit(t *testing.T, breaker *goller.CircuitBreaker) {
+	cfg := goller.NewDefaultConfig("foo", 1)
+	cfg.RunOnce()
+	cfg.Consumer.CircuitBreaker = breaker
+
+	w := goller.NewFromConfig(newQueueSQSClient(1), cfg)
+	w.Listen(context.Background(), func(ctx context.Context, j goller.Job) error {
+		return errors.New("downstream is down")
+	})
+
+	if state := breaker.State(); state != goller.CircuitOpen {
+		t.Fatalf("expected circuit to be open but got `%s`", state)
+	}
+}
+
+func TestCircuitOpensOnFailureThreshold(t *testing.T) {
+	breaker := goller.NewCircuitBreaker(goller.CircuitBreakerConfig{
+		FailureThreshold: 0.5,
+		MinRequests:      4,
+	})
+
+	cfg := goller.NewDefaultConfig("foo", 1)
+	cfg.RunOnce()
+	cfg.Consumer.RetrievalMaxNumberOfMessages = 3
+	cfg.Consumer.CircuitBreaker = breaker
+
+	// Below the minimum number of requests
+	w := goller.NewFromConfig(newQueueSQSClient(3), cfg)
+	w.Listen(context.Background(), func(ctx context.Context, j goller.Job) error {
+		return errors.New("downstream is down")
+	})
+
+	if state := breaker.State(); state != goller.CircuitClosed {
+		t.Fatalf("expected circuit to be closed but got `%s`", state)
+	}
+
+	// Tips it over
+	w = goller.NewFromConfig(newQueueSQSClient(1), cfg)
+	w.Listen(context.Background(), func(ctx context.Context, j goller.Job) error {
+		return nil
+	})
+
+	if state := breaker.State(); state != goller.CircuitOpen {
+		t.Fatalf("expected circuit to be open but got `%s`", state)
+	}
+	if w.Status().Circuit != goller.CircuitOpen {
+		t.Errorf("expected status to report open circuit but got `%s`", w.Status().Circuit)
+	}
+}
+
+func TestCircuitOpenRequeuesJobs(t *testing.T) {
+	breaker := goller.NewCircuitBreaker(goller.CircuitBreakerConfig{
+		MinRequests: 1,
+		Mode:        goller.CircuitRequeue,
+	})
+	openCircuit(t, breaker)
+
+	svc := newQueueSQSClient(2)
+	svc.messages[0].MessageAttributes = map[string]sqs.MessageAttributeValue{
+		"tenant": {DataType: aws.String("String"), StringValue: aws.String("a")},
+	}
+
+	cfg := goller.NewDefaultConfig("foo", 1)
+	cfg.RunOnce()
+	cfg.Consumer.RetrievalMaxNumberOfMessages = 2
+	cfg.Consumer.CircuitBreaker = breaker
+
+	calls := 0
+	w := goller.NewFromConfig(svc, cfg)
+	w.Listen(context.Background(), func(ctx context.Context, j goller.Job) error {
+		calls++
+		return nil
+	})
+
+	if calls != 0 {
+		t.Errorf("expected handler not to be called but was called `%d` times", calls)
+	}
+	if len(svc.sent) != 2 {
+		t.Fatalf("expected `2` messages to be requeued but got `%d`", len(svc.sent))
+	}
+	if len(svc.deleted) != 2 {
+		t.Errorf("expected `2` originals to be deleted but got `%d`", len(svc.deleted))
+	}
+
+	for _, sent := range svc.sent {
+		if aws.Int64Value(sent.DelaySeconds) < 1 || aws.Int64Value(sent.DelaySeconds) > 30 {
+			t.Errorf("expected delay until the circuit is probed but got `%d`", aws.Int64Value(sent.DelaySeconds))
+		}
+		if aws.StringValue(sent.MessageBody) == "body-0" && aws.StringValue(sent.MessageAttributes["tenant"].StringValue) != "a" {
+			t.Error("expected requeued message to keep its attributes")
+		}
+	}
+}
+
+func TestCircuitRequeueKeepsTries(t *testing.T) {
+	breaker := goller.NewCircuitBreaker(goller.CircuitBreakerConfig{
+		MinRequests: 1,
+		Mode:        goller.CircuitRequeue,
+	})
+	openCircuit(t, breaker)
+
+	svc := newQueueSQSClient(1)
+	svc.messages[0].Attributes = map[string]string{
+		string(sqs.MessageSystemAttributeNameApproximateReceiveCount): "3",
+	}
+
+	cfg := goller.NewDefaultConfig("foo", 1)
+	cfg.RunOnce()
+	cfg.Consumer.CircuitBreaker = breaker
+
+	w := goller.NewFromConfig(svc, cfg)
+	w.Listen(context.Background(), func(ctx context.Context, j goller.Job) error {
+		return nil
+	})
+
+	if len(svc.sent) != 1 {
+		t.Fatalf("expected `1` message to be requeued but got `%d`", len(svc.sent))
+	}
+
+	// 2 tries before this receive, which isn't counted
+	if tries := aws.StringValue(svc.sent[0].MessageAttributes[goller.TriesAttribute].StringValue); tries != "2" {
+		t.Errorf("expected requeued message to carry over `2` tries but got `%s`", tries)
+	}
+}
+
+func TestCircuitHalfOpenProbe(t *testing.T) {
+	breaker := goller.NewCircuitBreaker(goller.CircuitBreakerConfig{
+		MinRequests:  1,
+		OpenDuration: 20 * time.Millisecond,
+		Mode:         goller.CircuitRequeue,
+	})
+	openCircuit(t, breaker)
+
+	time.Sleep(25 * time.Millisecond)
+	if state := breaker.State(); state != goller.CircuitHalfOpen {
+		t.Fatalf("expected circuit to be half-open but got `%s`", state)
+	}
+
+	svc := newQueueSQSClient(3)
+	cfg := goller.NewDefaultConfig("foo", 1)
+	cfg.RunOnce()
+	cfg.Consumer.RetrievalMaxNumberOfMessages = 3
+	cfg.Consumer.CircuitBreaker = breaker
+
+	var lock sync.Mutex
+	calls := 0
+	w := goller.NewFromConfig(svc, cfg)
+	w.Listen(context.Background(), func(ctx context.Context, j goller.Job) error {
+		lock.Lock()
+		calls++
+		lock.Unlock()
+
+		// Keep the probe in-flight while the others arrive
+		time.Sleep(20 * time.Millisecond)
+		return nil
+	})
+
+	if calls != 1 {
+		t.Errorf("expected a single probe but handler was called `%d` times", calls)
+	}
+	if len(svc.sent) != 2 {
+		t.Errorf("expected `2` messages to be requeued but got `%d`", len(svc.sent))
+	}
+	if state := breaker.State(); state != goller.CircuitClosed {
+		t.Errorf("expected successful probe to close the circuit but got `%s`", state)
+	}
+}
+
+// A probe message dropped before reaching the handler mustn't leave the circuit half-open.
+func TestCircuitProbeDropped(t *testing.T) {
+	for name, listen := range map[string]func(w goller.Worker, ctx context.Context){
+		"expired": func(w goller.Worker, ctx context.Context) {
+			w.Listen(ctx, func(ctx context.Context, j goller.Job) error {
+				return j.Delete()
+			})
+		},
+		"not due": func(w goller.Worker, ctx context.Context) {
+			w.ListenBatch(ctx, func(ctx context.Context, jobs []goller.Job) goller.BatchResult {
+				return goller.BatchResult{}
+			})
+		},
+	} {
+		breaker := goller.NewCircuitBreaker(goller.CircuitBreakerConfig{
+			MinRequests:  1,
+			OpenDuration: 10 * time.Millisecond,
+		})
+		openCircuit(t, breaker)
+
+		// First message is the probe, & is dropped
+		svc := newBatchSQSClient(2)
+		svc.messages[0].Attributes["SentTimestamp"] = strconv.FormatInt(time.Now().Add(-2*time.Hour).UnixNano()/int64(time.Millisecond), 10)
+		svc.messages[0].MessageAttributes = map[string]sqs.MessageAttributeValue{
+			goller.NotBeforeAttribute: notBefore(time.Now().Add(time.Hour)),
+		}
+
+		cfg := goller.NewDefaultConfig("foo", 1)
+		cfg.Consumer.CircuitBreaker = breaker
+		cfg.Consumer.MaxMessageAge = time.Hour
+		if name == "not due" {
+			cfg.Consumer.MaxMessageAge = 0
+		}
+		w := goller.NewFromConfig(svc, cfg)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			listen(w, ctx)
+		}()
+
+		waitFor(t, func() bool { return breaker.State() == goller.CircuitClosed })
+
+		cancel()
+		<-done
+	}
+}
+
+func TestCircuitOpenStopsReceiving(t *testing.T) {
+	breaker := goller.NewCircuitBreaker(goller.CircuitBreakerConfig{
+		MinRequests:  1,
+		OpenDuration: 50 * time.Millisecond,
+	})
+	openCircuit(t, breaker)
+
+	svc := newQueueSQSClient(5)
+	w := goller.NewFromConfig(svc, func() *goller.Config {
+		cfg := goller.NewDefaultConfig("foo", 2)
+		cfg.Consumer.CircuitBreaker = breaker
+		return cfg
+	}())
+
+	cancel := listenInBackground(w)
+	defer cancel()
+
+	waitFor(t, func() bool {
+		status := w.Status()
+		return len(status.Consumers) == 2 && status.Consumers[0].State == goller.ConsumerCircuitOpen
+	})
+	if len(svc.Receives()) != 0 {
+		t.Errorf("expected no receives while the circuit is open but got `%d`", len(svc.Receives()))
+	}
+
+	// Probe receives a single message, then the circuit closes
+	waitFor(t, func() bool { return breaker.State() == goller.CircuitClosed })
+
+	receives := svc.Receives()
+	if len(receives) == 0 || aws.Int64Value(receives[0].MaxNumberOfMessages) != 1 {
+		t.Errorf("expected probe to receive a single message but got `%+v`", receives)
+	}
+}
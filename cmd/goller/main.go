@@ -0,0 +1,177 @@
+// Command goller inspects & operates the SQS queues that Goller consumes from.
+//
+//	goller stats -queue some-queue
+//	goller peek  -queue some-queue -n 5 -json
+//	goller tail  -queue some-queue -yes
+//	goller send  -queue some-queue -attr tenant=acme '{"hello": "world"}'
+//	goller purge -queue some-queue
+//	goller redrive -queue some-queue-dlq -attr tenant=acme -dry-run
+//
+// The queue is either a URL or a name, defaulting to $GOLLER_QUEUE.
+// AWS credentials & region come from the usual environment variables & shared config.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/external"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/cloudwatchiface"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/sqsiface"
+)
+
+// errUsage is returned when the command line is invalid, the usage has already been printed.
+var errUsage = errors.New("invalid usage")
+
+// command is a goller subcommand.
+type command struct {
+	usage string
+	run   func(ctx context.Context, a *app, args []string) error
+}
+
+var commands = map[string]command{
+	"stats":   {"queue depth, in-flight, delayed & age of the oldest message", runStats},
+	"peek":    {"print messages without removing them from the queue", runPeek},
+	"tail":    {"continuously print new messages without removing them, raising their receive count", runTail},
+	"send":    {"send a message, the body is read from stdin when not given", runSend},
+	"purge":   {"delete every message in the queue", runPurge},
+	"redrive": {"move messages from a dead letter queue back to its source queue", runRedrive},
+}
+
+// app holds the clients & streams the commands use.
+type app struct {
+	svc    sqsiface.SQSAPI
+	cw     cloudwatchiface.CloudWatchAPI
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+}
+
+// options common to every command.
+type options struct {
+	queue string
+	json  bool
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	cfg, err := external.LoadDefaultAWSConfig()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "unable to load AWS config:", err)
+		os.Exit(1)
+	}
+
+	a := &app{
+		svc:    sqs.New(cfg),
+		cw:     cloudwatch.New(cfg),
+		stdin:  os.Stdin,
+		stdout: os.Stdout,
+		stderr: os.Stderr,
+	}
+
+	if err := a.run(ctx, os.Args[1:]); err != nil {
+		if err != errUsage {
+			fmt.Fprintln(os.Stderr, "goller:", err)
+		}
+		os.Exit(1)
+	}
+}
+
+func (a *app) run(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		a.usage()
+		return errUsage
+	}
+
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(a.stderr, "unknown command %q\n\n", args[0])
+		a.usage()
+		return errUsage
+	}
+
+	return cmd.run(ctx, a, args[1:])
+}
+
+func (a *app) usage() {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintln(a.stderr, "usage: goller <command> [flags]")
+	fmt.Fprintln(a.stderr)
+	w := tabwriter.NewWriter(a.stderr, 0, 4, 2, ' ', 0)
+	for _, name := range names {
+		fmt.Fprintf(w, "  %s\t%s\n", name, commands[name].usage)
+	}
+	w.Flush()
+}
+
+// flags for the command, including the common options.
+func (a *app) flags(name string) (*flag.FlagSet, *options) {
+	opts := &options{}
+
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(a.stderr)
+	fs.StringVar(&opts.queue, "queue", os.Getenv("GOLLER_QUEUE"), "queue URL or name")
+	fs.BoolVar(&opts.json, "json", false, "output JSON")
+
+	return fs, opts
+}
+
+// parse the flags, returning errUsage when they're invalid.
+func parse(fs *flag.FlagSet, opts *options, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	if opts.queue == "" {
+		fmt.Fprintln(fs.Output(), "-queue is required")
+		fs.Usage()
+		return errUsage
+	}
+
+	return nil
+}
+
+// queueURL resolves a queue name to its URL.
+func (a *app) queueURL(queue string) (string, error) {
+	if strings.HasPrefix(queue, "https://") || strings.HasPrefix(queue, "http://") {
+		return queue, nil
+	}
+
+	resp, err := a.svc.GetQueueUrlRequest(&sqs.GetQueueUrlInput{
+		QueueName: aws.String(queue),
+	}).Send()
+	if err != nil {
+		return "", err
+	}
+
+	return aws.StringValue(resp.QueueUrl), nil
+}
+
+// queueName from the URL.
+func queueName(queueURL string) string {
+	return queueURL[strings.LastIndex(queueURL, "/")+1:]
+}
+
+// printJSON writes v as a single line.
+func (a *app) printJSON(v interface{}) error {
+	return json.NewEncoder(a.stdout).Encode(v)
+}
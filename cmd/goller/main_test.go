@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/cloudwatchiface"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/sqsiface"
+)
+
+const testQueueURL = "https://sqs.eu-west-1.amazonaws.com/123456789012/jobs"
+
+// fakeSQS always returns the same messages, as SQS does with a visibility timeout of 0.
+type fakeSQS struct {
+	sqsiface.SQSAPI
+	mu         sync.Mutex
+	messages   []sqs.Message
+	attributes map[string]string
+	receives   []sqs.ReceiveMessageInput
+	sent       []sqs.SendMessageInput
+	purged     []string
+	deleted    []string
+	released   []string
+	sources    []string
+}
+
+func (c *fakeSQS) GetQueueUrlRequest(input *sqs.GetQueueUrlInput) sqs.GetQueueUrlRequest {
+	return sqs.GetQueueUrlRequest{
+		Request: &aws.Request{
+			Data: &sqs.GetQueueUrlOutput{
+				QueueUrl: aws.String("https://sqs.eu-west-1.amazonaws.com/123456789012/" + aws.StringValue(input.QueueName)),
+			},
+		},
+	}
+}
+
+func (c *fakeSQS) GetQueueAttributesRequest(input *sqs.GetQueueAttributesInput) sqs.GetQueueAttributesRequest {
+	return sqs.GetQueueAttributesRequest{
+		Request: &aws.Request{
+			Data: &sqs.GetQueueAttributesOutput{Attributes: c.attributes},
+		},
+	}
+}
+
+func (c *fakeSQS) ReceiveMessageRequest(input *sqs.ReceiveMessageInput) sqs.ReceiveMessageRequest {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.receives = append(c.receives, *input)
+
+	n := int(aws.Int64Value(input.MaxNumberOfMessages))
+	if n > len(c.messages) {
+		n = len(c.messages)
+	}
+
+	msgs := c.messages[:n]
+
+	// Hidden until released
+	if aws.Int64Value(input.VisibilityTimeout) > 0 {
+		c.messages = c.messages[n:]
+	}
+
+	return sqs.ReceiveMessageRequest{
+		Request: &aws.Request{
+			HTTPRequest: &http.Request{},
+			Data:        &sqs.ReceiveMessageOutput{Messages: msgs},
+		},
+	}
+}
+
+func (c *fakeSQS) DeleteMessageRequest(input *sqs.DeleteMessageInput) sqs.DeleteMessageRequest {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.deleted = append(c.deleted, aws.StringValue(input.ReceiptHandle))
+
+	return sqs.DeleteMessageRequest{
+		Request: &aws.Request{Data: &sqs.DeleteMessageOutput{}},
+	}
+}
+
+func (c *fakeSQS) ChangeMessageVisibilityBatchRequest(input *sqs.ChangeMessageVisibilityBatchInput) sqs.ChangeMessageVisibilityBatchRequest {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, entry := range input.Entries {
+		c.released = append(c.released, aws.StringValue(entry.ReceiptHandle))
+	}
+
+	return sqs.ChangeMessageVisibilityBatchRequest{
+		Request: &aws.Request{Data: &sqs.ChangeMessageVisibilityBatchOutput{}},
+	}
+}
+
+func (c *fakeSQS) ListDeadLetterSourceQueuesRequest(input *sqs.ListDeadLetterSourceQueuesInput) sqs.ListDeadLetterSourceQueuesRequest {
+	return sqs.ListDeadLetterSourceQueuesRequest{
+		Request: &aws.Request{
+			Data: &sqs.ListDeadLetterSourceQueuesOutput{QueueUrls: c.sources},
+		},
+	}
+}
+
+func (c *fakeSQS) SendMessageRequest(input *sqs.SendMessageInput) sqs.SendMessageRequest {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.sent = append(c.sent, *input)
+
+	return sqs.SendMessageRequest{
+		Request: &aws.Request{
+			HTTPRequest: &http.Request{},
+			Data:        &sqs.SendMessageOutput{MessageId: aws.String("sent-id")},
+		},
+	}
+}
+
+func (c *fakeSQS) PurgeQueueRequest(input *sqs.PurgeQueueInput) sqs.PurgeQueueRequest {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.purged = append(c.purged, aws.StringValue(input.QueueUrl))
+
+	return sqs.PurgeQueueRequest{
+		Request: &aws.Request{
+			HTTPRequest: &http.Request{},
+			Data:        &sqs.PurgeQueueOutput{},
+		},
+	}
+}
+
+func (c *fakeSQS) Receives() []sqs.ReceiveMessageInput {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]sqs.ReceiveMessageInput{}, c.receives...)
+}
+
+type fakeCloudWatch struct {
+	cloudwatchiface.CloudWatchAPI
+	datapoints []cloudwatch.Datapoint
+	requested  []cloudwatch.GetMetricStatisticsInput
+}
+
+func (c *fakeCloudWatch) GetMetricStatisticsRequest(input *cloudwatch.GetMetricStatisticsInput) cloudwatch.GetMetricStatisticsRequest {
+	c.requested = append(c.requested, *input)
+
+	return cloudwatch.GetMetricStatisticsRequest{
+		Request: &aws.Request{
+			Data: &cloudwatch.GetMetricStatisticsOutput{Datapoints: c.datapoints},
+		},
+	}
+}
+
+// testApp with the fakes, returning stdout & stderr.
+func testApp(svc *fakeSQS, cw *fakeCloudWatch, stdin string) (*app, *bytes.Buffer, *bytes.Buffer) {
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+
+	return &app{
+		svc:    svc,
+		cw:     cw,
+		stdin:  strings.NewReader(stdin),
+		stdout: stdout,
+		stderr: stderr,
+	}, stdout, stderr
+}
+
+func TestUsage(t *testing.T) {
+	tests := map[string][]string{
+		"no command":      {},
+		"unknown command": {"nope"},
+		"missing queue":   {"stats"},
+		"invalid flag":    {"peek", "-queue", "jobs", "-nope"},
+	}
+
+	for name, args := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv("GOLLER_QUEUE", "")
+
+			a, _, stderr := testApp(&fakeSQS{}, &fakeCloudWatch{}, "")
+			if err := a.run(context.Background(), args); err != errUsage {
+				t.Fatalf("expected errUsage, got %v", err)
+			}
+			if stderr.Len() == 0 {
+				t.Fatal("expected usage to be printed")
+			}
+		})
+	}
+}
+
+func TestQueueURL(t *testing.T) {
+	a, _, _ := testApp(&fakeSQS{}, &fakeCloudWatch{}, "")
+
+	tests := map[string]string{
+		"jobs":       testQueueURL,
+		testQueueURL: testQueueURL,
+		"http://localhost:4566/000000000000/jobs": "http://localhost:4566/000000000000/jobs",
+	}
+
+	for queue, expected := range tests {
+		url, err := a.queueURL(queue)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if url != expected {
+			t.Fatalf("expected %s, got %s", expected, url)
+		}
+		if name := queueName(url); name != "jobs" {
+			t.Fatalf("expected queue name jobs, got %s", name)
+		}
+	}
+}
+
+func TestQueueFromEnvironment(t *testing.T) {
+	t.Setenv("GOLLER_QUEUE", "jobs")
+
+	svc := &fakeSQS{attributes: map[string]string{}}
+	a, stdout, _ := testApp(svc, &fakeCloudWatch{}, "")
+
+	if err := a.run(context.Background(), []string{"stats"}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(stdout.String(), "jobs") {
+		t.Fatalf("expected stats for jobs, got %q", stdout.String())
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/rcrowe/goller"
+)
+
+// peekedMessage is how a message is printed.
+type peekedMessage struct {
+	ID                string                     `json:"id"`
+	Attributes        map[string]string          `json:"attributes"`
+	MessageAttributes map[string]peekedAttribute `json:"message_attributes,omitempty"`
+	Body              string                     `json:"body"`
+}
+
+type peekedAttribute struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+func runPeek(ctx context.Context, a *app, args []string) error {
+	fs, opts := a.flags("peek")
+	count := fs.Int64("n", 1, "number of messages, 1 to 10")
+	if err := parse(fs, opts, args); err != nil {
+		return err
+	}
+
+	queueURL, err := a.queueURL(opts.queue)
+	if err != nil {
+		return err
+	}
+
+	msgs, err := a.peek(ctx, queueURL, *count, 0)
+	if err != nil {
+		return err
+	}
+
+	if len(msgs) == 0 && !opts.json {
+		fmt.Fprintln(a.stderr, "no messages")
+	}
+
+	for _, msg := range msgs {
+		if err := a.printMessage(msg, opts.json); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// maxTailSeen is the number of message IDs tail remembers having printed.
+const maxTailSeen = 10000
+
+// tailWarning is printed when tail is run without -yes.
+const tailWarning = `WARNING: tail receives every message on the queue again each -interval, as SQS can't skip the ones already printed.
+Each receive adds to the message's receive count, pushing it towards the queue's dead letter queue
+& inflating the tries Goller reports. Run again with -yes to tail anyway.`
+
+func runTail(ctx context.Context, a *app, args []string) error {
+	fs, opts := a.flags("tail")
+	interval := fs.Duration("interval", 10*time.Second, "wait between receives once every message has been printed")
+	yes := fs.Bool("yes", false, "tail even though every receive counts towards the redrive policy & tries of the messages")
+	if err := parse(fs, opts, args); err != nil {
+		return err
+	}
+	if !*yes {
+		fmt.Fprintln(a.stderr, tailWarning)
+		return errUsage
+	}
+
+	queueURL, err := a.queueURL(opts.queue)
+	if err != nil {
+		return err
+	}
+
+	// Messages stay on the queue, so they keep being received
+	seen := make(map[string]bool)
+	order := []string{}
+
+	for ctx.Err() == nil {
+		msgs, err := a.peek(ctx, queueURL, 10, 20)
+		if err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			return err
+		}
+
+		printed := 0
+		for _, msg := range msgs {
+			id := aws.StringValue(msg.MessageId)
+			if seen[id] {
+				continue
+			}
+
+			seen[id] = true
+			order = append(order, id)
+			if len(order) > maxTailSeen {
+				delete(seen, order[0])
+				order = order[1:]
+			}
+
+			if err := a.printMessage(msg, opts.json); err != nil {
+				return err
+			}
+			printed++
+		}
+
+		if printed == 0 {
+			select {
+			case <-ctx.Done():
+			case <-time.After(*interval):
+			}
+		}
+	}
+
+	return nil
+}
+
+// peek receives messages with a visibility timeout of 0, so they're left on the queue for consumers.
+// Each receive still counts towards the queue's redrive policy.
+func (a *app) peek(ctx context.Context, queueURL string, count, waitSeconds int64) ([]sqs.Message, error) {
+	req := a.svc.ReceiveMessageRequest(&sqs.ReceiveMessageInput{
+		AttributeNames:        []sqs.QueueAttributeName{sqs.QueueAttributeNameAll},
+		MaxNumberOfMessages:   aws.Int64(count),
+		MessageAttributeNames: []string{"All"},
+		QueueUrl:              aws.String(queueURL),
+		VisibilityTimeout:     aws.Int64(0),
+		WaitTimeSeconds:       aws.Int64(waitSeconds),
+	})
+	req.SetContext(ctx)
+
+	resp, err := req.Send()
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.Messages, nil
+}
+
+func toPeeked(msg sqs.Message) peekedMessage {
+	j := goller.NewJob(goller.NewDefaultConfig("", 1), goller.NewNopLogger(), msg, nil)
+	body, _ := j.Body()
+
+	peeked := peekedMessage{
+		ID:                j.ID(),
+		Attributes:        msg.Attributes,
+		MessageAttributes: make(map[string]peekedAttribute),
+		Body:              body,
+	}
+	for name, attr := range j.Attributes() {
+		value := attr.StringValue
+		if attr.Type() == goller.AttributeTypeBinary {
+			value = base64.StdEncoding.EncodeToString(attr.BinaryValue)
+		}
+		peeked.MessageAttributes[name] = peekedAttribute{Type: attr.DataType, Value: value}
+	}
+
+	return peeked
+}
+
+func (a *app) printMessage(msg sqs.Message, asJSON bool) error {
+	peeked := toPeeked(msg)
+	if asJSON {
+		return a.printJSON(peeked)
+	}
+
+	w := tabwriter.NewWriter(a.stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "id\t%s\n", peeked.ID)
+	for _, name := range sortedKeys(peeked.Attributes) {
+		fmt.Fprintf(w, "%s\t%s\n", name, peeked.Attributes[name])
+	}
+	for _, name := range sortedKeys(peeked.MessageAttributes) {
+		attr := peeked.MessageAttributes[name]
+		fmt.Fprintf(w, "%s (%s)\t%s\n", name, attr.Type, attr.Value)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	_, err := fmt.Fprintf(a.stdout, "\n%s\n%s\n", peeked.Body, strings.Repeat("-", 40))
+	return err
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
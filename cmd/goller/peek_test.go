@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+)
+
+func peekSQS() *fakeSQS {
+	return &fakeSQS{
+		messages: []sqs.Message{
+			{
+				MessageId:  aws.String("msg-1"),
+				Body:       aws.String(`{"hello":"world"}`),
+				Attributes: map[string]string{"ApproximateReceiveCount": "2"},
+				MessageAttributes: map[string]sqs.MessageAttributeValue{
+					"tenant": {DataType: aws.String("String"), StringValue: aws.String("acme")},
+					"blob":   {DataType: aws.String("Binary"), BinaryValue: []byte("hi")},
+				},
+			},
+			{
+				MessageId: aws.String("msg-2"),
+				Body:      aws.String("second"),
+			},
+		},
+	}
+}
+
+func TestPeek(t *testing.T) {
+	svc := peekSQS()
+	a, stdout, _ := testApp(svc, &fakeCloudWatch{}, "")
+
+	if err := a.run(context.Background(), []string{"peek", "-queue", testQueueURL}); err != nil {
+		t.Fatal(err)
+	}
+
+	out := stdout.String()
+	for _, expected := range []string{"msg-1", "ApproximateReceiveCount  2", "tenant (String)", "acme", `{"hello":"world"}`} {
+		if !strings.Contains(out, expected) {
+			t.Fatalf("expected %q in %q", expected, out)
+		}
+	}
+	if strings.Contains(out, "msg-2") {
+		t.Fatalf("expected a single message, got %q", out)
+	}
+
+	receive := svc.Receives()[0]
+	if aws.Int64Value(receive.VisibilityTimeout) != 0 || receive.VisibilityTimeout == nil {
+		t.Fatal("expected peek to receive with a visibility timeout of 0")
+	}
+}
+
+func TestPeekJSON(t *testing.T) {
+	a, stdout, _ := testApp(peekSQS(), &fakeCloudWatch{}, "")
+
+	if err := a.run(context.Background(), []string{"peek", "-queue", testQueueURL, "-n", "2", "-json"}); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %d", len(lines))
+	}
+
+	var msg peekedMessage
+	if err := json.Unmarshal([]byte(lines[0]), &msg); err != nil {
+		t.Fatal(err)
+	}
+	if msg.ID != "msg-1" || msg.Body != `{"hello":"world"}` {
+		t.Fatalf("unexpected message %+v", msg)
+	}
+	if attr := msg.MessageAttributes["blob"]; attr.Type != "Binary" || attr.Value != "aGk=" {
+		t.Fatalf("expected base64 binary attribute, got %+v", attr)
+	}
+}
+
+func TestTail(t *testing.T) {
+	svc := peekSQS()
+	a, stdout, _ := testApp(svc, &fakeCloudWatch{}, "")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- a.run(ctx, []string{"tail", "-queue", testQueueURL, "-interval", "time.Millisecond", "-json"})
+	}()
+	if err := <-done; err != errUsage {
+		t.Fatalf("expected invalid interval to fail, got %v", err)
+	}
+
+	go func() {
+		done <- a.run(ctx, []string{"tail", "-queue", testQueueURL, "-interval", "1ms", "-json", "-yes"})
+	}()
+
+	// Every receive returns the same messages, they're only printed once
+	for len(svc.Receives()) < 5 {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected each message printed once, got %d lines", len(lines))
+	}
+}
+
+func TestTailRequiresYes(t *testing.T) {
+	svc := peekSQS()
+	a, _, stderr := testApp(svc, &fakeCloudWatch{}, "")
+
+	if err := a.run(context.Background(), []string{"tail", "-queue", testQueueURL}); err != errUsage {
+		t.Fatalf("expected tail without -yes to fail, got %v", err)
+	}
+	if len(svc.Receives()) != 0 {
+		t.Fatalf("expected no receives, got %d", len(svc.Receives()))
+	}
+	if !strings.Contains(stderr.String(), "WARNING") {
+		t.Fatalf("expected a warning, got %q", stderr.String())
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+)
+
+// errNotConfirmed is returned when the purge wasn't confirmed.
+var errNotConfirmed = errors.New("purge not confirmed")
+
+func runPurge(ctx context.Context, a *app, args []string) error {
+	fs, opts := a.flags("purge")
+	yes := fs.Bool("yes", false, "skip confirmation")
+	if err := parse(fs, opts, args); err != nil {
+		return err
+	}
+
+	queueURL, err := a.queueURL(opts.queue)
+	if err != nil {
+		return err
+	}
+	name := queueName(queueURL)
+
+	if !*yes {
+		fmt.Fprintf(a.stderr, "This deletes every message in %s. Type the queue name to confirm: ", name)
+
+		answer, _ := bufio.NewReader(a.stdin).ReadString('\n')
+		if strings.TrimSpace(answer) != name {
+			return errNotConfirmed
+		}
+	}
+
+	req := a.svc.PurgeQueueRequest(&sqs.PurgeQueueInput{
+		QueueUrl: aws.String(queueURL),
+	})
+	req.SetContext(ctx)
+
+	if _, err := req.Send(); err != nil {
+		return err
+	}
+
+	if opts.json {
+		return a.printJSON(map[string]string{"purged": name})
+	}
+
+	_, err = fmt.Fprintf(a.stdout, "purged %s, which can take up to 60 seconds\n", name)
+	return err
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestPurge(t *testing.T) {
+	tests := map[string]struct {
+		args   []string
+		stdin  string
+		err    error
+		purged bool
+	}{
+		"confirmed": {
+			stdin:  "jobs\n",
+			purged: true,
+		},
+		"wrong queue": {
+			stdin: "other\n",
+			err:   errNotConfirmed,
+		},
+		"no answer": {
+			err: errNotConfirmed,
+		},
+		"yes": {
+			args:   []string{"-yes"},
+			purged: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			svc := &fakeSQS{}
+			a, _, _ := testApp(svc, &fakeCloudWatch{}, test.stdin)
+
+			args := append([]string{"purge", "-queue", "jobs"}, test.args...)
+			if err := a.run(context.Background(), args); err != test.err {
+				t.Fatalf("expected %v, got %v", test.err, err)
+			}
+
+			if purged := len(svc.purged) == 1; purged != test.purged {
+				t.Fatalf("expected purged %t, got %t", test.purged, purged)
+			}
+			if test.purged && svc.purged[0] != testQueueURL {
+				t.Fatalf("expected %s purged, got %s", testQueueURL, svc.purged[0])
+			}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/rcrowe/goller"
+	"github.com/rcrowe/goller/logrusadapter"
+	"github.com/sirupsen/logrus"
+)
+
+func runRedrive(ctx context.Context, a *app, args []string) error {
+	fs, opts := a.flags("redrive")
+	attrs := attributeFlags{}
+	fs.Var(attrs, "attr", "only move messages with the attribute value, as name=value, repeatable")
+	to := fs.String("to", "", "queue URL or name to move messages to, defaults to the dead letter queue's only source queue")
+	minAge := fs.Duration("min-age", 0, "only move messages sent at least this long ago")
+	maxAge := fs.Duration("max-age", 0, "only move messages sent at most this long ago")
+	body := fs.String("body", "", "only move messages with a body matching the regular expression")
+	max := fs.Int64("max", 0, "maximum number of messages to move")
+	dryRun := fs.Bool("dry-run", false, "count the messages that would be moved, without moving them")
+	rate := fs.Float64("rate", 0, "messages moved per second")
+	strip := fs.Bool("strip", false, "remove Goller's not-before & hops attributes")
+	resetTries := fs.Bool("reset-tries", false, "start the tries of moved messages again from 0")
+	checkpoint := fs.String("checkpoint", "", "file remembering moved messages, so an interrupted redrive can be run again")
+	visibility := fs.Int64("visibility", 300, "seconds messages are hidden on the dead letter queue during the redrive")
+	if err := parse(fs, opts, args); err != nil {
+		return err
+	}
+
+	cfg := goller.RedriveConfig{
+		Attributes:        attrs,
+		MinAge:            *minAge,
+		MaxAge:            *maxAge,
+		Max:               *max,
+		DryRun:            *dryRun,
+		Rate:              *rate,
+		StripAttributes:   *strip,
+		ResetTries:        *resetTries,
+		VisibilityTimeout: *visibility,
+	}
+
+	log := logrus.New()
+	log.Out = a.stderr
+	cfg.Log = logrusadapter.New(log)
+
+	if *body != "" {
+		re, err := regexp.Compile(*body)
+		if err != nil {
+			fmt.Fprintln(a.stderr, "invalid -body:", err)
+			return errUsage
+		}
+		cfg.Body = re
+	}
+
+	var err error
+	if cfg.DeadLetterQueueURL, err = a.queueURL(opts.queue); err != nil {
+		return err
+	}
+	if cfg.QueueURL, err = a.sourceQueueURL(cfg.DeadLetterQueueURL, *to); err != nil {
+		return err
+	}
+
+	if *checkpoint != "" {
+		if cfg.Checkpoint, err = goller.NewFileIdempotencyStore(*checkpoint); err != nil {
+			return err
+		}
+	}
+
+	result, err := goller.Redrive(ctx, a.svc, cfg)
+
+	if opts.json {
+		if printErr := a.printJSON(map[string]interface{}{
+			"from":    queueName(cfg.DeadLetterQueueURL),
+			"to":      queueName(cfg.QueueURL),
+			"dry_run": cfg.DryRun,
+			"matched": result.Matched,
+			"moved":   result.Moved,
+			"failed":  result.Failed,
+			"skipped": result.Skipped,
+		}); printErr != nil {
+			return printErr
+		}
+	} else {
+		verb := "moved"
+		if cfg.DryRun {
+			verb = "would move"
+		}
+		fmt.Fprintf(a.stdout, "%s %d of %d matching messages from %s to %s. %d failed, %d skipped\n",
+			verb, result.Moved, result.Matched, queueName(cfg.DeadLetterQueueURL), queueName(cfg.QueueURL), result.Failed, result.Skipped)
+	}
+
+	if err != nil {
+		return err
+	}
+	if result.Failed > 0 {
+		return fmt.Errorf("%d messages couldn't be moved, they're still on the dead letter queue", result.Failed)
+	}
+
+	return nil
+}
+
+// sourceQueueURL is the queue given, or the only queue using the dead letter queue.
+func (a *app) sourceQueueURL(dlq, to string) (string, error) {
+	if to != "" {
+		return a.queueURL(to)
+	}
+
+	resp, err := a.svc.ListDeadLetterSourceQueuesRequest(&sqs.ListDeadLetterSourceQueuesInput{
+		QueueUrl: aws.String(dlq),
+	}).Send()
+	if err != nil {
+		return "", err
+	}
+
+	if len(resp.QueueUrls) != 1 {
+		return "", fmt.Errorf("%s is the dead letter queue of %d queues, choose one with -to", queueName(dlq), len(resp.QueueUrls))
+	}
+
+	return resp.QueueUrls[0], nil
+}
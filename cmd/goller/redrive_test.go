@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+)
+
+const testDLQURL = "https://sqs.eu-west-1.amazonaws.com/123456789012/jobs-dlq"
+
+func redriveSQS() *fakeSQS {
+	return &fakeSQS{
+		sources: []string{testQueueURL},
+		messages: []sqs.Message{
+			{
+				MessageId:         aws.String("msg-1"),
+				ReceiptHandle:     aws.String("handle-1"),
+				Body:              aws.String(`{"order": 1}`),
+				MessageAttributes: map[string]sqs.MessageAttributeValue{"tenant": {DataType: aws.String("String"), StringValue: aws.String("acme")}},
+			},
+			{
+				MessageId:     aws.String("msg-2"),
+				ReceiptHandle: aws.String("handle-2"),
+				Body:          aws.String(`{"order": 2}`),
+			},
+		},
+	}
+}
+
+func TestRedrive(t *testing.T) {
+	svc := redriveSQS()
+	a, stdout, _ := testApp(svc, &fakeCloudWatch{}, "")
+
+	args := []string{"redrive", "-queue", testDLQURL, "-attr", "tenant=acme", "-checkpoint", filepath.Join(t.TempDir(), "checkpoint")}
+	if err := a.run(context.Background(), args); err != nil {
+		t.Fatal(err)
+	}
+
+	if out := stdout.String(); !strings.Contains(out, "moved 1 of 1 matching messages from jobs-dlq to jobs. 0 failed, 1 skipped") {
+		t.Fatalf("unexpected output %q", out)
+	}
+	if len(svc.sent) != 1 || aws.StringValue(svc.sent[0].QueueUrl) != testQueueURL {
+		t.Fatalf("expected 1 message sent to the source queue, got %+v", svc.sent)
+	}
+	if len(svc.deleted) != 1 || svc.deleted[0] != "handle-1" {
+		t.Fatalf("expected msg-1 deleted, got %v", svc.deleted)
+	}
+	if len(svc.released) != 1 || svc.released[0] != "handle-2" {
+		t.Fatalf("expected msg-2 released, got %v", svc.released)
+	}
+}
+
+func TestRedriveDryRunJSON(t *testing.T) {
+	svc := redriveSQS()
+	a, stdout, _ := testApp(svc, &fakeCloudWatch{}, "")
+
+	if err := a.run(context.Background(), []string{"redrive", "-queue", testDLQURL, "-to", "jobs", "-body", "order", "-dry-run", "-json"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var result map[string]interface{}
+	if err := json.Unmarshal(stdout.Bytes(), &result); err != nil {
+		t.Fatal(err)
+	}
+	if result["matched"] != float64(2) || result["moved"] != float64(0) || result["dry_run"] != true {
+		t.Fatalf("unexpected result %v", result)
+	}
+	if len(svc.sent) != 0 {
+		t.Fatalf("expected nothing sent in a dry-run, got %d", len(svc.sent))
+	}
+}
+
+func TestRedriveSourceQueue(t *testing.T) {
+	svc := redriveSQS()
+	svc.sources = []string{testQueueURL, testQueueURL + "-other"}
+	a, _, _ := testApp(svc, &fakeCloudWatch{}, "")
+
+	err := a.run(context.Background(), []string{"redrive", "-queue", testDLQURL})
+	if err == nil || !strings.Contains(err.Error(), "choose one with -to") {
+		t.Fatalf("expected to be asked for -to, got %v", err)
+	}
+}
+
+func TestRedriveInvalidBody(t *testing.T) {
+	a, _, _ := testApp(redriveSQS(), &fakeCloudWatch{}, "")
+
+	if err := a.run(context.Background(), []string{"redrive", "-queue", testDLQURL, "-body", "("}); err != errUsage {
+		t.Fatalf("expected errUsage, got %v", err)
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/rcrowe/goller"
+)
+
+// attributeFlags collects repeated -attr name=value flags.
+type attributeFlags map[string]string
+
+func (f attributeFlags) String() string {
+	return fmt.Sprint(len(f), " attributes")
+}
+
+func (f attributeFlags) Set(v string) error {
+	parts := strings.SplitN(v, "=", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return fmt.Errorf("attribute %q must be name=value", v)
+	}
+
+	f[parts[0]] = parts[1]
+	return nil
+}
+
+// messageAttributes as string attributes.
+func (f attributeFlags) messageAttributes() map[string]sqs.MessageAttributeValue {
+	attrs := make(map[string]sqs.MessageAttributeValue, len(f))
+	for name, value := range f {
+		attrs[name] = sqs.MessageAttributeValue{
+			DataType:    aws.String(goller.AttributeTypeString),
+			StringValue: aws.String(value),
+		}
+	}
+
+	return attrs
+}
+
+func runSend(ctx context.Context, a *app, args []string) error {
+	fs, opts := a.flags("send")
+	attrs := attributeFlags{}
+	fs.Var(attrs, "attr", "string attribute as name=value, repeatable")
+	delay := fs.Int64("delay", 0, "seconds to delay the message, max 900")
+	if err := parse(fs, opts, args); err != nil {
+		return err
+	}
+
+	queueURL, err := a.queueURL(opts.queue)
+	if err != nil {
+		return err
+	}
+
+	body := strings.Join(fs.Args(), " ")
+	if fs.NArg() == 0 {
+		b, err := ioutil.ReadAll(a.stdin)
+		if err != nil {
+			return err
+		}
+		body = string(b)
+	}
+
+	id, err := goller.NewPublisher(a.svc, queueURL).Publish(ctx, goller.Message{
+		Body:         body,
+		Attributes:   attrs.messageAttributes(),
+		DelaySeconds: *delay,
+	})
+	if err != nil {
+		return err
+	}
+
+	if opts.json {
+		return a.printJSON(map[string]string{"id": id})
+	}
+
+	_, err = fmt.Fprintln(a.stdout, id)
+	return err
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+func TestSend(t *testing.T) {
+	svc := &fakeSQS{}
+	a, stdout, _ := testApp(svc, &fakeCloudWatch{}, "")
+
+	args := []string{"send", "-queue", testQueueURL, "-attr", "tenant=acme", "-attr", "query=a=b", "-delay", "30", "hello", "world"}
+	if err := a.run(context.Background(), args); err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.TrimSpace(stdout.String()) != "sent-id" {
+		t.Fatalf("expected message ID, got %q", stdout.String())
+	}
+
+	sent := svc.sent[0]
+	if body := aws.StringValue(sent.MessageBody); body != "hello world" {
+		t.Fatalf("expected body from arguments, got %q", body)
+	}
+	if delay := aws.Int64Value(sent.DelaySeconds); delay != 30 {
+		t.Fatalf("expected delay of 30, got %d", delay)
+	}
+	if v := aws.StringValue(sent.MessageAttributes["tenant"].StringValue); v != "acme" {
+		t.Fatalf("expected tenant attribute, got %q", v)
+	}
+	if v := aws.StringValue(sent.MessageAttributes["query"].StringValue); v != "a=b" {
+		t.Fatalf("expected value to keep =, got %q", v)
+	}
+}
+
+func TestSendFromStdin(t *testing.T) {
+	svc := &fakeSQS{}
+	a, stdout, _ := testApp(svc, &fakeCloudWatch{}, `{"from":"stdin"}`)
+
+	if err := a.run(context.Background(), []string{"send", "-queue", testQueueURL, "-json"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if body := aws.StringValue(svc.sent[0].MessageBody); body != `{"from":"stdin"}` {
+		t.Fatalf("expected body from stdin, got %q", body)
+	}
+	if strings.TrimSpace(stdout.String()) != `{"id":"sent-id"}` {
+		t.Fatalf("unexpected output %q", stdout.String())
+	}
+}
+
+func TestSendInvalidAttribute(t *testing.T) {
+	a, _, _ := testApp(&fakeSQS{}, &fakeCloudWatch{}, "")
+
+	if err := a.run(context.Background(), []string{"send", "-queue", testQueueURL, "-attr", "nope", "body"}); err != errUsage {
+		t.Fatalf("expected errUsage, got %v", err)
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+)
+
+// queueStats of a single queue.
+type queueStats struct {
+	Queue    string `json:"queue"`
+	Depth    int64  `json:"depth"`
+	InFlight int64  `json:"in_flight"`
+	Delayed  int64  `json:"delayed"`
+
+	// From CloudWatch, -1 when unavailable
+	OldestAge float64 `json:"oldest_age_seconds"`
+}
+
+func runStats(ctx context.Context, a *app, args []string) error {
+	fs, opts := a.flags("stats")
+	if err := parse(fs, opts, args); err != nil {
+		return err
+	}
+
+	queueURL, err := a.queueURL(opts.queue)
+	if err != nil {
+		return err
+	}
+
+	resp, err := a.svc.GetQueueAttributesRequest(&sqs.GetQueueAttributesInput{
+		AttributeNames: []sqs.QueueAttributeName{
+			sqs.QueueAttributeNameApproximateNumberOfMessages,
+			sqs.QueueAttributeNameApproximateNumberOfMessagesNotVisible,
+			sqs.QueueAttributeNameApproximateNumberOfMessagesDelayed,
+		},
+		QueueUrl: aws.String(queueURL),
+	}).Send()
+	if err != nil {
+		return err
+	}
+
+	attr := func(name sqs.QueueAttributeName) int64 {
+		n, _ := strconv.ParseInt(resp.Attributes[string(name)], 10, 64)
+		return n
+	}
+
+	stats := queueStats{
+		Queue:     queueName(queueURL),
+		Depth:     attr(sqs.QueueAttributeNameApproximateNumberOfMessages),
+		InFlight:  attr(sqs.QueueAttributeNameApproximateNumberOfMessagesNotVisible),
+		Delayed:   attr(sqs.QueueAttributeNameApproximateNumberOfMessagesDelayed),
+		OldestAge: -1,
+	}
+
+	// SQS doesn't expose the age of the oldest message, only CloudWatch does
+	if age, err := a.oldestAge(stats.Queue); err != nil {
+		fmt.Fprintln(a.stderr, "unable to get age of oldest message:", err)
+	} else {
+		stats.OldestAge = age.Seconds()
+	}
+
+	if opts.json {
+		return a.printJSON(stats)
+	}
+
+	oldest := "unknown"
+	if stats.OldestAge >= 0 {
+		oldest = (time.Duration(stats.OldestAge) * time.Second).String()
+	}
+
+	w := tabwriter.NewWriter(a.stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "queue\t%s\n", stats.Queue)
+	fmt.Fprintf(w, "depth\t%d\n", stats.Depth)
+	fmt.Fprintf(w, "in-flight\t%d\n", stats.InFlight)
+	fmt.Fprintf(w, "delayed\t%d\n", stats.Delayed)
+	fmt.Fprintf(w, "oldest\t%s\n", oldest)
+
+	return w.Flush()
+}
+
+// oldestAge is the latest ApproximateAgeOfOldestMessage metric.
+// CloudWatch publishes SQS metrics every minute, so look back over the last few.
+func (a *app) oldestAge(queue string) (time.Duration, error) {
+	now := time.Now()
+
+	resp, err := a.cw.GetMetricStatisticsRequest(&cloudwatch.GetMetricStatisticsInput{
+		Dimensions: []cloudwatch.Dimension{
+			{Name: aws.String("QueueName"), Value: aws.String(queue)},
+		},
+		StartTime:  aws.Time(now.Add(-5 * time.Minute)),
+		EndTime:    aws.Time(now),
+		MetricName: aws.String("ApproximateAgeOfOldestMessage"),
+		Namespace:  aws.String("AWS/SQS"),
+		Period:     aws.Int64(60),
+		Statistics: []cloudwatch.Statistic{cloudwatch.StatisticMaximum},
+	}).Send()
+	if err != nil {
+		return 0, err
+	}
+
+	var latest *cloudwatch.Datapoint
+	for i, point := range resp.Datapoints {
+		if latest == nil || aws.TimeValue(point.Timestamp).After(aws.TimeValue(latest.Timestamp)) {
+			latest = &resp.Datapoints[i]
+		}
+	}
+	if latest == nil {
+		return 0, fmt.Errorf("no datapoints for %s", queue)
+	}
+
+	return time.Duration(aws.Float64Value(latest.Maximum)) * time.Second, nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+)
+
+func statsSQS() *fakeSQS {
+	return &fakeSQS{
+		attributes: map[string]string{
+			"ApproximateNumberOfMessages":           "12",
+			"ApproximateNumberOfMessagesNotVisible": "3",
+			"ApproximateNumberOfMessagesDelayed":    "1",
+		},
+	}
+}
+
+func TestStatsJSON(t *testing.T) {
+	now := time.Now()
+	cw := &fakeCloudWatch{
+		datapoints: []cloudwatch.Datapoint{
+			{Timestamp: aws.Time(now.Add(-2 * time.Minute)), Maximum: aws.Float64(30)},
+			{Timestamp: aws.Time(now.Add(-time.Minute)), Maximum: aws.Float64(90)},
+		},
+	}
+
+	a, stdout, _ := testApp(statsSQS(), cw, "")
+	if err := a.run(context.Background(), []string{"stats", "-queue", testQueueURL, "-json"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var stats queueStats
+	if err := json.Unmarshal(stdout.Bytes(), &stats); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := queueStats{Queue: "jobs", Depth: 12, InFlight: 3, Delayed: 1, OldestAge: 90}
+	if stats != expected {
+		t.Fatalf("expected %+v, got %+v", expected, stats)
+	}
+
+	if len(cw.requested) != 1 {
+		t.Fatalf("expected 1 CloudWatch request, got %d", len(cw.requested))
+	}
+	if dim := cw.requested[0].Dimensions[0]; aws.StringValue(dim.Value) != "jobs" {
+		t.Fatalf("expected metric for jobs, got %s", aws.StringValue(dim.Value))
+	}
+}
+
+func TestStatsWithoutCloudWatch(t *testing.T) {
+	a, stdout, stderr := testApp(statsSQS(), &fakeCloudWatch{}, "")
+	if err := a.run(context.Background(), []string{"stats", "-queue", testQueueURL}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, line := range []string{"depth      12", "in-flight  3", "delayed    1", "oldest     unknown"} {
+		if !strings.Contains(stdout.String(), line) {
+			t.Fatalf("expected %q in %q", line, stdout.String())
+		}
+	}
+	if !strings.Contains(stderr.String(), "no datapoints") {
+		t.Fatalf("expected warning, got %q", stderr.String())
+	}
+}
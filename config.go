@@ -11,6 +11,7 @@ func NewDefaultConfig(queueURL string, consumerCount int) *Config {
 	return &Config{
 		Consumer: &ConsumerConfig{
 			Count:                        consumerCount,
+			RetrievalAttributeNames:      []string{"All"},
 			RetrievalErrWait:             30 * time.Second,
 			RetrievalMaxNumberOfMessages: 10,
 			RetrievalVisibilityTimeout:   int64((10 * time.Minute).Seconds()),
@@ -51,6 +52,13 @@ func (cfg *Config) RunSlowly(pause time.Duration) {
 	cfg.Consumer.RetrievalMaxNumberOfMessages = 1
 }
 
+// DryRun handles jobs without acknowledging them, for trying a new handler against real traffic.
+// Delete, Release & Backoff are logged & counted but never sent to SQS, the mode decides what happens to the message after.
+// Handlers still run for real, so anything they publish or write elsewhere still happens.
+func (cfg *Config) DryRun(mode DryRunMode) {
+	cfg.Consumer.DryRun = mode
+}
+
 // Config holds Goller configuration.
 // See NewDefaultConfig(...).
 type Config struct {
@@ -68,6 +76,12 @@ type ConsumerConfig struct {
 	// Number of workers that listen against the queue.
 	Count int
 
+	// SQS system attributes returned with each message, see the Job accessors.
+	// ApproximateReceiveCount & AWSTraceHeader are always requested,
+	// as is SentTimestamp when MaxMessageAge is set.
+	// Default is All.
+	RetrievalAttributeNames []string
+
 	// If an error occurs trying to retrieve messages,
 	// wait this long before attempting a reconnect.
 	// Default is 30 seconds.
@@ -96,12 +110,42 @@ type ConsumerConfig struct {
 	// using this duration. It super helpful when debugging jobs.
 	// Zero value disables the setting.
 	RunSlowly time.Duration
+
+	// Handlers run, but Delete, Release & Backoff calls are logged & never sent to SQS.
+	// See Config.DryRun(...). Zero value disables the setting.
+	DryRun DryRunMode
+
+	// Jobs sent longer ago than this are deleted without calling the handler.
+	// Zero value disables the setting.
+	MaxMessageAge time.Duration
+
+	// Keep receiving until a batch holds this many messages, or BatchWindow has passed.
+	// Default is RetrievalMaxNumberOfMessages.
+	BatchSize int
+
+	// Longest time to spend gathering messages over several receives into a single batch.
+	// Must be shorter than RetrievalVisibilityTimeout.
+	// Zero value disables the setting, each receive is its own batch.
+	BatchWindow time.Duration
+
+	// Adjusts the number of handlers that can run at once across all consumers,
+	// based off of handler latency and errors.
+	// Zero value disables the setting.
+	ConcurrencyLimiter ConcurrencyLimiter `json:"-"`
+
+	// Limits the rate handlers are called across all consumers.
+	// Zero value disables the setting.
+	RateLimiter *RateLimiter `json:"-"`
+
+	// Stops handlers being called while they keep failing.
+	// Zero value disables the setting.
+	CircuitBreaker *CircuitBreaker `json:"-"`
 }
 
 // JobConfig holds configuration for jobs.
 type JobConfig struct {
 	// Calculate the time the job should backoff.
-	BackoffCalc func(tries int64) int64
+	BackoffCalc func(tries int64) int64 `json:"-"`
 
 	// Minimum visibility timeout allowed.
 	// Default 10 seconds.
@@ -0,0 +1,254 @@
+package goller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+// ErrInvalidConcurrency is returned when trying to scale below a single consumer.
+// Use Pause() to stop consuming altogether.
+var ErrInvalidConcurrency = errors.New("concurrency must be at least 1")
+
+// control lets the worker be paused, resumed & scaled while listening.
+// Consumers check in with control between receives, so in-flight jobs are never dropped.
+type control struct {
+	mu      sync.Mutex
+	paused  bool
+	changed chan struct{}
+
+	// Set while listening
+	listen  *listenRun
+	running map[int]uint64
+
+	// Tells apart a consumer from the one that replaced it with the same ID
+	generation uint64
+}
+
+// listenRun is everything needed to start a new consumer within a call to Listen.
+type listenRun struct {
+	ctx    context.Context
+	handle messageHandler
+	wg     sync.WaitGroup
+
+	// Handlers have their own context, so they can carry on after Shutdown stops receiving
+	handlerCtx     context.Context
+	cancelHandlers context.CancelFunc
+	stopReceiving  context.CancelFunc
+	shuttingDown   int32
+}
+
+func newControl() *control {
+	return &control{
+		changed: make(chan struct{}),
+		running: make(map[int]uint64),
+	}
+}
+
+// notify wakes up any consumers waiting on a change.
+// Must hold the lock.
+func (c *control) notify() {
+	close(c.changed)
+	c.changed = make(chan struct{})
+}
+
+// Pause stops consumers from receiving any more messages.
+// Jobs already received are processed as normal.
+func (w *sqsWorker) Pause() {
+	w.control.mu.Lock()
+	defer w.control.mu.Unlock()
+
+	if !w.control.paused {
+		w.control.paused = true
+		w.control.notify()
+		w.log.Info("pausing consumers")
+	}
+}
+
+// Resume consumers after being paused.
+func (w *sqsWorker) Resume() {
+	w.control.mu.Lock()
+	defer w.control.mu.Unlock()
+
+	if w.control.paused {
+		w.control.paused = false
+		w.control.notify()
+		w.log.Info("resuming consumers")
+	}
+}
+
+// Paused returns whether the consumers have been paused.
+func (w *sqsWorker) Paused() bool {
+	w.control.mu.Lock()
+	defer w.control.mu.Unlock()
+
+	return w.control.paused
+}
+
+// SetConcurrency changes the number of consumers.
+// When listening, new consumers are started straight away and
+// surplus consumers stop once they have finished processing their current jobs.
+func (w *sqsWorker) SetConcurrency(n int) error {
+	if n < 1 {
+		return ErrInvalidConcurrency
+	}
+
+	w.control.mu.Lock()
+	defer w.control.mu.Unlock()
+
+	w.log.WithFields(Fields{
+		"from": w.cfg.Consumer.Count,
+		"to":   n,
+	}).Info("setting concurrency")
+
+	w.cfg.Consumer.Count = n
+	w.control.notify()
+
+	// Only start new consumers while others are still running,
+	// otherwise Listen has already returned.
+	if w.control.listen != nil && len(w.control.running) > 0 {
+		for id := 0; id < n; id++ {
+			if _, ok := w.control.running[id]; !ok {
+				w.startConsumer(id)
+			}
+		}
+	}
+
+	return nil
+}
+
+// startConsumer must hold the control lock.
+func (w *sqsWorker) startConsumer(id int) {
+	run := w.control.listen
+
+	w.control.generation++
+	gen := w.control.generation
+
+	w.control.running[id] = gen
+	w.state.addConsumer(id)
+	run.wg.Add(1)
+
+	go func() {
+		defer w.stopConsumer(id, gen)
+
+		w.receive(run, id)
+	}()
+}
+
+func (w *sqsWorker) stopConsumer(id int, gen uint64) {
+	w.control.mu.Lock()
+	defer w.control.mu.Unlock()
+
+	// A surplus consumer may already have been replaced
+	if w.control.running[id] == gen {
+		delete(w.control.running, id)
+	}
+	w.control.listen.wg.Done()
+}
+
+// checkIn is called by a consumer before each receive.
+// It blocks while paused and returns false when the consumer should stop.
+func (w *sqsWorker) checkIn(ctx context.Context, id int) bool {
+	for {
+		w.control.mu.Lock()
+		paused := w.control.paused
+		changed := w.control.changed
+
+		// Stop under the same lock as SetConcurrency(...), so it starts a replacement if Count goes back up
+		if id >= w.cfg.Consumer.Count {
+			delete(w.control.running, id)
+			w.state.removeConsumer(id)
+			w.control.mu.Unlock()
+
+			w.log.WithField("consumer", id).Debug("stopping surplus consumer")
+			return false
+		}
+		w.control.mu.Unlock()
+
+		if !paused {
+			return true
+		}
+
+		w.state.consumer(id, ConsumerPaused)
+
+		select {
+		case <-ctx.Done():
+			return false
+		case <-changed:
+		}
+	}
+}
+
+// NewAdminHandler lets you pause, resume & scale the worker over HTTP.
+//
+//	GET  /              - JSON encoded paused state & concurrency
+//	POST /pause         - stop receiving messages
+//	POST /resume        - start receiving messages again
+//	POST /concurrency?n - set the number of consumers
+func NewAdminHandler(w Worker) http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/", func(rw http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(rw, r)
+			return
+		}
+
+		writeAdminState(rw, w)
+	})
+
+	mux.HandleFunc("/pause", func(rw http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Pause()
+		writeAdminState(rw, w)
+	})
+
+	mux.HandleFunc("/resume", func(rw http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Resume()
+		writeAdminState(rw, w)
+	})
+
+	mux.HandleFunc("/concurrency", func(rw http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		n, err := strconv.Atoi(r.FormValue("n"))
+		if err != nil {
+			http.Error(rw, "invalid concurrency", http.StatusBadRequest)
+			return
+		}
+		if err := w.SetConcurrency(n); err != nil {
+			http.Error(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		writeAdminState(rw, w)
+	})
+
+	return mux
+}
+
+func writeAdminState(rw http.ResponseWriter, w Worker) {
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(struct {
+		Paused      bool `json:"paused"`
+		Concurrency int  `json:"concurrency"`
+	}{
+		Paused:      w.Paused(),
+		Concurrency: w.Config().Consumer.Count,
+	})
+}
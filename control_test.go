@@ -0,0 +1,231 @@
+package goller_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/sqsiface"
+	"github.com/rcrowe/goller"
+)
+
+type countingReceiveSQSClient struct {
+	sqsiface.SQSAPI
+	lock  sync.Mutex
+	calls int
+}
+
+func (c *countingReceiveSQSClient) ReceiveMessageRequest(input *sqs.ReceiveMessageInput) sqs.ReceiveMessageRequest {
+	c.lock.Lock()
+	c.calls++
+	c.lock.Unlock()
+
+	// Stand in for long polling
+	time.Sleep(time.Millisecond)
+
+	return sqs.ReceiveMessageRequest{
+		Request: &aws.Request{
+			Data: &sqs.ReceiveMessageOutput{},
+		},
+	}
+}
+
+func (c *countingReceiveSQSClient) Calls() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.calls
+}
+
+func listenInBackground(w goller.Worker) (cancel func()) {
+	ctx, stop := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		w.Listen(ctx, func(ctx context.Context, j goller.Job) error {
+			return nil
+		})
+	}()
+
+	return func() {
+		stop()
+		<-done
+	}
+}
+
+func TestPauseAndResume(t *testing.T) {
+	svc := &countingReceiveSQSClient{}
+	w := goller.NewFromConfig(svc, goller.NewDefaultConfig("foo", 2))
+
+	w.Pause()
+	if !w.Paused() {
+		t.Error("expected worker to be paused")
+	}
+
+	cancel := listenInBackground(w)
+	defer cancel()
+
+	waitFor(t, func() bool {
+		status := w.Status()
+		return len(status.Consumers) == 2 &&
+			status.Consumers[0].State == goller.ConsumerPaused &&
+			status.Consumers[1].State == goller.ConsumerPaused
+	})
+
+	if calls := svc.Calls(); calls != 0 {
+		t.Errorf("expected no receive calls while paused but got `%d`", calls)
+	}
+	if !w.Status().Live {
+		t.Error("expected paused worker to be live")
+	}
+
+	w.Resume()
+	waitFor(t, func() bool { return svc.Calls() > 0 })
+
+	if w.Paused() {
+		t.Error("expected worker to be resumed")
+	}
+}
+
+func TestSetConcurrency(t *testing.T) {
+	w := goller.NewFromConfig(&countingReceiveSQSClient{}, goller.NewDefaultConfig("foo", 1))
+
+	cancel := listenInBackground(w)
+	defer cancel()
+
+	waitFor(t, func() bool { return len(w.Status().Consumers) == 1 })
+
+	// Scale up
+	if err := w.SetConcurrency(3); err != nil {
+		t.Fatalf("expected no error but got `%s`", err)
+	}
+	waitFor(t, func() bool { return len(w.Status().Consumers) == 3 })
+
+	if count := w.Config().Consumer.Count; count != 3 {
+		t.Errorf("expected consumer count `3` but got `%d`", count)
+	}
+
+	// Scale down
+	if err := w.SetConcurrency(1); err != nil {
+		t.Fatalf("expected no error but got `%s`", err)
+	}
+	waitFor(t, func() bool { return len(w.Status().Consumers) == 1 })
+
+	// Scale down while paused
+	w.Pause()
+	w.SetConcurrency(2)
+	waitFor(t, func() bool { return len(w.Status().Consumers) == 2 })
+	w.SetConcurrency(1)
+	waitFor(t, func() bool { return len(w.Status().Consumers) == 1 })
+}
+
+// surplusLogger blocks the first consumer that stops as surplus, until released.
+type surplusLogger struct {
+	goller.Logger
+	once     sync.Once
+	stopping chan struct{}
+	release  chan struct{}
+}
+
+func (l *surplusLogger) WithField(key string, value interface{}) goller.Logger { return l }
+func (l *surplusLogger) WithFields(fields goller.Fields) goller.Logger         { return l }
+
+func (l *surplusLogger) Debug(msg string) {
+	if msg == "stopping surplus consumer" {
+		l.once.Do(func() {
+			close(l.stopping)
+			<-l.release
+		})
+	}
+}
+
+// A surplus consumer stopping while concurrency is raised again must be replaced.
+func TestSetConcurrencyRace(t *testing.T) {
+	logger := &surplusLogger{
+		Logger:   goller.NewNopLogger(),
+		stopping: make(chan struct{}),
+		release:  make(chan struct{}),
+	}
+
+	w := goller.NewFromConfig(&countingReceiveSQSClient{}, goller.NewDefaultConfig("foo", 2))
+	w.WithLogger(logger)
+	w.Pause()
+
+	cancel := listenInBackground(w)
+	defer cancel()
+
+	waitFor(t, func() bool { return len(w.Status().Consumers) == 2 })
+
+	w.SetConcurrency(1)
+	<-logger.stopping
+
+	// Raised again while consumer 1 is on its way out
+	w.SetConcurrency(2)
+	close(logger.release)
+
+	waitFor(t, func() bool { return len(w.Status().Consumers) == 2 })
+	time.Sleep(20 * time.Millisecond)
+
+	if consumers := len(w.Status().Consumers); consumers != 2 {
+		t.Errorf("expected `2` consumers but got `%d`", consumers)
+	}
+}
+
+func TestSetConcurrencyInvalid(t *testing.T) {
+	w := goller.NewFromConfig(&countingReceiveSQSClient{}, goller.NewDefaultConfig("foo", 1))
+
+	if err := w.SetConcurrency(0); err != goller.ErrInvalidConcurrency {
+		t.Errorf("expected ErrInvalidConcurrency but got `%v`", err)
+	}
+}
+
+func TestAdminHandler(t *testing.T) {
+	w := goller.NewFromConfig(&countingReceiveSQSClient{}, goller.NewDefaultConfig("foo", 1))
+	h := goller.NewAdminHandler(w)
+
+	call := func(method, path string) (int, map[string]interface{}) {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(method, path, nil))
+
+		body := map[string]interface{}{}
+		json.NewDecoder(rec.Body).Decode(&body)
+		return rec.Code, body
+	}
+
+	if code, _ := call(http.MethodGet, "/pause"); code != http.StatusMethodNotAllowed {
+		t.Errorf("expected `405` but got `%d`", code)
+	}
+
+	code, body := call(http.MethodPost, "/pause")
+	if code != http.StatusOK || body["paused"] != true {
+		t.Errorf("expected paused but got `%d` `%v`", code, body)
+	}
+
+	code, body = call(http.MethodPost, "/resume")
+	if code != http.StatusOK || body["paused"] != false {
+		t.Errorf("expected resumed but got `%d` `%v`", code, body)
+	}
+
+	code, body = call(http.MethodPost, "/concurrency?n=4")
+	if code != http.StatusOK || body["concurrency"] != float64(4) {
+		t.Errorf("expected concurrency of 4 but got `%d` `%v`", code, body)
+	}
+
+	if code, _ := call(http.MethodPost, "/concurrency?n=0"); code != http.StatusBadRequest {
+		t.Errorf("expected `400` but got `%d`", code)
+	}
+	if code, _ := call(http.MethodPost, "/concurrency?n=lots"); code != http.StatusBadRequest {
+		t.Errorf("expected `400` but got `%d`", code)
+	}
+
+	code, body = call(http.MethodGet, "/")
+	if code != http.StatusOK || body["concurrency"] != float64(4) || body["paused"] != false {
+		t.Errorf("unexpected state `%d` `%v`", code, body)
+	}
+}
@@ -0,0 +1,129 @@
+package goller
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// DryRunMode decides what happens to a message once a dry-run handler is done with it.
+type DryRunMode int
+
+const (
+	// DryRunOff acknowledges jobs as usual.
+	DryRunOff DryRunMode = iota
+
+	// DryRunRelease makes the message visible again straight away, for the live consumers to handle.
+	// The dry-run worker will also receive it again, combine with RunOnce or RunSlowly to avoid a hot loop.
+	DryRunRelease
+
+	// DryRunTimeout leaves the message hidden until RetrievalVisibilityTimeout passes.
+	DryRunTimeout
+)
+
+// dryRunJob records & logs Delete, Release & Backoff calls instead of sending them to SQS.
+type dryRunJob struct {
+	*ReplayJob
+	log Logger
+}
+
+func (j *dryRunJob) Delete() error {
+	return j.logCall(j.ReplayJob.Delete())
+}
+
+func (j *dryRunJob) Release(secs int64) error {
+	return j.logCall(j.ReplayJob.Release(secs))
+}
+
+func (j *dryRunJob) Backoff() error {
+	return j.logCall(j.ReplayJob.Backoff())
+}
+
+func (j *dryRunJob) logCall(err error) error {
+	if err != nil {
+		return err
+	}
+
+	calls := j.Calls()
+	call := calls[len(calls)-1]
+
+	switch call.Method {
+	case ReplayDelete:
+		dryRunDeleteTotal.Inc()
+	case ReplayRelease:
+		dryRunReleaseTotal.Inc()
+	case ReplayBackoff:
+		dryRunBackoffTotal.Inc()
+	}
+
+	j.log.WithFields(Fields{
+		"jid":     j.ID(),
+		"call":    call.Method,
+		"seconds": call.Seconds,
+	}).Info("dry-run. not sent to SQS")
+
+	return nil
+}
+
+type dryRunKey struct{}
+
+// IsDryRun returns whether the job being handled is a dry run, see Config.DryRun(...).
+// Handlers can check it to skip side effects of their own.
+func IsDryRun(ctx context.Context) bool {
+	dryRun, _ := ctx.Value(dryRunKey{}).(bool)
+	return dryRun
+}
+
+// dryRun returns whether jobs are being recorded instead of acknowledged.
+func (w *sqsWorker) dryRun() bool {
+	return w.cfg.Consumer.DryRun != DryRunOff
+}
+
+// newJob for the message, recording instead of acknowledging it in dry-run mode.
+func (w *sqsWorker) newJob(msg sqs.Message) Job {
+	j := NewJob(w.cfg, w.log, msg, w.svc)
+	if !w.dryRun() {
+		return j
+	}
+
+	return &dryRunJob{
+		ReplayJob: &ReplayJob{Job: j, cfg: w.cfg},
+		log:       w.log,
+	}
+}
+
+// finishDryRun puts the messages back on the queue, depending on the dry-run mode.
+func (w *sqsWorker) finishDryRun(msgs ...sqs.Message) {
+	if w.cfg.Consumer.DryRun != DryRunRelease {
+		return
+	}
+
+	for _, msg := range msgs {
+		w.log.WithField("jid", aws.StringValue(msg.MessageId)).Debug("dry-run. releasing message")
+	}
+	releaseMessages(w.svc, w.cfg.QueueURL, w.log, receiptHandles(msgs))
+}
+
+// outcomeCounters are the metrics for handled & failed jobs, kept apart in dry-run mode.
+func (w *sqsWorker) outcomeCounters() (processed, errored prometheus.Counter) {
+	if w.dryRun() {
+		return dryRunProcessedTotal, dryRunErrorTotal
+	}
+
+	return jobProcessedTotal, jobErrorTotal
+}
+
+func (m DryRunMode) String() string {
+	switch m {
+	case DryRunOff:
+		return "off"
+	case DryRunRelease:
+		return "release"
+	case DryRunTimeout:
+		return "timeout"
+	}
+
+	return "unknown"
+}
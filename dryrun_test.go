@@ -0,0 +1,177 @@
+package goller_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/rcrowe/goller"
+)
+
+func TestDryRun(t *testing.T) {
+	tests := map[string]struct {
+		mode     goller.DryRunMode
+		released []string
+	}{
+		"release": {mode: goller.DryRunRelease, released: []string{"handle-0", "handle-1", "handle-2"}},
+		"timeout": {mode: goller.DryRunTimeout},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			svc := newBatchSQSClient(3)
+
+			cfg := goller.NewDefaultConfig("foo", 1)
+			cfg.RunOnce()
+			cfg.Consumer.RetrievalMaxNumberOfMessages = 3
+			cfg.DryRun(test.mode)
+
+			processed := counterValue(t, "goller_dry_run_processed_total")
+			errored := counterValue(t, "goller_dry_run_error_total")
+			deletes := counterValue(t, "goller_dry_run_delete_total")
+			backoffs := counterValue(t, "goller_dry_run_backoff_total")
+			liveProcessed := counterValue(t, "goller_job_processed_total")
+
+			w := goller.NewFromConfig(svc, cfg)
+			w.Listen(context.Background(), func(ctx context.Context, j goller.Job) error {
+				switch j.ID() {
+				case "msg-0":
+					return j.Delete()
+				case "msg-1":
+					if err := j.Backoff(); err != nil {
+						return err
+					}
+					if !j.Handled() {
+						t.Error("expected the job to be handled once backed off")
+					}
+					return nil
+				default:
+					return errors.New("handler errored")
+				}
+			})
+
+			if len(svc.deleted) != 0 {
+				t.Errorf("expected nothing deleted but got `%v`", svc.deleted)
+			}
+
+			released := svc.Released()
+			if len(released) != len(test.released) {
+				t.Fatalf("expected `%v` released but got `%v`", test.released, released)
+			}
+			for _, handle := range test.released {
+				found := false
+				for _, r := range released {
+					found = found || r == handle
+				}
+				if !found {
+					t.Errorf("expected `%s` to be released", handle)
+				}
+			}
+
+			if v := counterValue(t, "goller_dry_run_processed_total") - processed; v != 2 {
+				t.Errorf("expected `2` dry-run jobs processed but got `%v`", v)
+			}
+			if v := counterValue(t, "goller_dry_run_error_total") - errored; v != 2 {
+				t.Errorf("expected `2` dry-run errors, errored & not handled, but got `%v`", v)
+			}
+			if v := counterValue(t, "goller_dry_run_delete_total") - deletes; v != 1 {
+				t.Errorf("expected `1` dry-run delete but got `%v`", v)
+			}
+			if v := counterValue(t, "goller_dry_run_backoff_total") - backoffs; v != 1 {
+				t.Errorf("expected `1` dry-run backoff but got `%v`", v)
+			}
+			if v := counterValue(t, "goller_job_processed_total") - liveProcessed; v != 0 {
+				t.Errorf("expected dry-run jobs kept out of the live metrics but got `%v`", v)
+			}
+		})
+	}
+}
+
+func TestDryRunBatch(t *testing.T) {
+	svc := newBatchSQSClient(3)
+
+	cfg := goller.NewDefaultConfig("foo", 1)
+	cfg.Consumer.RunOnce = true
+	cfg.DryRun(goller.DryRunRelease)
+
+	deletes := counterValue(t, "goller_dry_run_delete_total")
+	backoffs := counterValue(t, "goller_dry_run_backoff_total")
+	processed := counterValue(t, "goller_dry_run_processed_total")
+	errored := counterValue(t, "goller_dry_run_error_total")
+
+	w := goller.NewFromConfig(svc, cfg)
+	w.ListenBatch(context.Background(), func(ctx context.Context, jobs []goller.Job) goller.BatchResult {
+		var result goller.BatchResult
+		result.Fail(jobs[1], errors.New("bad row"))
+		return result
+	})
+
+	if svc.batches != 0 || len(svc.deleted) != 0 {
+		t.Errorf("expected nothing deleted but got `%v`", svc.deleted)
+	}
+	if released := svc.Released(); len(released) != 3 {
+		t.Errorf("expected the batch released but got `%v`", released)
+	}
+
+	if v := counterValue(t, "goller_dry_run_delete_total") - deletes; v != 2 {
+		t.Errorf("expected `2` dry-run deletes but got `%v`", v)
+	}
+	if v := counterValue(t, "goller_dry_run_backoff_total") - backoffs; v != 1 {
+		t.Errorf("expected `1` dry-run backoff but got `%v`", v)
+	}
+	if v := counterValue(t, "goller_dry_run_processed_total") - processed; v != 2 {
+		t.Errorf("expected `2` dry-run jobs processed but got `%v`", v)
+	}
+	if v := counterValue(t, "goller_dry_run_error_total") - errored; v != 1 {
+		t.Errorf("expected `1` dry-run job errored but got `%v`", v)
+	}
+}
+
+func TestDryRunIdempotency(t *testing.T) {
+	svc := newBatchSQSClient(1)
+	store := goller.NewMemoryIdempotencyStore(10)
+
+	cfg := goller.NewDefaultConfig("foo", 1)
+	cfg.RunOnce()
+	cfg.DryRun(goller.DryRunRelease)
+
+	handled := false
+	handler := goller.Chain(func(ctx context.Context, j goller.Job) error {
+		handled = goller.IsDryRun(ctx)
+		return j.Delete()
+	}, goller.NewIdempotencyMiddleware(goller.IdempotencyConfig{Store: store}))
+
+	goller.NewFromConfig(svc, cfg).Listen(context.Background(), handler)
+
+	if !handled {
+		t.Error("expected the handler to be told it's a dry run")
+	}
+
+	// Live workers still get to handle the message
+	if _, err := store.Acquire(context.Background(), "msg-0", time.Minute); err != nil {
+		t.Errorf("expected dry run to leave the store alone but got `%v`", err)
+	}
+}
+
+func TestDryRunScheduledJob(t *testing.T) {
+	svc := newQueueSQSClient(1)
+	svc.messages[0].MessageAttributes = map[string]sqs.MessageAttributeValue{
+		goller.NotBeforeAttribute: notBefore(time.Now().Add(time.Hour)),
+	}
+
+	cfg := goller.NewDefaultConfig("foo", 1)
+	cfg.RunOnce()
+	cfg.DryRun(goller.DryRunTimeout)
+
+	w := goller.NewFromConfig(svc, cfg)
+	w.Listen(context.Background(), func(ctx context.Context, j goller.Job) error {
+		t.Error("expected job not due to skip the handler")
+		return nil
+	})
+
+	if len(svc.sent) != 0 || len(svc.deleted) != 0 {
+		t.Errorf("expected the hop not to reach SQS but got `%d` sent & `%d` deleted", len(svc.sent), len(svc.deleted))
+	}
+}
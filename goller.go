@@ -3,7 +3,6 @@ package goller
 import (
 	"context"
 	"fmt"
-	"io/ioutil"
 	"sync"
 	"time"
 
@@ -11,7 +10,9 @@ import (
 	"github.com/aws/aws-sdk-go-v2/aws/awserr"
 	"github.com/aws/aws-sdk-go-v2/service/sqs"
 	"github.com/aws/aws-sdk-go-v2/service/sqs/sqsiface"
-	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
@@ -25,8 +26,18 @@ const (
 // either goller.New(svc, "https://queue/url/here", 10) or goller.NewFromConfig(svc, cfg).
 type Worker interface {
 	Config() Config
-	WithLogger(logger *logrus.Logger)
+	WithLogger(logger Logger)
+	WithTracerProvider(tp trace.TracerProvider)
 	Listen(ctx context.Context, handler HandlerFunc)
+	ListenBatch(ctx context.Context, handler BatchHandlerFunc)
+	Status() Status
+	Shutdown(ctx context.Context) error
+
+	// Runtime controls, safe to call from any goroutine
+	Pause()
+	Resume()
+	Paused() bool
+	SetConcurrency(n int) error
 }
 
 // HandlerFunc receives any job popped off the SQS queue.
@@ -40,41 +51,104 @@ func New(svc sqsiface.SQSAPI, queueURL string, consumerCount int) Worker {
 
 // NewFromConfig is for those power users that know what they want to change in the config.
 func NewFromConfig(svc sqsiface.SQSAPI, cfg *Config) Worker {
-	logger := logrus.New()
-	logger.Out = ioutil.Discard
-
-	return &sqsWorker{
-		cfg: cfg,
-		log: logger,
-		svc: svc,
+	w := &sqsWorker{
+		cfg:     cfg,
+		control: newControl(),
+		log:     NewNopLogger(),
+		state:   newWorkerState(),
+		svc:     svc,
 	}
+	w.WithTracerProvider(otel.GetTracerProvider())
+
+	return w
 }
 
 type sqsWorker struct {
-	cfg *Config
-	log *logrus.Logger
-	svc sqsiface.SQSAPI
+	cfg     *Config
+	control *control
+	log     Logger
+	state   *workerState
+	svc     sqsiface.SQSAPI
+	tracer  trace.Tracer
 }
 
 // Config gives you read-only access to how Goller was configured.
 func (w *sqsWorker) Config() Config {
-	return *w.cfg
+	w.control.mu.Lock()
+	defer w.control.mu.Unlock()
+
+	cfg := *w.cfg
+	if w.cfg.Consumer != nil {
+		consumer := *w.cfg.Consumer
+		cfg.Consumer = &consumer
+	}
+	if w.cfg.Job != nil {
+		job := *w.cfg.Job
+		cfg.Job = &job
+	}
+
+	return cfg
 }
 
 // WithLogger overrides the default logger.
 // By default no logs are ever writen, so if you want output you're going
-// to need to set your own logger.
-func (w *sqsWorker) WithLogger(logger *logrus.Logger) {
+// to need to set your own logger. See NewSlogLogger, logrusadapter & zapadapter.
+func (w *sqsWorker) WithLogger(logger Logger) {
 	w.log = logger
 }
 
+// WithTracerProvider overrides the global OpenTelemetry tracer provider.
+// Each job is processed within a consumer span, parented to the trace context
+// found on the message, which is available from the handler context.
+func (w *sqsWorker) WithTracerProvider(tp trace.TracerProvider) {
+	w.tracer = tp.Tracer(tracerName, trace.WithInstrumentationVersion(VERSION))
+}
+
+// Status reports on the health of the consumers and the jobs they are processing.
+// See NewHealthHandler(...) to expose this over HTTP.
+func (w *sqsWorker) Status() Status {
+	// Longest a healthy consumer could go between receive attempts
+	threshold := time.Duration(w.cfg.Consumer.RetrievalWaitTimeSeconds)*time.Second +
+		w.cfg.Consumer.RetrievalErrWait +
+		w.cfg.Consumer.RunSlowly +
+		30*time.Second
+
+	status := w.state.status(threshold)
+	status.Paused = w.Paused()
+	status.Config = w.Config()
+	if status.Config.Consumer.CircuitBreaker != nil {
+		status.Circuit = status.Config.Consumer.CircuitBreaker.State()
+	}
+
+	return status
+}
+
 // Listen to new SQS jobs.
 // Context allows you to gracefully shutdown the listener.
 func (w *sqsWorker) Listen(ctx context.Context, handler HandlerFunc) {
+	cfg := w.Config()
+
+	// Rate limit before taking up a handler slot
+	if cfg.Consumer.ConcurrencyLimiter != nil {
+		handler = newHandlerSlots(cfg.Consumer.ConcurrencyLimiter).middleware(handler)
+	}
+	if cfg.Consumer.RateLimiter != nil {
+		handler = cfg.Consumer.RateLimiter.Middleware(handler)
+	}
+
+	w.listen(ctx, func(ctx context.Context, consumer int, msgs []sqs.Message) {
+		w.handleResponse(ctx, consumer, msgs, handler)
+	})
+}
+
+// listen starts the consumers, passing whatever they receive to handle.
+func (w *sqsWorker) listen(ctx context.Context, handle messageHandler) {
+	cfg := w.Config()
+
 	// Welcome banner
-	w.log.WithFields(logrus.Fields{
+	w.log.WithFields(Fields{
 		"version":   VERSION,
-		"consumers": w.cfg.Consumer.Count,
+		"consumers": cfg.Consumer.Count,
 	}).Info("Starting Goller")
 	if w.cfg.Consumer.RunOnce {
 		w.log.Debug("`run-once` enabled")
@@ -82,30 +156,62 @@ func (w *sqsWorker) Listen(ctx context.Context, handler HandlerFunc) {
 	if w.cfg.Consumer.RunSlowly > time.Duration(0) {
 		w.log.WithField("slowly", w.cfg.Consumer.RunSlowly.String()).Debug("`run-slowly` enabled")
 	}
+	if w.dryRun() {
+		w.log.WithField("mode", w.cfg.Consumer.DryRun).Info("`dry-run` enabled. jobs won't be deleted or released")
+	}
+
+	w.state.listen()
+	defer w.state.stop()
+
+	if cfg.Consumer.CircuitBreaker != nil {
+		cfg.Consumer.CircuitBreaker.log = w.log
+	}
 
 	go func() {
 		<-ctx.Done()
 		if ctx.Err() != nil {
+			w.state.drain()
 			w.log.Info("shutting down safely...this could take a while")
 		}
 	}()
 
 	// Start those consumers up
-	var wg sync.WaitGroup
-	wg.Add(w.cfg.Consumer.Count)
+	receiveCtx, stopReceiving := context.WithCancel(ctx)
+	defer stopReceiving()
+	handlerCtx, cancelHandlers := context.WithCancel(ctx)
+	defer cancelHandlers()
+	if w.dryRun() {
+		handlerCtx = context.WithValue(handlerCtx, dryRunKey{}, true)
+	}
 
-	for i := 0; i < w.cfg.Consumer.Count; i++ {
-		go func() {
-			defer wg.Done()
+	run := &listenRun{
+		ctx:            receiveCtx,
+		handle:         handle,
+		handlerCtx:     handlerCtx,
+		cancelHandlers: cancelHandlers,
+		stopReceiving:  stopReceiving,
+	}
 
-			w.receive(ctx, handler)
-		}()
+	w.control.mu.Lock()
+	w.control.listen = run
+	for id := 0; id < w.cfg.Consumer.Count; id++ {
+		w.startConsumer(id)
 	}
+	w.control.mu.Unlock()
 
-	wg.Wait()
+	run.wg.Wait()
+
+	w.control.mu.Lock()
+	w.control.listen = nil
+	w.control.mu.Unlock()
 }
 
-func (w *sqsWorker) receive(ctx context.Context, handler HandlerFunc) {
+// messageHandler processes the messages from a single receive, or a gathered batch.
+type messageHandler func(ctx context.Context, consumer int, msgs []sqs.Message)
+
+func (w *sqsWorker) receive(run *listenRun, id int) {
+	ctx := run.ctx
+
 	for {
 		select {
 		case <-ctx.Done():
@@ -113,41 +219,31 @@ func (w *sqsWorker) receive(ctx context.Context, handler HandlerFunc) {
 			return
 
 		default:
-			w.log.WithFields(logrus.Fields{
-				"wait": time.Duration(w.cfg.Consumer.RetrievalWaitTimeSeconds) * time.Second,
-			}).Debug("calling receive.")
-
-			// Poll SQS for new messages
-			// Currently not setting req.SetContext() as it can leave messages dangling with default visibility timeout
-			req := w.svc.ReceiveMessageRequest(&sqs.ReceiveMessageInput{
-				AttributeNames: []sqs.QueueAttributeName{
-					sqs.QueueAttributeName(sqs.MessageSystemAttributeNameApproximateReceiveCount), // j.Tries()
-				},
-				MaxNumberOfMessages: aws.Int64(w.cfg.Consumer.RetrievalMaxNumberOfMessages),
-				MessageAttributeNames: []string{
-					"All",
-				},
-				QueueUrl:          aws.String(w.cfg.QueueURL),
-				VisibilityTimeout: aws.Int64(w.cfg.Consumer.RetrievalVisibilityTimeout),
-				WaitTimeSeconds:   aws.Int64(w.cfg.Consumer.RetrievalWaitTimeSeconds),
-			})
-
-			start := time.Now()
-			resp, err := req.Send()
-			sqsReceiveTimer.Set(time.Since(start).Seconds())
+			if !w.checkIn(ctx, id) {
+				return
+			}
 
-			if err != nil {
-				receiveErrorTotal.Inc()
-
-				if awsErr, ok := err.(awserr.Error); ok {
-					w.log.WithFields(logrus.Fields{
-						"code":  awsErr.Code(),
-						"error": awsErr.Message(),
-					}).Error("error receiving sqs message")
-				} else {
-					w.log.WithError(err).Error("error receiving sqs message")
+			maxMessages := w.cfg.Consumer.RetrievalMaxNumberOfMessages
+			breaker := w.cfg.Consumer.CircuitBreaker
+			probe := false
+			if breaker != nil {
+				var ok bool
+				if probe, ok = w.waitForCircuit(ctx, id, breaker); !ok {
+					return
+				}
+				// Half-open only lets a single message through
+				if probe {
+					maxMessages = 1
 				}
+			}
+
+			msgs, err := w.receiveMessages(id, maxMessages, w.cfg.Consumer.RetrievalWaitTimeSeconds)
+
+			if probe {
+				breaker.probeReceived(len(msgs))
+			}
 
+			if err != nil {
 				if w.cfg.Consumer.RunOnce {
 					w.log.Debug("`run-once` complete")
 					return
@@ -155,24 +251,43 @@ func (w *sqsWorker) receive(ctx context.Context, handler HandlerFunc) {
 
 				// Backoff trying to re-connect
 				w.log.WithField("sleep", w.cfg.Consumer.RetrievalErrWait).Debug("sleeping before retrying")
-				time.Sleep(w.cfg.Consumer.RetrievalErrWait)
+				w.state.consumer(id, ConsumerSleeping)
+				select {
+				case <-ctx.Done():
+				case <-time.After(w.cfg.Consumer.RetrievalErrWait):
+				}
 
 				continue
 			}
 
-			// Handle response
-			receivedTotal.Add(float64(len(resp.Messages)))
+			// Shutdown came in during the receive, let another worker have them
+			if len(msgs) > 0 && run.stopping() {
+				w.log.WithField("count", len(msgs)).Debug("shutting down. releasing received messages")
+				releaseMessages(w.svc, w.cfg.QueueURL, w.log, receiptHandles(msgs))
+				return
+			}
 
-			if len(resp.Messages) == 0 {
+			if len(msgs) > 0 && !probe && !w.cfg.Consumer.RunOnce && w.cfg.Consumer.BatchWindow > 0 {
+				msgs = w.gather(ctx, id, msgs)
+			}
+
+			// Handle response
+			if len(msgs) == 0 {
 				w.log.Debug("no messages on attempt. trying again.")
 			} else {
-				w.log.WithField("count", len(resp.Messages)).Debug("messages retrieved")
+				w.log.WithField("count", len(msgs)).Debug("messages retrieved")
 				// Pass messages to job handler
-				w.handleResponse(ctx, resp.Messages, handler)
+				w.state.consumer(id, ConsumerHandling)
+				run.handle(run.handlerCtx, id, msgs)
+			}
+
+			if probe {
+				breaker.probeHandled()
 			}
 
 			if w.cfg.Consumer.RunSlowly > time.Duration(0) {
 				w.log.WithField("sleep", w.cfg.Consumer.RunSlowly).Debug("`run-slowly` kicking in")
+				w.state.consumer(id, ConsumerSleeping)
 				time.Sleep(w.cfg.Consumer.RunSlowly)
 			}
 
@@ -184,25 +299,134 @@ func (w *sqsWorker) receive(ctx context.Context, handler HandlerFunc) {
 	}
 }
 
-func (w *sqsWorker) handleResponse(ctx context.Context, msgs []sqs.Message, handler HandlerFunc) {
+// attributeNames to request, always including those Goller relies on.
+func (w *sqsWorker) attributeNames() []sqs.QueueAttributeName {
+	names := append([]string{}, w.cfg.Consumer.RetrievalAttributeNames...)
+	names = append(names,
+		string(sqs.MessageSystemAttributeNameApproximateReceiveCount), // j.Tries()
+		AWSTraceHeaderAttribute,                                      // tracing
+		string(sqs.MessageSystemAttributeNameSentTimestamp),          // MaxMessageAge & copies, see sendCopy(...)
+		string(sqs.MessageSystemAttributeNameMessageGroupId),         // FIFO copies
+		string(sqs.MessageSystemAttributeNameMessageDeduplicationId), // FIFO copies
+	)
+
+	attrs := make([]sqs.QueueAttributeName, 0, len(names))
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		if name == string(sqs.QueueAttributeNameAll) {
+			return []sqs.QueueAttributeName{sqs.QueueAttributeNameAll}
+		}
+		if !seen[name] {
+			seen[name] = true
+			attrs = append(attrs, sqs.QueueAttributeName(name))
+		}
+	}
+
+	return attrs
+}
+
+// receiveMessages makes a single receive request against SQS.
+func (w *sqsWorker) receiveMessages(id int, maxMessages, waitSeconds int64) ([]sqs.Message, error) {
+	w.log.WithFields(Fields{
+		"wait": time.Duration(waitSeconds) * time.Second,
+	}).Debug("calling receive.")
+
+	// Poll SQS for new messages
+	// Currently not setting req.SetContext() as it can leave messages dangling with default visibility timeout
+	req := w.svc.ReceiveMessageRequest(&sqs.ReceiveMessageInput{
+		AttributeNames:      w.attributeNames(),
+		MaxNumberOfMessages: aws.Int64(maxMessages),
+		MessageAttributeNames: []string{
+			"All",
+		},
+		QueueUrl:          aws.String(w.cfg.QueueURL),
+		VisibilityTimeout: aws.Int64(w.cfg.Consumer.RetrievalVisibilityTimeout),
+		WaitTimeSeconds:   aws.Int64(waitSeconds),
+	})
+
+	w.state.receiveAttempt(id)
+
+	start := time.Now()
+	resp, err := req.Send()
+	sqsReceiveTimer.Set(time.Since(start).Seconds())
+
+	w.state.receiveResult(id, err)
+
+	if err != nil {
+		receiveErrorTotal.Inc()
+
+		if awsErr, ok := err.(awserr.Error); ok {
+			w.log.WithFields(Fields{
+				"code":  awsErr.Code(),
+				"error": awsErr.Message(),
+			}).Error("error receiving sqs message")
+		} else {
+			w.log.WithError(err).Error("error receiving sqs message")
+		}
+
+		return nil, err
+	}
+
+	receivedTotal.Add(float64(len(resp.Messages)))
+
+	return resp.Messages, nil
+}
+
+func (w *sqsWorker) handleResponse(ctx context.Context, consumer int, msgs []sqs.Message, handler HandlerFunc) {
 	// Call the handler for each of the messages
 	var wg sync.WaitGroup
 	wg.Add(len(msgs))
 
 	for _, msg := range msgs {
-		j := NewJob(w.cfg, w.log, msg, w.svc)
+		j := w.newJob(msg)
 
-		logger := w.log.WithField("jid", j.ID())
+		logger := w.jobLogger(j)
 		logger.Debug("processing job")
 
-		go func(j Job) {
+		go func(j Job, msg sqs.Message) {
 			defer wg.Done()
+
+			inFlight := w.state.startJob(consumer, j, aws.StringValue(msg.ReceiptHandle))
+			defer w.state.finishJob(inFlight)
+
+			if w.dryRun() {
+				defer w.finishDryRun(msg)
+			}
+
+			ctx, span := startConsumerSpan(ctx, w.tracer, w.cfg.QueueURL, msg)
+			defer span.End()
+
+			ctx = ContextWithLogger(ctx, logger)
+
+			if w.expire(j, logger) || w.notDue(j, msg, logger) {
+				return
+			}
+
+			// Circuit breaker decides whether the handler gets called
+			breaker := w.cfg.Consumer.CircuitBreaker
+			probe := false
+			if breaker != nil {
+				var ok bool
+				if ok, probe = breaker.allow(); !ok {
+					w.requeue(j, msg, breaker.retryIn(), logger)
+					return
+				}
+			}
+
+			processed, errored := w.outcomeCounters()
+
 			defer func() {
 				if r := recover(); r != nil {
 					err := fmt.Errorf("panic: %s", r)
 					logger.WithError(err).Error("job handler paniced")
+					span.RecordError(err)
+					span.SetStatus(codes.Error, err.Error())
 					jobPanicTotal.Inc()
-					jobErrorTotal.Inc()
+					errored.Inc()
+
+					if breaker != nil {
+						breaker.record(probe, err)
+					}
 				}
 			}()
 
@@ -210,20 +434,56 @@ func (w *sqsWorker) handleResponse(ctx context.Context, msgs []sqs.Message, hand
 			err := handler(ctx, j)
 			jobHandlerTimer.Set(time.Since(start).Seconds())
 
+			if breaker != nil {
+				breaker.record(probe, err)
+			}
+
 			if err != nil {
 				logger.WithError(err).Error("handler errored")
-				jobErrorTotal.Inc()
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+				errored.Inc()
 			}
 
 			if !j.Handled() {
 				logger.WithError(err).Error("job not handled")
-				jobErrorTotal.Inc()
+				errored.Inc()
 			} else {
 				logger.Debug("job processed successfully")
-				jobProcessedTotal.Inc()
+				processed.Inc()
 			}
-		}(j)
+		}(j, msg)
 	}
 
 	wg.Wait()
 }
+
+// expire deletes the job without handling it when it's older than MaxMessageAge.
+func (w *sqsWorker) expire(j Job, logger Logger) bool {
+	maxAge := w.cfg.Consumer.MaxMessageAge
+	if maxAge <= 0 || j.Age() <= maxAge {
+		return false
+	}
+
+	logger.WithField("age", j.Age()).Info("job expired. deleting")
+	jobExpiredTotal.Inc()
+
+	if err := j.Delete(); err != nil {
+		logger.WithError(err).Error("unable to delete expired job")
+	}
+
+	return true
+}
+
+// jobLogger enriches the logger with details about the job.
+func (w *sqsWorker) jobLogger(j Job) Logger {
+	fields := Fields{
+		"jid":   j.ID(),
+		"queue": queueName(w.cfg.QueueURL),
+	}
+	if tries, err := j.Tries(); err == nil {
+		fields["tries"] = tries
+	}
+
+	return w.log.WithFields(fields)
+}
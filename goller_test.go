@@ -4,6 +4,9 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"testing"
@@ -15,6 +18,7 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/sqs/sqsiface"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/rcrowe/goller"
+	"github.com/rcrowe/goller/logrusadapter"
 	"github.com/sirupsen/logrus"
 )
 
@@ -118,7 +122,7 @@ func TestListenCompletesRunSlowly(t *testing.T) {
 	cfg.Consumer.RunOnce = true
 
 	w := goller.NewFromConfig(&receiveSQSClient{}, cfg)
-	w.WithLogger(logger)
+	w.WithLogger(logrusadapter.New(logger))
 	w.Listen(context.Background(), func(ctx context.Context, j goller.Job) error {
 		return nil
 	})
@@ -149,7 +153,7 @@ func TestListenLogsContextClosed(t *testing.T) {
 	cfg := goller.NewDefaultConfig("", 0)
 
 	w := goller.NewFromConfig(sqs.New(aws.Config{}), cfg)
-	w.WithLogger(logger)
+	w.WithLogger(logrusadapter.New(logger))
 	w.Listen(ctx, func(ctx context.Context, j goller.Job) error {
 		return nil
 	})
@@ -281,27 +285,27 @@ func TestUnhandledJob(t *testing.T) {
 		},
 	}
 
+	before := counterValue(t, "goller_job_error_total")
 	listenOnce(svc, nil, nil)
 
+	if metricValue := counterValue(t, "goller_job_error_total") - before; metricValue != 1 {
+		t.Errorf("expected error metric to be incremented but got `%f`", metricValue)
+	}
+}
+
+func counterValue(t *testing.T, name string) float64 {
 	metricFamilies, err := prometheus.DefaultGatherer.Gather()
 	if err != nil {
-		t.Fail()
-		return
+		t.Fatal(err)
 	}
 
-	expectedMetric := "goller_job_error_total"
-	var metricValue float64
 	for _, metricFamily := range metricFamilies {
-		if *metricFamily.Name != expectedMetric {
-			continue
+		if metricFamily.GetName() == name {
+			return metricFamily.Metric[0].Counter.GetValue()
 		}
-
-		metricValue = metricFamily.Metric[0].Counter.GetValue()
 	}
 
-	if metricValue != 1 {
-		t.Errorf("expected error metric to be incremented but got `%f`", metricValue)
-	}
+	return 0
 }
 
 func listenOnce(svc sqsiface.SQSAPI, logger *logrus.Logger, cfg *goller.Config) {
@@ -317,8 +321,68 @@ func listenOnce(svc sqsiface.SQSAPI, logger *logrus.Logger, cfg *goller.Config)
 	}
 
 	w := goller.NewFromConfig(svc, cfg)
-	w.WithLogger(logger)
+	w.WithLogger(logrusadapter.New(logger))
 	w.Listen(context.Background(), func(ctx context.Context, j goller.Job) error {
 		return nil
 	})
 }
+
+func TestRetrievalAttributeNames(t *testing.T) {
+	for _, tc := range []struct {
+		names    []string
+		maxAge   time.Duration
+		expected []sqs.QueueAttributeName
+	}{
+		{[]string{"All"}, 0, []sqs.QueueAttributeName{"All"}},
+		{nil, 0, []sqs.QueueAttributeName{"ApproximateReceiveCount", "AWSTraceHeader", "SentTimestamp", "MessageGroupId", "MessageDeduplicationId"}},
+		{[]string{"MessageGroupId", "ApproximateReceiveCount"}, time.Hour, []sqs.QueueAttributeName{"MessageGroupId", "ApproximateReceiveCount", "AWSTraceHeader", "SentTimestamp", "MessageDeduplicationId"}},
+	} {
+		svc := newQueueSQSClient(0)
+
+		cfg := goller.NewDefaultConfig("foo", 1)
+		cfg.RunOnce()
+		cfg.Consumer.RetrievalAttributeNames = tc.names
+		cfg.Consumer.MaxMessageAge = tc.maxAge
+
+		goller.NewFromConfig(svc, cfg).Listen(context.Background(), func(ctx context.Context, j goller.Job) error {
+			return nil
+		})
+
+		if actual := svc.Receives()[0].AttributeNames; !reflect.DeepEqual(actual, tc.expected) {
+			t.Errorf("expected attribute names `%v` but got `%v`", tc.expected, actual)
+		}
+	}
+}
+
+func TestMaxMessageAge(t *testing.T) {
+	svc := newQueueSQSClient(2)
+	for i, sent := range []time.Time{time.Now().Add(-2 * time.Hour), time.Now()} {
+		svc.messages[i].Attributes = map[string]string{
+			"SentTimestamp": strconv.FormatInt(sent.UnixNano()/int64(time.Millisecond), 10),
+		}
+	}
+
+	cfg := goller.NewDefaultConfig("foo", 1)
+	cfg.RunOnce()
+	cfg.Consumer.RetrievalMaxNumberOfMessages = 2
+	cfg.Consumer.MaxMessageAge = time.Hour
+
+	var lock sync.Mutex
+	handled := []string{}
+	goller.NewFromConfig(svc, cfg).Listen(context.Background(), func(ctx context.Context, j goller.Job) error {
+		lock.Lock()
+		handled = append(handled, j.ID())
+		lock.Unlock()
+
+		return j.Delete()
+	})
+
+	if len(handled) != 1 || handled[0] != "msg-1" {
+		t.Errorf("expected only the fresh job to be handled but got `%v`", handled)
+	}
+
+	sort.Strings(svc.deleted)
+	if len(svc.deleted) != 2 || svc.deleted[0] != "handle-0" {
+		t.Errorf("expected stale job to be deleted but got `%v`", svc.deleted)
+	}
+}
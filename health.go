@@ -0,0 +1,286 @@
+package goller
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Consumer states reported by Status.
+const (
+	ConsumerStarting    = "starting"
+	ConsumerReceiving   = "receiving"
+	ConsumerHandling    = "handling"
+	ConsumerSleeping    = "sleeping"
+	ConsumerPaused      = "paused"
+	ConsumerCircuitOpen = "circuit-open"
+	ConsumerStopped     = "stopped"
+)
+
+// Status is a point in time snapshot of what the worker is up to.
+type Status struct {
+	// Live is true while every consumer has recently attempted to receive from SQS,
+	// or is starting or busy handling jobs. Workers yet to Listen are live, but not ready;
+	// once Listen has returned they're neither.
+	Live bool `json:"live"`
+
+	// Ready is true when the last receive of every consumer succeeded and the worker is not draining.
+	Ready bool `json:"ready"`
+
+	// Draining is true once the listen context is done and the worker is finishing up in-flight jobs.
+	Draining bool `json:"draining"`
+
+	// Paused is true while consumers have been told to stop receiving.
+	Paused bool `json:"paused"`
+
+	// State of the circuit breaker, if configured.
+	Circuit CircuitState `json:"circuit,omitempty"`
+
+	Consumers []ConsumerStatus `json:"consumers"`
+	InFlight  []InFlightJob    `json:"in_flight"`
+	Config    Config           `json:"config"`
+}
+
+// ConsumerStatus is the state of a single consumer.
+type ConsumerStatus struct {
+	ID                 int       `json:"id"`
+	State              string    `json:"state"`
+	LastReceiveAttempt time.Time `json:"last_receive_attempt"`
+	LastReceiveSuccess time.Time `json:"last_receive_success"`
+	LastReceiveError   string    `json:"last_receive_error,omitempty"`
+}
+
+// InFlightJob is a job currently being processed by a handler.
+type InFlightJob struct {
+	ID       string        `json:"id"`
+	Consumer int           `json:"consumer"`
+	Started  time.Time     `json:"started"`
+	Age      time.Duration `json:"age"`
+
+	receiptHandle string
+	job           Job
+}
+
+// NewHealthHandler exposes the health of the worker over HTTP.
+//
+//	/healthz - liveness; 200 when live, otherwise 503
+//	/readyz  - readiness; 200 when ready, otherwise 503
+//	/status  - JSON encoded Status
+func NewHealthHandler(w Worker) http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/healthz", func(rw http.ResponseWriter, r *http.Request) {
+		writeProbe(rw, w.Status().Live)
+	})
+
+	mux.HandleFunc("/readyz", func(rw http.ResponseWriter, r *http.Request) {
+		writeProbe(rw, w.Status().Ready)
+	})
+
+	mux.HandleFunc("/status", func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(rw).Encode(w.Status()); err != nil {
+			http.Error(rw, err.Error(), http.StatusInternalServerError)
+		}
+	})
+
+	return mux
+}
+
+func writeProbe(rw http.ResponseWriter, ok bool) {
+	if !ok {
+		http.Error(rw, "not ok", http.StatusServiceUnavailable)
+		return
+	}
+
+	rw.WriteHeader(http.StatusOK)
+	rw.Write([]byte("ok"))
+}
+
+// workerState tracks what each consumer is doing so that it can be reported on.
+type workerState struct {
+	mu        sync.Mutex
+	listening bool
+	stopped   bool
+	draining  bool
+	consumers map[int]*ConsumerStatus
+	nextJob   int64
+	inFlight  map[int64]*InFlightJob
+}
+
+func newWorkerState() *workerState {
+	return &workerState{
+		consumers: make(map[int]*ConsumerStatus),
+		inFlight:  make(map[int64]*InFlightJob),
+	}
+}
+
+func (s *workerState) listen() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.listening = true
+	s.stopped = false
+	s.draining = false
+	s.consumers = make(map[int]*ConsumerStatus)
+}
+
+func (s *workerState) stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.listening = false
+	s.stopped = true
+	for _, c := range s.consumers {
+		c.State = ConsumerStopped
+	}
+}
+
+func (s *workerState) drain() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.draining = true
+}
+
+func (s *workerState) addConsumer(id int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.consumers[id] = &ConsumerStatus{ID: id, State: ConsumerStarting}
+}
+
+func (s *workerState) removeConsumer(id int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.consumers, id)
+}
+
+func (s *workerState) consumer(id int, state string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if c, ok := s.consumers[id]; ok {
+		c.State = state
+	}
+}
+
+func (s *workerState) receiveAttempt(id int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if c, ok := s.consumers[id]; ok {
+		c.State = ConsumerReceiving
+		c.LastReceiveAttempt = time.Now()
+	}
+}
+
+func (s *workerState) receiveResult(id int, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.consumers[id]
+	if !ok {
+		return
+	}
+
+	if err != nil {
+		c.LastReceiveError = err.Error()
+		return
+	}
+
+	c.LastReceiveError = ""
+	c.LastReceiveSuccess = time.Now()
+}
+
+func (s *workerState) startJob(consumer int, j Job, receiptHandle string) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nextJob++
+	s.inFlight[s.nextJob] = &InFlightJob{
+		ID:            j.ID(),
+		Consumer:      consumer,
+		Started:       time.Now(),
+		receiptHandle: receiptHandle,
+		job:           j,
+	}
+
+	return s.nextJob
+}
+
+func (s *workerState) finishJob(token int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.inFlight, token)
+}
+
+// abandon marks every in-flight job handled, so their handlers can no longer acknowledge them.
+// Returns the receipt handles of the jobs that hadn't already been acknowledged.
+func (s *workerState) abandon() []string {
+	s.mu.Lock()
+	inFlight := make([]*InFlightJob, 0, len(s.inFlight))
+	for _, j := range s.inFlight {
+		inFlight = append(inFlight, j)
+	}
+	s.mu.Unlock()
+
+	// Outside of the lock, as abandoning waits for any write to SQS the handler is making
+	handles := make([]string, 0, len(inFlight))
+	for _, j := range inFlight {
+		if job, ok := j.job.(interface{ abandon() bool }); ok && !job.abandon() {
+			continue
+		}
+		handles = append(handles, j.receiptHandle)
+	}
+
+	return handles
+}
+
+// status builds a snapshot, a consumer is classed as dead when it hasn't
+// attempted to receive within the given threshold.
+func (s *workerState) status(threshold time.Duration) Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	status := Status{
+		Live:      !s.stopped,
+		Ready:     s.listening && !s.draining,
+		Draining:  s.draining,
+		Consumers: make([]ConsumerStatus, 0, len(s.consumers)),
+		InFlight:  make([]InFlightJob, 0, len(s.inFlight)),
+	}
+
+	for _, c := range s.consumers {
+		status.Consumers = append(status.Consumers, *c)
+
+		// Starting consumers haven't had the chance to receive yet
+		busy := c.State == ConsumerStarting || c.State == ConsumerHandling || c.State == ConsumerPaused || c.State == ConsumerCircuitOpen
+		if !busy && now.Sub(c.LastReceiveAttempt) > threshold {
+			status.Live = false
+		}
+		if c.LastReceiveSuccess.IsZero() || c.LastReceiveError != "" {
+			status.Ready = false
+		}
+	}
+
+	sort.Slice(status.Consumers, func(i, k int) bool {
+		return status.Consumers[i].ID < status.Consumers[k].ID
+	})
+
+	for _, j := range s.inFlight {
+		job := *j
+		job.Age = now.Sub(job.Started)
+		status.InFlight = append(status.InFlight, job)
+	}
+	sort.Slice(status.InFlight, func(i, k int) bool {
+		return status.InFlight[i].Started.Before(status.InFlight[k].Started)
+	})
+
+	return status
+}
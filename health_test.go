@@ -0,0 +1,187 @@
+package goller_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/rcrowe/goller"
+)
+
+func probe(h http.Handler, path string) int {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	return rec.Code
+}
+
+func waitFor(t *testing.T, condition func() bool) {
+	deadline := time.Now().Add(2 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for condition")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestHealthBeforeListen(t *testing.T) {
+	w := goller.NewFromConfig(&receiveSQSClient{}, goller.NewDefaultConfig("foo", 1))
+	h := goller.NewHealthHandler(w)
+
+	// Not stuck, just not started yet
+	if code := probe(h, "/healthz"); code != http.StatusOK {
+		t.Errorf("expected liveness to be `200` but got `%d`", code)
+	}
+	if code := probe(h, "/readyz"); code != http.StatusServiceUnavailable {
+		t.Errorf("expected readiness to be `503` but got `%d`", code)
+	}
+}
+
+func TestHealthStarting(t *testing.T) {
+	svc := &gatedReceiveSQSClient{
+		queueSQSClient: newQueueSQSClient(0),
+		receiving:      make(chan struct{}),
+		gate:           make(chan struct{}),
+	}
+	w := goller.NewFromConfig(svc, goller.NewDefaultConfig("foo", 1))
+	h := goller.NewHealthHandler(w)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		w.Listen(ctx, func(ctx context.Context, j goller.Job) error {
+			return nil
+		})
+	}()
+
+	// Consumer is yet to make its first receive
+	<-svc.receiving
+	if state := w.Status().Consumers[0].State; state != goller.ConsumerStarting {
+		t.Fatalf("expected consumer to be starting but got `%s`", state)
+	}
+
+	if code := probe(h, "/healthz"); code != http.StatusOK {
+		t.Errorf("expected liveness to be `200` but got `%d`", code)
+	}
+	if code := probe(h, "/readyz"); code != http.StatusServiceUnavailable {
+		t.Errorf("expected readiness to be `503` but got `%d`", code)
+	}
+
+	cancel()
+	close(svc.gate)
+	<-done
+}
+
+func TestHealthWhileHandling(t *testing.T) {
+	svc := &receiveSQSClient{
+		Output: sqs.ReceiveMessageOutput{
+			Messages: []sqs.Message{
+				{MessageId: aws.String("abc123")},
+			},
+		},
+	}
+
+	w := goller.NewFromConfig(svc, goller.NewDefaultConfig("foo", 1))
+	h := goller.NewHealthHandler(w)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	handling := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		w.Listen(ctx, func(ctx context.Context, j goller.Job) error {
+			close(handling)
+			<-release
+			return nil
+		})
+	}()
+	<-handling
+
+	if code := probe(h, "/healthz"); code != http.StatusOK {
+		t.Errorf("expected liveness to be `200` but got `%d`", code)
+	}
+	if code := probe(h, "/readyz"); code != http.StatusOK {
+		t.Errorf("expected readiness to be `200` but got `%d`", code)
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/status", nil))
+
+	var status goller.Status
+	if err := json.NewDecoder(rec.Body).Decode(&status); err != nil {
+		t.Fatalf("unable to decode status: %s", err)
+	}
+	if len(status.Consumers) != 1 || status.Consumers[0].State != goller.ConsumerHandling {
+		t.Errorf("expected a single handling consumer but got `%+v`", status.Consumers)
+	}
+	if len(status.InFlight) != 1 || status.InFlight[0].ID != "abc123" {
+		t.Errorf("expected job `abc123` to be in-flight but got `%+v`", status.InFlight)
+	}
+	if status.Config.QueueURL != "foo" {
+		t.Errorf("expected config queue URL `foo` but got `%s`", status.Config.QueueURL)
+	}
+
+	// Draining is no longer ready
+	cancel()
+	waitFor(t, func() bool { return w.Status().Draining })
+
+	if code := probe(h, "/readyz"); code != http.StatusServiceUnavailable {
+		t.Errorf("expected readiness to be `503` when draining but got `%d`", code)
+	}
+
+	close(release)
+	<-done
+
+	if len(w.Status().InFlight) != 0 {
+		t.Errorf("expected no in-flight jobs but got `%+v`", w.Status().InFlight)
+	}
+	if code := probe(h, "/healthz"); code != http.StatusServiceUnavailable {
+		t.Errorf("expected liveness to be `503` once stopped but got `%d`", code)
+	}
+}
+
+func TestHealthReceiveErrorNotReady(t *testing.T) {
+	svc := &receiveErroredSQSClient{err: errors.New("one two kayak")}
+
+	cfg := goller.NewDefaultConfig("foo", 1)
+	cfg.Consumer.RetrievalErrWait = 5 * time.Millisecond
+
+	w := goller.NewFromConfig(svc, cfg)
+	h := goller.NewHealthHandler(w)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		w.Listen(ctx, func(ctx context.Context, j goller.Job) error {
+			return nil
+		})
+	}()
+
+	waitFor(t, func() bool {
+		status := w.Status()
+		return len(status.Consumers) == 1 && status.Consumers[0].LastReceiveError != ""
+	})
+
+	if code := probe(h, "/healthz"); code != http.StatusOK {
+		t.Errorf("expected liveness to be `200` but got `%d`", code)
+	}
+	if code := probe(h, "/readyz"); code != http.StatusServiceUnavailable {
+		t.Errorf("expected readiness to be `503` but got `%d`", code)
+	}
+	if msg := w.Status().Consumers[0].LastReceiveError; msg != "one two kayak" {
+		t.Errorf("expected last receive error `one two kayak` but got `%s`", msg)
+	}
+
+	cancel()
+	<-done
+}
@@ -0,0 +1,463 @@
+package goller
+
+import (
+	"bufio"
+	"container/list"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"math"
+	"os"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrJobCompleted is returned by an IdempotencyStore when the job has already been processed.
+	ErrJobCompleted = errors.New("job already completed")
+
+	// ErrJobInProgress is returned by an IdempotencyStore when another consumer holds the lease on the job.
+	ErrJobInProgress = errors.New("job in progress")
+
+	// ErrLeaseLost is returned by an IdempotencyStore when completing a job whose lease
+	// expired & has since been taken by another consumer.
+	ErrLeaseLost = errors.New("idempotency lease lost")
+)
+
+// IdempotencyStore records which jobs have been processed, so that
+// duplicate deliveries of the same message can be skipped.
+type IdempotencyStore interface {
+	// Acquire takes a processing lease on the key, returning a token that identifies the holder.
+	// Returns ErrJobCompleted or ErrJobInProgress when the lease can't be taken.
+	Acquire(ctx context.Context, key string, lease time.Duration) (token string, err error)
+
+	// Complete marks the key as processed for the given TTL.
+	// Returns ErrLeaseLost when the lease is now held by another token.
+	Complete(ctx context.Context, key, token string, ttl time.Duration) error
+
+	// Release gives up the processing lease so the job can be tried again.
+	// Does nothing unless the lease is still held by the token.
+	Release(ctx context.Context, key, token string) error
+}
+
+// IdempotencyConfig configures the idempotency middleware.
+type IdempotencyConfig struct {
+	// Where completed jobs & leases are kept.
+	Store IdempotencyStore
+
+	// What makes a job unique. Jobs with an empty key are always handled.
+	// Default JobIDKey, see also AttributeKey(...) & BodyHashKey.
+	Key func(j Job) string
+
+	// How long a consumer holds on to a job before another can pick it up.
+	// Should be longer than the visibility timeout, so a redelivery of a slow job is seen as a duplicate.
+	// Default 15 minutes, longer than the default RetrievalVisibilityTimeout.
+	Lease time.Duration
+
+	// How long completed jobs are remembered.
+	// Default 24 hours.
+	TTL time.Duration
+}
+
+// JobIDKey dedupes on the SQS message ID, which is the same across redeliveries.
+func JobIDKey(j Job) string {
+	return j.ID()
+}
+
+// BodyHashKey dedupes on a SHA-256 hash of the job body,
+// for when the same payload is sent more than once.
+func BodyHashKey(j Job) string {
+	body, err := j.Body()
+	if err != nil {
+		return ""
+	}
+
+	sum := sha256.Sum256([]byte(body))
+	return hex.EncodeToString(sum[:])
+}
+
+// NewIdempotencyMiddleware skips jobs that have already been completed.
+//
+// A job is only recorded as completed once the handler returns without error
+// and the job has been deleted. Duplicates of a completed job are deleted,
+// duplicates of a job still being processed are released until the lease expires.
+// Dry runs skip the store, see IsDryRun(...).
+func NewIdempotencyMiddleware(cfg IdempotencyConfig) Middleware {
+	if cfg.Key == nil {
+		cfg.Key = JobIDKey
+	}
+	if cfg.Lease <= 0 {
+		cfg.Lease = 15 * time.Minute
+	}
+	if cfg.TTL <= 0 {
+		cfg.TTL = 24 * time.Hour
+	}
+
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, j Job) error {
+			key := cfg.Key(j)
+			if key == "" {
+				return next(ctx, j)
+			}
+
+			logger := LoggerFromContext(ctx).WithField("idempotency_key", key)
+
+			// Live workers would see the key as completed & drop the real message
+			if IsDryRun(ctx) {
+				logger.Debug("dry-run. idempotency store not used")
+				return next(ctx, j)
+			}
+
+			token, err := cfg.Store.Acquire(ctx, key, cfg.Lease)
+			switch err {
+			case nil:
+			case ErrJobCompleted:
+				logger.Debug("job already completed. deleting duplicate")
+				idempotencyDuplicateTotal.Inc()
+
+				return j.Delete()
+			case ErrJobInProgress:
+				logger.Debug("job in progress. releasing duplicate")
+				idempotencyDuplicateTotal.Inc()
+
+				// SQS maximum visibility timeout
+				return j.Release(int64(math.Min(math.Ceil(cfg.Lease.Seconds()), 43200)))
+			default:
+				idempotencyStoreErrorTotal.Inc()
+				logger.WithError(err).Error("unable to acquire idempotency lease")
+
+				return err
+			}
+
+			tracked := &deleteTrackingJob{Job: j}
+			completed := false
+
+			// Give up the lease on error or panic, so a redelivery can try again
+			defer func() {
+				if completed {
+					return
+				}
+				if err := cfg.Store.Release(ctx, key, token); err != nil {
+					idempotencyStoreErrorTotal.Inc()
+					logger.WithError(err).Error("unable to release idempotency lease")
+				}
+			}()
+
+			if err := next(ctx, tracked); err != nil || !tracked.wasDeleted() {
+				return err
+			}
+
+			if err := cfg.Store.Complete(ctx, key, token, cfg.TTL); err != nil {
+				idempotencyStoreErrorTotal.Inc()
+				logger.WithError(err).Error("unable to mark job as completed")
+				return nil
+			}
+
+			completed = true
+			return nil
+		}
+	}
+}
+
+// deleteTrackingJob records whether the handler successfully deleted the job.
+type deleteTrackingJob struct {
+	Job
+
+	mu      sync.Mutex
+	deleted bool
+}
+
+func (j *deleteTrackingJob) Delete() error {
+	if err := j.Job.Delete(); err != nil {
+		return err
+	}
+
+	j.mu.Lock()
+	j.deleted = true
+	j.mu.Unlock()
+
+	return nil
+}
+
+func (j *deleteTrackingJob) wasDeleted() bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return j.deleted
+}
+
+// NewMemoryIdempotencyStore keeps up to size keys in memory, evicting the least recently used.
+// Only dedupes within a single worker; use a shared store across multiple workers.
+func NewMemoryIdempotencyStore(size int) IdempotencyStore {
+	return newMemoryIdempotencyStore(size)
+}
+
+type memoryIdempotencyStore struct {
+	mu    sync.Mutex
+	size  int
+	items map[string]*list.Element
+	order *list.List
+}
+
+type idempotencyEntry struct {
+	Key       string    `json:"key"`
+	Token     string    `json:"-"`
+	Completed bool      `json:"-"`
+	Expires   time.Time `json:"expires"`
+}
+
+// newLeaseToken is a random token identifying who holds a lease.
+func newLeaseToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+// newMemoryIdempotencyStore with a size below 1 is unbounded.
+func newMemoryIdempotencyStore(size int) *memoryIdempotencyStore {
+	return &memoryIdempotencyStore{
+		size:  size,
+		items: make(map[string]*list.Element),
+		order: list.New(),
+	}
+}
+
+func (s *memoryIdempotencyStore) Acquire(ctx context.Context, key string, lease time.Duration) (string, error) {
+	token, err := newLeaseToken()
+	if err != nil {
+		return "", err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if e := s.get(key, now); e != nil {
+		if e.Completed {
+			return "", ErrJobCompleted
+		}
+		return "", ErrJobInProgress
+	}
+
+	s.put(idempotencyEntry{Key: key, Token: token, Expires: now.Add(lease)})
+	return token, nil
+}
+
+func (s *memoryIdempotencyStore) Complete(ctx context.Context, key, token string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if err := s.checkLease(key, token, now); err != nil {
+		return err
+	}
+
+	s.put(idempotencyEntry{Key: key, Completed: true, Expires: now.Add(ttl)})
+	return nil
+}
+
+func (s *memoryIdempotencyStore) Release(ctx context.Context, key, token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if el, ok := s.items[key]; ok {
+		if e := el.Value.(*idempotencyEntry); !e.Completed && e.Token == token {
+			s.remove(el)
+		}
+	}
+	return nil
+}
+
+// checkLease returns ErrLeaseLost when another token holds the lease on the key. Must hold the lock.
+func (s *memoryIdempotencyStore) checkLease(key, token string, now time.Time) error {
+	if e := s.get(key, now); e != nil && !e.Completed && e.Token != token {
+		return ErrLeaseLost
+	}
+
+	return nil
+}
+
+// get returns the entry if it hasn't expired. Must hold the lock.
+func (s *memoryIdempotencyStore) get(key string, now time.Time) *idempotencyEntry {
+	el, ok := s.items[key]
+	if !ok {
+		return nil
+	}
+
+	e := el.Value.(*idempotencyEntry)
+	if !now.Before(e.Expires) {
+		s.remove(el)
+		return nil
+	}
+
+	s.order.MoveToFront(el)
+	return e
+}
+
+// put adds or replaces the entry, evicting the least recently used. Must hold the lock.
+func (s *memoryIdempotencyStore) put(e idempotencyEntry) {
+	if el, ok := s.items[e.Key]; ok {
+		el.Value = &e
+		s.order.MoveToFront(el)
+		return
+	}
+
+	s.items[e.Key] = s.order.PushFront(&e)
+
+	for s.size > 0 && s.order.Len() > s.size {
+		s.remove(s.order.Back())
+	}
+}
+
+func (s *memoryIdempotencyStore) remove(el *list.Element) {
+	delete(s.items, el.Value.(*idempotencyEntry).Key)
+	s.order.Remove(el)
+}
+
+// completed returns every unexpired completed entry. Must hold the lock.
+func (s *memoryIdempotencyStore) completed(now time.Time) []idempotencyEntry {
+	entries := make([]idempotencyEntry, 0, len(s.items))
+	for el := s.order.Back(); el != nil; el = el.Prev() {
+		if e := el.Value.(*idempotencyEntry); e.Completed && now.Before(e.Expires) {
+			entries = append(entries, *e)
+		}
+	}
+
+	return entries
+}
+
+// minFileCompaction is the number of lines appended before the file is considered for compaction.
+const minFileCompaction = 1000
+
+// NewFileIdempotencyStore keeps completed jobs in memory & appends them to the file,
+// so they survive the worker restarting. Leases are only held in memory.
+func NewFileIdempotencyStore(path string) (IdempotencyStore, error) {
+	s := &fileIdempotencyStore{
+		memoryIdempotencyStore: newMemoryIdempotencyStore(0),
+		path:                   path,
+	}
+
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.compact(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+type fileIdempotencyStore struct {
+	*memoryIdempotencyStore
+
+	path     string
+	appended int
+}
+
+func (s *fileIdempotencyStore) Complete(ctx context.Context, key, token string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if err := s.checkLease(key, token, now); err != nil {
+		return err
+	}
+
+	e := idempotencyEntry{Key: key, Completed: true, Expires: now.Add(ttl)}
+
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return err
+	}
+
+	s.put(e)
+	s.appended++
+
+	// Rewrite the file once it's mostly expired or duplicate entries
+	if s.appended > minFileCompaction && s.appended > 2*s.order.Len() {
+		return s.compact()
+	}
+
+	return nil
+}
+
+// load replays the file into memory.
+func (s *fileIdempotencyStore) load() error {
+	f, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e idempotencyEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			// Partially written line from a crash
+			continue
+		}
+		if now.Before(e.Expires) {
+			e.Completed = true
+			s.put(e)
+		}
+	}
+
+	return scanner.Err()
+}
+
+// compact rewrites the file with only unexpired entries. Must hold the lock.
+func (s *fileIdempotencyStore) compact() error {
+	tmp := s.path + ".tmp"
+
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, e := range s.completed(time.Now()) {
+		if err := enc.Encode(e); err != nil {
+			f.Close()
+			return err
+		}
+	}
+
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	s.appended = 0
+	return os.Rename(tmp, s.path)
+}
@@ -0,0 +1,257 @@
+package goller_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/sqsiface"
+	"github.com/rcrowe/goller"
+)
+
+type writeSQSClient struct {
+	sqsiface.SQSAPI
+	Deleted  bool
+	Released *sqs.ChangeMessageVisibilityInput
+}
+
+func (c *writeSQSClient) DeleteMessageRequest(input *sqs.DeleteMessageInput) sqs.DeleteMessageRequest {
+	c.Deleted = true
+	return sqs.DeleteMessageRequest{
+		Request: &aws.Request{
+			Data: &sqs.DeleteMessageOutput{},
+		},
+	}
+}
+
+func (c *writeSQSClient) ChangeMessageVisibilityRequest(input *sqs.ChangeMessageVisibilityInput) sqs.ChangeMessageVisibilityRequest {
+	c.Released = input
+	return sqs.ChangeMessageVisibilityRequest{
+		Request: &aws.Request{
+			Data: &sqs.ChangeMessageVisibilityOutput{},
+		},
+	}
+}
+
+func newWriteJob(id, body string) (goller.Job, *writeSQSClient) {
+	svc := &writeSQSClient{}
+	msg := sqs.Message{MessageId: aws.String(id), Body: aws.String(body)}
+
+	return goller.NewJob(goller.NewDefaultConfig("foo", 1), goller.NewNopLogger(), msg, svc), svc
+}
+
+func TestIdempotencySkipsCompleted(t *testing.T) {
+	calls := 0
+	handler := goller.Chain(func(ctx context.Context, j goller.Job) error {
+		calls++
+		return j.Delete()
+	}, goller.NewIdempotencyMiddleware(goller.IdempotencyConfig{
+		Store: goller.NewMemoryIdempotencyStore(10),
+	}))
+
+	j, _ := newWriteJob("abc", "hello")
+	if err := handler(context.Background(), j); err != nil {
+		t.Fatalf("expected no error but got `%s`", err)
+	}
+
+	// Redelivery of the same message
+	j, svc := newWriteJob("abc", "hello")
+	if err := handler(context.Background(), j); err != nil {
+		t.Fatalf("expected no error but got `%s`", err)
+	}
+
+	if calls != 1 {
+		t.Errorf("expected handler to be called once but was called `%d` times", calls)
+	}
+	if !svc.Deleted {
+		t.Error("expected duplicate to be deleted")
+	}
+}
+
+func TestIdempotencyOnlyCompletesOnDelete(t *testing.T) {
+	store := goller.NewMemoryIdempotencyStore(10)
+	mw := goller.NewIdempotencyMiddleware(goller.IdempotencyConfig{Store: store})
+
+	// Errored
+	handler := mw(func(ctx context.Context, j goller.Job) error {
+		return errors.New("oh no")
+	})
+	j, _ := newWriteJob("abc", "hello")
+	handler(context.Background(), j)
+
+	token, err := store.Acquire(context.Background(), "abc", time.Minute)
+	if err != nil {
+		t.Errorf("expected lease to be released on error but got `%s`", err)
+	}
+	store.Release(context.Background(), "abc", token)
+
+	// Successful but never deleted
+	handler = mw(func(ctx context.Context, j goller.Job) error {
+		return nil
+	})
+	j, _ = newWriteJob("abc", "hello")
+	handler(context.Background(), j)
+
+	token, err = store.Acquire(context.Background(), "abc", time.Minute)
+	if err != nil {
+		t.Errorf("expected lease to be released when not deleted but got `%s`", err)
+	}
+	store.Release(context.Background(), "abc", token)
+
+	// Panics
+	handler = mw(func(ctx context.Context, j goller.Job) error {
+		panic("boom")
+	})
+	j, _ = newWriteJob("abc", "hello")
+	func() {
+		defer func() { recover() }()
+		handler(context.Background(), j)
+	}()
+
+	if _, err := store.Acquire(context.Background(), "abc", time.Minute); err != nil {
+		t.Errorf("expected lease to be released on panic but got `%s`", err)
+	}
+}
+
+func TestIdempotencyReleasesInProgress(t *testing.T) {
+	store := goller.NewMemoryIdempotencyStore(10)
+	store.Acquire(context.Background(), "abc", time.Minute)
+
+	calls := 0
+	handler := goller.NewIdempotencyMiddleware(goller.IdempotencyConfig{
+		Store: store,
+		Lease: 90 * time.Second,
+	})(func(ctx context.Context, j goller.Job) error {
+		calls++
+		return j.Delete()
+	})
+
+	j, svc := newWriteJob("abc", "hello")
+	if err := handler(context.Background(), j); err != nil {
+		t.Fatalf("expected no error but got `%s`", err)
+	}
+
+	if calls != 0 {
+		t.Errorf("expected handler not to be called but was called `%d` times", calls)
+	}
+	if svc.Released == nil || aws.Int64Value(svc.Released.VisibilityTimeout) != 90 {
+		t.Errorf("expected duplicate to be released for the lease but got `%+v`", svc.Released)
+	}
+}
+
+func TestIdempotencyBodyHashKey(t *testing.T) {
+	calls := 0
+	handler := goller.NewIdempotencyMiddleware(goller.IdempotencyConfig{
+		Store: goller.NewMemoryIdempotencyStore(10),
+		Key:   goller.BodyHashKey,
+	})(func(ctx context.Context, j goller.Job) error {
+		calls++
+		return j.Delete()
+	})
+
+	for _, m := range []struct{ id, body string }{{"1", "hello"}, {"2", "hello"}, {"3", "world"}} {
+		j, _ := newWriteJob(m.id, m.body)
+		handler(context.Background(), j)
+	}
+
+	if calls != 2 {
+		t.Errorf("expected `2` unique bodies to be handled but got `%d`", calls)
+	}
+}
+
+func TestIdempotencyStoreLeaseToken(t *testing.T) {
+	ctx := context.Background()
+	file, err := goller.NewFileIdempotencyStore(filepath.Join(t.TempDir(), "idempotency"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, store := range map[string]goller.IdempotencyStore{
+		"memory": goller.NewMemoryIdempotencyStore(10),
+		"file":   file,
+	} {
+		// The first holder's lease expired & was taken by another consumer
+		stale, _ := store.Acquire(ctx, "abc", time.Nanosecond)
+		time.Sleep(time.Millisecond)
+
+		token, err := store.Acquire(ctx, "abc", time.Minute)
+		if err != nil {
+			t.Fatalf("%s: expected lease once expired but got `%s`", name, err)
+		}
+
+		store.Release(ctx, "abc", stale)
+		if _, err := store.Acquire(ctx, "abc", time.Minute); err != goller.ErrJobInProgress {
+			t.Errorf("%s: expected stale release to leave the lease but got `%v`", name, err)
+		}
+		if err := store.Complete(ctx, "abc", stale, time.Hour); err != goller.ErrLeaseLost {
+			t.Errorf("%s: expected ErrLeaseLost completing with a stale token but got `%v`", name, err)
+		}
+
+		if err := store.Complete(ctx, "abc", token, time.Hour); err != nil {
+			t.Errorf("%s: expected holder to complete but got `%s`", name, err)
+		}
+		if _, err := store.Acquire(ctx, "abc", time.Minute); err != goller.ErrJobCompleted {
+			t.Errorf("%s: expected ErrJobCompleted but got `%v`", name, err)
+		}
+	}
+}
+
+func TestMemoryIdempotencyStoreEvicts(t *testing.T) {
+	ctx := context.Background()
+	store := goller.NewMemoryIdempotencyStore(2)
+
+	store.Complete(ctx, "a", "", time.Hour)
+	store.Complete(ctx, "b", "", time.Hour)
+
+	// Touch a so that b is the least recently used
+	store.Acquire(ctx, "a", time.Minute)
+	store.Complete(ctx, "c", "", time.Hour)
+
+	if _, err := store.Acquire(ctx, "a", time.Minute); err != goller.ErrJobCompleted {
+		t.Errorf("expected `a` to be kept but got `%v`", err)
+	}
+	if _, err := store.Acquire(ctx, "b", time.Minute); err != nil {
+		t.Errorf("expected `b` to be evicted but got `%s`", err)
+	}
+
+	// Expired
+	store.Complete(ctx, "d", "", time.Nanosecond)
+	time.Sleep(time.Millisecond)
+	if _, err := store.Acquire(ctx, "d", time.Minute); err != nil {
+		t.Errorf("expected `d` to have expired but got `%s`", err)
+	}
+}
+
+func TestFileIdempotencyStore(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "idempotency")
+
+	store, err := goller.NewFileIdempotencyStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store.Acquire(ctx, "leased", time.Minute)
+	store.Complete(ctx, "done", "", time.Hour)
+	store.Complete(ctx, "expired", "", time.Nanosecond)
+
+	// Reopen, as if the worker restarted
+	store, err = goller.NewFileIdempotencyStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := store.Acquire(ctx, "done", time.Minute); err != goller.ErrJobCompleted {
+		t.Errorf("expected `done` to survive a restart but got `%v`", err)
+	}
+	if _, err := store.Acquire(ctx, "leased", time.Minute); err != nil {
+		t.Errorf("expected leases to not be persisted but got `%s`", err)
+	}
+	if _, err := store.Acquire(ctx, "expired", time.Minute); err != nil {
+		t.Errorf("expected `expired` to have been dropped but got `%s`", err)
+	}
+}
@@ -3,23 +3,40 @@ package goller
 import (
 	"errors"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/sqs"
 	"github.com/aws/aws-sdk-go-v2/service/sqs/sqsiface"
-	"github.com/sirupsen/logrus"
 )
 
 // Job lets you interact with the SQS message.
 type Job interface {
 	// Reader
 	Attribute(attr string) (string, bool)
+	Attributes() map[string]AttributeValue
+	AttributeInt(attr string) (int64, error)
+	AttributeFloat(attr string) (float64, error)
+	AttributeBytes(attr string) ([]byte, error)
+	AttributeTime(attr string) (time.Time, error)
 	ID() string
 	Body() (string, error)
 	Tries() (int64, error)
 	Handled() bool
 
+	// SQS system attributes, zero values when the attribute wasn't requested.
+	// See ConsumerConfig.RetrievalAttributeNames.
+	SentAt() time.Time
+	FirstReceivedAt() time.Time
+	Age() time.Duration
+	SenderID() string
+	GroupID() string
+	DeduplicationID() string
+	SequenceNumber() string
+	TraceHeader() string
+	SystemAttribute(attr string) (string, bool)
+
 	// Writer
 	Delete() error
 	Release(secs int64) error
@@ -30,7 +47,7 @@ type Job interface {
 var ErrAlreadyHandled = errors.New("job already handled")
 
 // NewJob creates a new job.
-func NewJob(cfg *Config, logger *logrus.Logger, msg sqs.Message, svc sqsiface.SQSAPI) Job {
+func NewJob(cfg *Config, logger Logger, msg sqs.Message, svc sqsiface.SQSAPI) Job {
 	return &sqsJob{
 		cfg: cfg,
 		log: logger,
@@ -42,15 +59,22 @@ func NewJob(cfg *Config, logger *logrus.Logger, msg sqs.Message, svc sqsiface.SQ
 type sqsJob struct {
 	cfg     *Config
 	handled bool
-	log     *logrus.Logger
+	log     Logger
 	msg     sqs.Message
 	svc     sqsiface.SQSAPI
+
+	// Guards handled, held while writing to SQS so Shutdown can't abandon the job mid-write
+	mu sync.Mutex
 }
 
 // Attribute looks for custom attributes set on the message by the sender.
 // If nothing is found it will then look to SQS defined attributes.
+// Binary attributes are returned as a string of the raw bytes, see the typed Attribute helpers.
 func (j *sqsJob) Attribute(attr string) (string, bool) {
 	if a, ok := j.msg.MessageAttributes[attr]; ok {
+		if a.StringValue == nil {
+			return string(a.BinaryValue), true
+		}
 		return *a.StringValue, true
 	}
 
@@ -78,6 +102,7 @@ func (j *sqsJob) Body() (string, error) {
 
 // Tries returns the number of previous attempts to process the job.
 // If a job is put back on the queue (un)intentionally this will increase.
+// Includes the attempts made before the job was redriven from a dead letter queue, see TriesAttribute.
 func (j *sqsJob) Tries() (int64, error) {
 	count, ok := j.Attribute(string(sqs.MessageSystemAttributeNameApproximateReceiveCount))
 	if !ok {
@@ -89,16 +114,113 @@ func (j *sqsJob) Tries() (int64, error) {
 		tries--
 	}
 
+	if before, beforeErr := j.AttributeInt(TriesAttribute); beforeErr == nil && before > 0 {
+		tries += before
+	}
+
 	return tries, err
 }
 
+// systemAttribute only looks at the SQS defined attributes,
+// so they can't be overridden by the sender.
+func (j *sqsJob) systemAttribute(attr string) string {
+	return j.msg.Attributes[attr]
+}
+
+// SystemAttribute only looks at the SQS defined attributes, unlike Attribute(...)
+// a message attribute with the same name is ignored.
+func (j *sqsJob) SystemAttribute(attr string) (string, bool) {
+	v, ok := j.msg.Attributes[attr]
+	return v, ok
+}
+
+// timestampAttribute parses an attribute holding milliseconds since the epoch.
+func (j *sqsJob) timestampAttribute(attr string) time.Time {
+	ms, err := strconv.ParseInt(j.systemAttribute(attr), 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+
+	return time.Unix(0, ms*int64(time.Millisecond))
+}
+
+// SentAt is when the message was sent to the queue.
+// For a copy Goller sent back to the queue, it's when the original was sent, see SentAtAttribute.
+func (j *sqsJob) SentAt() time.Time {
+	if sent, err := j.AttributeInt(SentAtAttribute); err == nil && sent > 0 {
+		return time.Unix(0, sent*int64(time.Millisecond))
+	}
+
+	return j.timestampAttribute(string(sqs.MessageSystemAttributeNameSentTimestamp))
+}
+
+// FirstReceivedAt is when the message was first received from the queue.
+func (j *sqsJob) FirstReceivedAt() time.Time {
+	return j.timestampAttribute(string(sqs.MessageSystemAttributeNameApproximateFirstReceiveTimestamp))
+}
+
+// Age is how long ago the message was sent to the queue.
+func (j *sqsJob) Age() time.Duration {
+	sent := j.SentAt()
+	if sent.IsZero() {
+		return 0
+	}
+
+	return time.Since(sent)
+}
+
+// SenderID is the IAM user or role that sent the message.
+func (j *sqsJob) SenderID() string {
+	return j.systemAttribute(string(sqs.MessageSystemAttributeNameSenderId))
+}
+
+// GroupID is the message group of a FIFO queue message.
+func (j *sqsJob) GroupID() string {
+	return j.systemAttribute(string(sqs.MessageSystemAttributeNameMessageGroupId))
+}
+
+// DeduplicationID is the token used to deduplicate FIFO queue messages.
+func (j *sqsJob) DeduplicationID() string {
+	return j.systemAttribute(string(sqs.MessageSystemAttributeNameMessageDeduplicationId))
+}
+
+// SequenceNumber is the order of a FIFO queue message within its group.
+func (j *sqsJob) SequenceNumber() string {
+	return j.systemAttribute(string(sqs.MessageSystemAttributeNameSequenceNumber))
+}
+
+// TraceHeader is the X-Ray trace header the message was sent with.
+func (j *sqsJob) TraceHeader() string {
+	return j.systemAttribute(AWSTraceHeaderAttribute)
+}
+
 // Handled returns whether the Goller handler has successfully process the job.
 func (j *sqsJob) Handled() bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
 	return j.handled
 }
 
+// abandon marks the job handled without writing to SQS, so the handler can no longer acknowledge it.
+// Returns false when the handler already had.
+func (j *sqsJob) abandon() bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.handled {
+		return false
+	}
+	j.handled = true
+
+	return true
+}
+
 // Delete the message from SQS.
 func (j *sqsJob) Delete() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
 	if j.handled {
 		return ErrAlreadyHandled
 	}
@@ -122,12 +244,15 @@ func (j *sqsJob) Delete() error {
 
 // Release the job back on to the SQS queue for the given number of seconds.
 func (j *sqsJob) Release(secs int64) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
 	if j.handled {
 		return ErrAlreadyHandled
 	}
 
 	if secs < j.cfg.Job.MinVisibilityTimeout {
-		j.log.WithFields(logrus.Fields{
+		j.log.WithFields(Fields{
 			"jid":       j.ID(),
 			"requested": time.Duration(secs) * time.Second,
 		}).Debug("release time is below minimum")
@@ -136,7 +261,7 @@ func (j *sqsJob) Release(secs int64) error {
 	}
 
 	if secs > j.cfg.Job.MaxVisibilityTimeout {
-		j.log.WithFields(logrus.Fields{
+		j.log.WithFields(Fields{
 			"jid":       j.ID(),
 			"requested": time.Duration(secs) * time.Second,
 		}).Debug("release time is above maximum")
@@ -162,7 +287,7 @@ func (j *sqsJob) Release(secs int64) error {
 
 	if err == nil {
 		j.handled = true
-		j.log.WithFields(logrus.Fields{
+		j.log.WithFields(Fields{
 			"jid":  j.ID(),
 			"time": time.Duration(secs) * time.Second,
 		}).Debug("released job back to SQS")
@@ -178,7 +303,7 @@ func (j *sqsJob) Backoff() error {
 		return err
 	}
 
-	j.log.WithFields(logrus.Fields{
+	j.log.WithFields(Fields{
 		"jid":   j.ID(),
 		"tries": tries,
 	}).Debug("released job back to SQS")
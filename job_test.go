@@ -2,7 +2,7 @@ package goller_test
 
 import (
 	"errors"
-	"io/ioutil"
+	"strconv"
 	"testing"
 	"time"
 
@@ -10,7 +10,6 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/sqs"
 	"github.com/aws/aws-sdk-go-v2/service/sqs/sqsiface"
 	"github.com/rcrowe/goller"
-	"github.com/sirupsen/logrus"
 )
 
 type mockSQSClient struct {
@@ -19,8 +18,7 @@ type mockSQSClient struct {
 
 func TestAttribute(t *testing.T) {
 	cfg := goller.NewDefaultConfig("", 1)
-	logger := logrus.New()
-	logger.Out = ioutil.Discard
+	logger := goller.NewNopLogger()
 
 	// Not set
 	{
@@ -60,8 +58,7 @@ func TestID(t *testing.T) {
 		MessageId: aws.String(expected),
 	}
 
-	logger := logrus.New()
-	logger.Out = ioutil.Discard
+	logger := goller.NewNopLogger()
 
 	if id := goller.NewJob(cfg, logger, msg, &mockSQSClient{}).ID(); id != expected {
 		t.Error("incorrect job id")
@@ -70,8 +67,7 @@ func TestID(t *testing.T) {
 
 func TestBody(t *testing.T) {
 	cfg := goller.NewDefaultConfig("", 1)
-	logger := logrus.New()
-	logger.Out = ioutil.Discard
+	logger := goller.NewNopLogger()
 
 	// Empty body
 	{
@@ -103,8 +99,7 @@ func TestBody(t *testing.T) {
 
 func TestTries(t *testing.T) {
 	cfg := goller.NewDefaultConfig("", 1)
-	logger := logrus.New()
-	logger.Out = ioutil.Discard
+	logger := goller.NewNopLogger()
 
 	// Unable to get receive count
 	{
@@ -167,8 +162,7 @@ func (c *deleteSQSClient) DeleteMessageRequest(input *sqs.DeleteMessageInput) sq
 func TestDelete(t *testing.T) {
 	expectedQueueURL := "http://some/queue/url"
 	cfg := goller.NewDefaultConfig(expectedQueueURL, 1)
-	logger := logrus.New()
-	logger.Out = ioutil.Discard
+	logger := goller.NewNopLogger()
 
 	// On error, is handled still false
 	{
@@ -258,8 +252,7 @@ func (c *releaseSQSClient) ChangeMessageVisibilityRequest(input *sqs.ChangeMessa
 func TestRelease(t *testing.T) {
 	expectedQueueURL := "http://some/queue/url"
 	cfg := goller.NewDefaultConfig(expectedQueueURL, 1)
-	logger := logrus.New()
-	logger.Out = ioutil.Discard
+	logger := goller.NewNopLogger()
 
 	// On error, is handled still false
 	{
@@ -304,8 +297,7 @@ func TestRelease(t *testing.T) {
 
 func TestReleaseConfigOverrides(t *testing.T) {
 	expectedQueueURL := "http://some/queue/url"
-	logger := logrus.New()
-	logger.Out = ioutil.Discard
+	logger := goller.NewNopLogger()
 
 	// Min visibility config overrides release request
 	{
@@ -357,8 +349,7 @@ func TestReleaseConfigOverrides(t *testing.T) {
 func TestReleaseCantDeleteTwice(t *testing.T) {
 	expectedQueueURL := "http://some/queue/url"
 	cfg := goller.NewDefaultConfig(expectedQueueURL, 1)
-	logger := logrus.New()
-	logger.Out = ioutil.Discard
+	logger := goller.NewNopLogger()
 
 	// Job can not be deleted twice
 	{
@@ -382,8 +373,7 @@ func TestReleaseCantDeleteTwice(t *testing.T) {
 }
 
 func TestBackoff(t *testing.T) {
-	logger := logrus.New()
-	logger.Out = ioutil.Discard
+	logger := goller.NewNopLogger()
 
 	// Unable to Backoff when unable to get number of tries
 	{
@@ -421,3 +411,70 @@ func TestBackoff(t *testing.T) {
 		}
 	}
 }
+
+func TestSystemAttributes(t *testing.T) {
+	cfg := goller.NewDefaultConfig("", 1)
+	sent := time.Now().Add(-time.Minute).Truncate(time.Millisecond)
+
+	msg := sqs.Message{
+		MessageId: aws.String("123"),
+		Attributes: map[string]string{
+			string(sqs.MessageSystemAttributeNameSentTimestamp):                    strconv.FormatInt(sent.UnixNano()/int64(time.Millisecond), 10),
+			string(sqs.MessageSystemAttributeNameApproximateFirstReceiveTimestamp): strconv.FormatInt(sent.Add(time.Second).UnixNano()/int64(time.Millisecond), 10),
+			string(sqs.MessageSystemAttributeNameSenderId):                         "AIDAIENQZJOLO23YVJ4VO",
+			string(sqs.MessageSystemAttributeNameMessageGroupId):                   "group",
+			string(sqs.MessageSystemAttributeNameMessageDeduplicationId):           "dedupe",
+			string(sqs.MessageSystemAttributeNameSequenceNumber):                   "18849496460467696128",
+			goller.AWSTraceHeaderAttribute:                                         "Root=1-5759e988-bd862e3fe1be46a994272793",
+		},
+		MessageAttributes: map[string]sqs.MessageAttributeValue{
+			// Senders can't override system attributes
+			"MessageGroupId": {DataType: aws.String("String"), StringValue: aws.String("spoofed")},
+		},
+	}
+	j := goller.NewJob(cfg, goller.NewNopLogger(), msg, &mockSQSClient{})
+
+	if !j.SentAt().Equal(sent) {
+		t.Errorf("expected sent at `%s` but got `%s`", sent, j.SentAt())
+	}
+	if !j.FirstReceivedAt().Equal(sent.Add(time.Second)) {
+		t.Errorf("expected first received at `%s` but got `%s`", sent.Add(time.Second), j.FirstReceivedAt())
+	}
+	if age := j.Age(); age < time.Minute || age > 2*time.Minute {
+		t.Errorf("expected age of about a minute but got `%s`", age)
+	}
+
+	for expected, actual := range map[string]string{
+		"AIDAIENQZJOLO23YVJ4VO": j.SenderID(),
+		"group":                 j.GroupID(),
+		"dedupe":                j.DeduplicationID(),
+		"18849496460467696128":  j.SequenceNumber(),
+		"Root=1-5759e988-bd862e3fe1be46a994272793": j.TraceHeader(),
+	} {
+		if expected != actual {
+			t.Errorf("expected `%s` but got `%s`", expected, actual)
+		}
+	}
+	if v, ok := j.SystemAttribute("MessageGroupId"); !ok || v != "group" {
+		t.Errorf("expected system attribute `group` but got `%s`", v)
+	}
+	if _, ok := j.SystemAttribute("missing"); ok {
+		t.Error("expected missing system attribute not to be found")
+	}
+
+	// Copies sent back to the queue carry when the original was sent
+	msg.MessageAttributes[goller.SentAtAttribute] = sqs.MessageAttributeValue{
+		DataType:    aws.String("Number"),
+		StringValue: aws.String(strconv.FormatInt(sent.Add(-time.Hour).UnixNano()/int64(time.Millisecond), 10)),
+	}
+	j = goller.NewJob(cfg, goller.NewNopLogger(), msg, &mockSQSClient{})
+	if !j.SentAt().Equal(sent.Add(-time.Hour)) {
+		t.Errorf("expected sent at of the original `%s` but got `%s`", sent.Add(-time.Hour), j.SentAt())
+	}
+
+	// Not requested
+	j = goller.NewJob(cfg, goller.NewNopLogger(), sqs.Message{MessageId: aws.String("123")}, &mockSQSClient{})
+	if !j.SentAt().IsZero() || j.Age() != 0 || j.GroupID() != "" {
+		t.Error("expected zero values when attributes are missing")
+	}
+}
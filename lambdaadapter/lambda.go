@@ -0,0 +1,260 @@
+// Package lambdaadapter runs Goller handlers against SQS events in AWS Lambda.
+//
+//	adapter := lambdaadapter.New(sqs.New(cfg), handler)
+//	lambda.Start(adapter.Handle)
+//
+// The function's event source mapping needs ReportBatchItemFailures enabled,
+// otherwise a single failure retries the whole batch.
+package lambdaadapter
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/sqsiface"
+	"github.com/rcrowe/goller"
+)
+
+// SQSEvent is the event Lambda invokes the function with.
+type SQSEvent struct {
+	Records []SQSMessage `json:"Records"`
+}
+
+// SQSMessage is a single record in the event.
+type SQSMessage struct {
+	MessageID         string                         `json:"messageId"`
+	ReceiptHandle     string                         `json:"receiptHandle"`
+	Body              string                         `json:"body"`
+	Md5OfBody         string                         `json:"md5OfBody"`
+	Attributes        map[string]string              `json:"attributes"`
+	MessageAttributes map[string]SQSMessageAttribute `json:"messageAttributes"`
+	EventSource       string                         `json:"eventSource"`
+	EventSourceARN    string                         `json:"eventSourceARN"`
+	AWSRegion         string                         `json:"awsRegion"`
+}
+
+// SQSMessageAttribute is a custom attribute set on the message by the sender.
+type SQSMessageAttribute struct {
+	StringValue *string `json:"stringValue,omitempty"`
+	BinaryValue []byte  `json:"binaryValue,omitempty"`
+	DataType    string  `json:"dataType"`
+}
+
+// SQSEventResponse reports the messages that failed, so that only they are retried.
+type SQSEventResponse struct {
+	BatchItemFailures []BatchItemFailure `json:"batchItemFailures"`
+}
+
+// BatchItemFailure is a message that Lambda should not delete.
+type BatchItemFailure struct {
+	ItemIdentifier string `json:"itemIdentifier"`
+}
+
+// Adapter passes each record of an SQS event to the Goller handler.
+type Adapter interface {
+	WithLogger(logger goller.Logger)
+	Handle(ctx context.Context, event SQSEvent) (SQSEventResponse, error)
+}
+
+// New adapts the handler with the default job config.
+func New(svc sqsiface.SQSAPI, handler goller.HandlerFunc) Adapter {
+	return NewFromConfig(svc, goller.NewDefaultConfig("", 1), handler)
+}
+
+// NewFromConfig adapts the handler, using the job config for Release & Backoff.
+// The queue URL is worked out from each record.
+func NewFromConfig(svc sqsiface.SQSAPI, cfg *goller.Config, handler goller.HandlerFunc) Adapter {
+	return &lambdaAdapter{
+		cfg:     cfg,
+		handler: handler,
+		log:     goller.NewNopLogger(),
+		svc:     svc,
+	}
+}
+
+type lambdaAdapter struct {
+	cfg     *goller.Config
+	handler goller.HandlerFunc
+	log     goller.Logger
+	svc     sqsiface.SQSAPI
+}
+
+// WithLogger overrides the default logger, which discards everything.
+func (a *lambdaAdapter) WithLogger(logger goller.Logger) {
+	a.log = logger
+}
+
+// Handle calls the handler for each record.
+// Any job that isn't deleted, whether it errored, panicked or was released, is reported as a failure.
+//
+// Records from a FIFO queue are handled in order, stopping at the first failure.
+// Otherwise, as with a Goller worker, records are handled concurrently.
+func (a *lambdaAdapter) Handle(ctx context.Context, event SQSEvent) (SQSEventResponse, error) {
+	resp := SQSEventResponse{BatchItemFailures: []BatchItemFailure{}}
+	failed := make([]bool, len(event.Records))
+
+	if len(event.Records) > 0 && strings.HasSuffix(event.Records[0].EventSourceARN, ".fifo") {
+		for i, record := range event.Records {
+			if !a.handle(ctx, record) {
+				// Later messages in the group can't be processed before this one
+				for k := i; k < len(failed); k++ {
+					failed[k] = true
+				}
+				break
+			}
+		}
+	} else {
+		var wg sync.WaitGroup
+		wg.Add(len(event.Records))
+
+		for i, record := range event.Records {
+			go func(i int, record SQSMessage) {
+				defer wg.Done()
+				failed[i] = !a.handle(ctx, record)
+			}(i, record)
+		}
+
+		wg.Wait()
+	}
+
+	for i, record := range event.Records {
+		if failed[i] {
+			resp.BatchItemFailures = append(resp.BatchItemFailures, BatchItemFailure{ItemIdentifier: record.MessageID})
+		}
+	}
+
+	return resp, nil
+}
+
+// handle returns whether the job was deleted.
+func (a *lambdaAdapter) handle(ctx context.Context, record SQSMessage) (deleted bool) {
+	queueURL, err := QueueURL(record.EventSourceARN)
+	if err != nil {
+		a.log.WithError(err).WithField("jid", record.MessageID).Error("unable to work out queue url")
+		return false
+	}
+
+	cfg := *a.cfg
+	cfg.QueueURL = queueURL
+
+	logger := a.log.WithField("jid", record.MessageID)
+	j := &lambdaJob{Job: goller.NewJob(&cfg, logger, toMessage(record), a.svc)}
+	if tries, err := j.Tries(); err == nil {
+		logger = logger.WithField("tries", tries)
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			logger.WithError(fmt.Errorf("panic: %s", r)).Error("job handler paniced")
+			deleted = false
+		}
+	}()
+
+	if err := a.handler(goller.ContextWithLogger(ctx, logger), j); err != nil {
+		logger.WithError(err).Error("handler errored")
+	}
+
+	if !j.deleted() {
+		logger.Error("job not handled")
+		return false
+	}
+
+	logger.Debug("job processed successfully")
+	return true
+}
+
+// toMessage converts the record in to the shape returned by the SQS API.
+func toMessage(record SQSMessage) sqs.Message {
+	msg := sqs.Message{
+		Attributes:        make(map[string]string, len(record.Attributes)),
+		Body:              aws.String(record.Body),
+		MD5OfBody:         aws.String(record.Md5OfBody),
+		MessageAttributes: make(map[string]sqs.MessageAttributeValue, len(record.MessageAttributes)),
+		MessageId:         aws.String(record.MessageID),
+		ReceiptHandle:     aws.String(record.ReceiptHandle),
+	}
+
+	for k, v := range record.Attributes {
+		msg.Attributes[k] = v
+	}
+	for k, v := range record.MessageAttributes {
+		msg.MessageAttributes[k] = sqs.MessageAttributeValue{
+			BinaryValue: v.BinaryValue,
+			DataType:    aws.String(v.DataType),
+			StringValue: v.StringValue,
+		}
+	}
+
+	return msg
+}
+
+// QueueURL works out the queue URL from the queue ARN.
+func QueueURL(arn string) (string, error) {
+	// arn:partition:sqs:region:account:name
+	parts := strings.Split(arn, ":")
+	if len(parts) != 6 || parts[0] != "arn" || parts[2] != "sqs" {
+		return "", fmt.Errorf("invalid sqs arn: %q", arn)
+	}
+
+	domain := "amazonaws.com"
+	if parts[1] == "aws-cn" {
+		domain = "amazonaws.com.cn"
+	}
+
+	return fmt.Sprintf("https://sqs.%s.%s/%s/%s", parts[3], domain, parts[4], parts[5]), nil
+}
+
+// lambdaJob leaves deleting the message to Lambda.
+type lambdaJob struct {
+	goller.Job
+
+	mu  sync.Mutex
+	del bool
+}
+
+// Delete succeeds without calling SQS, Lambda deletes the message once the function returns.
+func (j *lambdaJob) Delete() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.del || j.Job.Handled() {
+		return goller.ErrAlreadyHandled
+	}
+
+	j.del = true
+	return nil
+}
+
+// Release changes the visibility of the message, and reports it as failed so Lambda doesn't delete it.
+func (j *lambdaJob) Release(secs int64) error {
+	if j.deleted() {
+		return goller.ErrAlreadyHandled
+	}
+
+	return j.Job.Release(secs)
+}
+
+// Backoff will exponentially release the job back to the queue.
+func (j *lambdaJob) Backoff() error {
+	if j.deleted() {
+		return goller.ErrAlreadyHandled
+	}
+
+	return j.Job.Backoff()
+}
+
+// Handled returns whether the job has been deleted or released.
+func (j *lambdaJob) Handled() bool {
+	return j.deleted() || j.Job.Handled()
+}
+
+func (j *lambdaJob) deleted() bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return j.del
+}
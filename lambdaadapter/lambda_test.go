@@ -0,0 +1,179 @@
+package lambdaadapter_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/sqsiface"
+	"github.com/rcrowe/goller"
+	"github.com/rcrowe/goller/lambdaadapter"
+)
+
+type releaseSQSClient struct {
+	sqsiface.SQSAPI
+	lock   sync.Mutex
+	Inputs []sqs.ChangeMessageVisibilityInput
+}
+
+func (c *releaseSQSClient) ChangeMessageVisibilityRequest(input *sqs.ChangeMessageVisibilityInput) sqs.ChangeMessageVisibilityRequest {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.Inputs = append(c.Inputs, *input)
+
+	return sqs.ChangeMessageVisibilityRequest{
+		Request: &aws.Request{
+			Data: &sqs.ChangeMessageVisibilityOutput{},
+		},
+	}
+}
+
+func loadEvent(t *testing.T, fixture string) lambdaadapter.SQSEvent {
+	data, err := ioutil.ReadFile("testdata/" + fixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var event lambdaadapter.SQSEvent
+	if err := json.Unmarshal(data, &event); err != nil {
+		t.Fatal(err)
+	}
+
+	return event
+}
+
+// handler deletes the first order, backs off the second & fails the third.
+func handler(t *testing.T, calls *sync.Map) goller.HandlerFunc {
+	return func(ctx context.Context, j goller.Job) error {
+		body, _ := j.Body()
+		calls.Store(body, true)
+
+		switch body {
+		case `{"order": 1}`:
+			if tenant, _ := j.Attribute("tenant"); tenant != "acme" {
+				t.Errorf("expected tenant attribute `acme` but got `%s`", tenant)
+			}
+			if tries, _ := j.Tries(); tries != 0 {
+				t.Errorf("expected `0` tries but got `%d`", tries)
+			}
+			return j.Delete()
+
+		case `{"order": 2}`:
+			if tries, _ := j.Tries(); tries != 2 {
+				t.Errorf("expected `2` tries but got `%d`", tries)
+			}
+			return j.Backoff()
+		}
+
+		return errors.New("oh no")
+	}
+}
+
+func failures(resp lambdaadapter.SQSEventResponse) []string {
+	ids := []string{}
+	for _, f := range resp.BatchItemFailures {
+		ids = append(ids, f.ItemIdentifier)
+	}
+	sort.Strings(ids)
+
+	return ids
+}
+
+func TestHandle(t *testing.T) {
+	svc := &releaseSQSClient{}
+	calls := &sync.Map{}
+
+	resp, err := lambdaadapter.New(svc, handler(t, calls)).Handle(context.Background(), loadEvent(t, "event.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"2e1424d4-f796-459a-8184-9c92662be6da", "a4b1f5a2-0a43-4b7c-9a62-2c6f2cb2f8e1"}
+	if got := failures(resp); len(got) != 2 || got[0] != expected[0] || got[1] != expected[1] {
+		t.Errorf("expected failures `%v` but got `%v`", expected, got)
+	}
+
+	if len(svc.Inputs) != 1 {
+		t.Fatalf("expected backoff to change visibility once but got `%d`", len(svc.Inputs))
+	}
+	if url := aws.StringValue(svc.Inputs[0].QueueUrl); url != "https://sqs.us-east-2.amazonaws.com/123456789012/orders" {
+		t.Errorf("expected queue url from the event source arn but got `%s`", url)
+	}
+	if handle := aws.StringValue(svc.Inputs[0].ReceiptHandle); handle != "AQEBzWwaftRI0KuVm4tP+/7q1rGgNqicHq" {
+		t.Errorf("expected receipt handle of the second order but got `%s`", handle)
+	}
+}
+
+func TestHandleFIFO(t *testing.T) {
+	calls := &sync.Map{}
+
+	resp, err := lambdaadapter.New(&releaseSQSClient{}, handler(t, calls)).Handle(context.Background(), loadEvent(t, "fifo_event.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := failures(resp); len(got) != 2 {
+		t.Errorf("expected the failed order and everything after it to fail but got `%v`", got)
+	}
+	if _, ok := calls.Load(`{"order": 3}`); ok {
+		t.Error("expected handling to stop at the first failure")
+	}
+}
+
+func TestHandlePanic(t *testing.T) {
+	event := loadEvent(t, "event.json")
+	event.Records = event.Records[:1]
+
+	resp, err := lambdaadapter.New(&releaseSQSClient{}, func(ctx context.Context, j goller.Job) error {
+		panic("boom")
+	}).Handle(context.Background(), event)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(resp.BatchItemFailures) != 1 {
+		t.Errorf("expected panic to be reported as a failure but got `%v`", resp.BatchItemFailures)
+	}
+}
+
+func TestDeleteIsHandled(t *testing.T) {
+	event := loadEvent(t, "event.json")
+	event.Records = event.Records[:1]
+
+	lambdaadapter.New(&releaseSQSClient{}, func(ctx context.Context, j goller.Job) error {
+		if err := j.Delete(); err != nil {
+			t.Errorf("expected delete to succeed but got `%s`", err)
+		}
+		if !j.Handled() {
+			t.Error("expected job to be handled once deleted")
+		}
+		if err := j.Release(10); err != goller.ErrAlreadyHandled {
+			t.Errorf("expected ErrAlreadyHandled but got `%v`", err)
+		}
+		return nil
+	}).Handle(context.Background(), event)
+}
+
+func TestQueueURL(t *testing.T) {
+	for arn, expected := range map[string]string{
+		"arn:aws:sqs:eu-west-1:123456789012:jobs":      "https://sqs.eu-west-1.amazonaws.com/123456789012/jobs",
+		"arn:aws-cn:sqs:cn-north-1:123456789012:jobs":  "https://sqs.cn-north-1.amazonaws.com.cn/123456789012/jobs",
+		"arn:aws:sqs:us-east-1:123456789012:jobs.fifo": "https://sqs.us-east-1.amazonaws.com/123456789012/jobs.fifo",
+	} {
+		url, err := lambdaadapter.QueueURL(arn)
+		if err != nil || url != expected {
+			t.Errorf("expected `%s` but got `%s` (%v)", expected, url, err)
+		}
+	}
+
+	if _, err := lambdaadapter.QueueURL("arn:aws:sns:us-east-1:123456789012:topic"); err == nil {
+		t.Error("expected an error for a non-sqs arn")
+	}
+}
@@ -0,0 +1,258 @@
+package goller
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// ConcurrencyLimiter decides how many handlers can run at once across all consumers.
+// It's fed the outcome of every handler call so it can react to downstream health.
+// See NewAIMDLimiter(...) and NewGradientLimiter(...).
+type ConcurrencyLimiter interface {
+	Limit() int
+	Observe(sample ConcurrencySample)
+}
+
+// ConcurrencySample is the outcome of a single handler call.
+type ConcurrencySample struct {
+	// How long the handler took.
+	Latency time.Duration
+
+	// Number of handlers running when the sample was taken, including this one.
+	InFlight int
+
+	// Error returned by the handler, including panics.
+	Err error
+}
+
+// AIMDLimiter adds one slot when handlers are succeeding and the limit is being used,
+// and backs off multiplicatively on errors or handlers taking longer than Timeout.
+type AIMDLimiter struct {
+	// Bounds on the number of handler slots.
+	Min int
+	Max int
+
+	// Handlers taking longer than this are treated as failures.
+	// Zero value disables setting.
+	Timeout time.Duration
+
+	// Multiplied with the current limit on failure.
+	// Default 0.9.
+	BackoffRatio float64
+
+	mu    sync.Mutex
+	limit float64
+}
+
+// NewAIMDLimiter starts with the minimum number of slots.
+func NewAIMDLimiter(min, max int) *AIMDLimiter {
+	return &AIMDLimiter{
+		Min:          min,
+		Max:          max,
+		BackoffRatio: 0.9,
+		limit:        float64(min),
+	}
+}
+
+// Limit is the current number of handler slots.
+func (l *AIMDLimiter) Limit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return int(l.limit)
+}
+
+// Observe adjusts the limit based off of the handler outcome.
+func (l *AIMDLimiter) Observe(sample ConcurrencySample) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	switch {
+	case sample.Err != nil || (l.Timeout > 0 && sample.Latency > l.Timeout):
+		l.limit *= l.BackoffRatio
+
+	// Only grow when we're actually making use of the current limit
+	case float64(sample.InFlight*2) >= l.limit:
+		l.limit++
+	}
+
+	l.limit = clampLimit(l.limit, l.Min, l.Max)
+}
+
+// GradientLimiter compares the latency of each handler against the long term average,
+// shrinking the limit as latency climbs and growing it while latency holds steady.
+// Similar to TCP Vegas; errors back the limit off as per AIMDLimiter.
+type GradientLimiter struct {
+	// Bounds on the number of handler slots.
+	Min int
+	Max int
+
+	// How far above the long term latency handlers can drift before the limit is reduced.
+	// Default 2.
+	Tolerance float64
+
+	// How quickly the limit moves towards the new estimate, between 0 and 1.
+	// Default 0.2.
+	Smoothing float64
+
+	// Multiplied with the current limit on failure.
+	// Default 0.9.
+	BackoffRatio float64
+
+	mu          sync.Mutex
+	limit       float64
+	longLatency float64
+}
+
+// NewGradientLimiter starts with the minimum number of slots.
+func NewGradientLimiter(min, max int) *GradientLimiter {
+	return &GradientLimiter{
+		Min:          min,
+		Max:          max,
+		Tolerance:    2,
+		Smoothing:    0.2,
+		BackoffRatio: 0.9,
+		limit:        float64(min),
+	}
+}
+
+// Limit is the current number of handler slots.
+func (l *GradientLimiter) Limit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return int(l.limit)
+}
+
+// Observe adjusts the limit based off of the handler outcome.
+func (l *GradientLimiter) Observe(sample ConcurrencySample) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if sample.Err != nil {
+		l.limit = clampLimit(l.limit*l.BackoffRatio, l.Min, l.Max)
+		return
+	}
+
+	latency := float64(sample.Latency)
+	if latency <= 0 {
+		return
+	}
+
+	// Exponential moving average over roughly the last 100 samples
+	if l.longLatency == 0 {
+		l.longLatency = latency
+	} else {
+		l.longLatency += (latency - l.longLatency) / 100
+	}
+
+	// Don't grow the limit when it's not being used
+	if float64(sample.InFlight*2) < l.limit {
+		return
+	}
+
+	gradient := math.Max(0.5, math.Min(1, l.Tolerance*l.longLatency/latency))
+	estimate := l.limit*gradient + math.Sqrt(l.limit)
+
+	l.limit = clampLimit(l.limit*(1-l.Smoothing)+estimate*l.Smoothing, l.Min, l.Max)
+}
+
+func clampLimit(limit float64, min, max int) float64 {
+	return math.Max(float64(min), math.Min(float64(max), limit))
+}
+
+// handlerSlots blocks handlers from running until the limiter allows it.
+type handlerSlots struct {
+	mu       sync.Mutex
+	freed    chan struct{}
+	inFlight int
+	limiter  ConcurrencyLimiter
+}
+
+func newHandlerSlots(limiter ConcurrencyLimiter) *handlerSlots {
+	s := &handlerSlots{
+		freed:   make(chan struct{}),
+		limiter: limiter,
+	}
+
+	concurrencyLimit.Set(float64(limiter.Limit()))
+
+	return s
+}
+
+// acquire waits for a free slot, or until the context is done.
+func (s *handlerSlots) acquire(ctx context.Context) error {
+	for ctx.Err() == nil {
+		s.mu.Lock()
+
+		// Always allow one handler to run, otherwise we'll never recover
+		if s.inFlight == 0 || s.inFlight < s.limiter.Limit() {
+			s.inFlight++
+			concurrencyInFlight.Set(float64(s.inFlight))
+			s.mu.Unlock()
+
+			return nil
+		}
+
+		freed := s.freed
+		s.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-freed:
+		}
+	}
+
+	return ctx.Err()
+}
+
+// release the slot feeding the outcome back to the limiter.
+func (s *handlerSlots) release(latency time.Duration, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.limiter.Observe(ConcurrencySample{
+		Latency:  latency,
+		InFlight: s.inFlight,
+		Err:      err,
+	})
+
+	s.inFlight--
+	concurrencyInFlight.Set(float64(s.inFlight))
+	concurrencyLimit.Set(float64(s.limiter.Limit()))
+
+	// Wake everyone waiting on a slot
+	close(s.freed)
+	s.freed = make(chan struct{})
+}
+
+// middleware calls the handler once a slot is free.
+// Jobs still waiting when the context is done are released back to the queue without being handled.
+func (s *handlerSlots) middleware(next HandlerFunc) HandlerFunc {
+	return func(ctx context.Context, j Job) (err error) {
+		if err := s.acquire(ctx); err != nil {
+			logger := LoggerFromContext(ctx)
+			logger.Debug("context done waiting for a handler slot. releasing job")
+			if releaseErr := releaseJob(j, logger); releaseErr != nil {
+				logger.WithError(releaseErr).Error("unable to release job")
+			}
+			return err
+		}
+
+		start := time.Now()
+		defer func() {
+			if r := recover(); r != nil {
+				s.release(time.Since(start), fmt.Errorf("panic: %s", r))
+				panic(r)
+			}
+
+			s.release(time.Since(start), err)
+		}()
+
+		return next(ctx, j)
+	}
+}
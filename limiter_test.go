@@ -0,0 +1,214 @@
+package goller_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/rcrowe/goller"
+)
+
+func TestAIMDLimiter(t *testing.T) {
+	l := goller.NewAIMDLimiter(2, 5)
+	l.Timeout = time.Second
+
+	if l.Limit() != 2 {
+		t.Errorf("expected to start at the minimum `2` but got `%d`", l.Limit())
+	}
+
+	// Not using the limit, so don't grow
+	l.Observe(goller.ConcurrencySample{Latency: time.Millisecond, InFlight: 0})
+	if l.Limit() != 2 {
+		t.Errorf("expected limit to stay at `2` but got `%d`", l.Limit())
+	}
+
+	// Additive increase, capped at max
+	for i := 0; i < 10; i++ {
+		l.Observe(goller.ConcurrencySample{Latency: time.Millisecond, InFlight: l.Limit()})
+	}
+	if l.Limit() != 5 {
+		t.Errorf("expected limit to grow to the maximum `5` but got `%d`", l.Limit())
+	}
+
+	// Multiplicative decrease on error
+	l.Observe(goller.ConcurrencySample{Latency: time.Millisecond, InFlight: 5, Err: errors.New("broke")})
+	if l.Limit() != 4 {
+		t.Errorf("expected limit to back off to `4` but got `%d`", l.Limit())
+	}
+
+	// Timeouts count as errors, capped at min
+	for i := 0; i < 20; i++ {
+		l.Observe(goller.ConcurrencySample{Latency: 2 * time.Second, InFlight: 5})
+	}
+	if l.Limit() != 2 {
+		t.Errorf("expected limit to back off to the minimum `2` but got `%d`", l.Limit())
+	}
+}
+
+func TestGradientLimiter(t *testing.T) {
+	l := goller.NewGradientLimiter(1, 20)
+
+	// Steady latency grows the limit
+	for i := 0; i < 100; i++ {
+		l.Observe(goller.ConcurrencySample{Latency: 10 * time.Millisecond, InFlight: l.Limit()})
+	}
+	grown := l.Limit()
+	if grown <= 1 {
+		t.Fatalf("expected limit to grow but got `%d`", grown)
+	}
+
+	// Latency climbing well above the average shrinks it
+	for i := 0; i < 20; i++ {
+		l.Observe(goller.ConcurrencySample{Latency: time.Second, InFlight: l.Limit()})
+	}
+	if l.Limit() >= grown {
+		t.Errorf("expected limit to shrink below `%d` but got `%d`", grown, l.Limit())
+	}
+
+	// Errors back off
+	before := l.Limit()
+	l.Observe(goller.ConcurrencySample{Err: errors.New("broke")})
+	if before > 1 && l.Limit() >= before {
+		t.Errorf("expected limit to back off below `%d` but got `%d`", before, l.Limit())
+	}
+}
+
+type fixedLimiter struct {
+	lock    sync.Mutex
+	limit   int
+	samples []goller.ConcurrencySample
+}
+
+func (l *fixedLimiter) Limit() int {
+	return l.limit
+}
+
+func (l *fixedLimiter) Observe(sample goller.ConcurrencySample) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.samples = append(l.samples, sample)
+}
+
+func TestConcurrencyLimiterCapsHandlers(t *testing.T) {
+	msgs := []sqs.Message{}
+	for i := 0; i < 10; i++ {
+		msgs = append(msgs, sqs.Message{MessageId: aws.String(fmt.Sprintf("msg-%d", i))})
+	}
+	svc := &receiveSQSClient{Output: sqs.ReceiveMessageOutput{Messages: msgs}}
+
+	limiter := &fixedLimiter{limit: 2}
+	cfg := goller.NewDefaultConfig("foo", 1)
+	cfg.RunOnce()
+	cfg.Consumer.ConcurrencyLimiter = limiter
+
+	var lock sync.Mutex
+	var running, maxRunning int
+
+	w := goller.NewFromConfig(svc, cfg)
+	w.Listen(context.Background(), func(ctx context.Context, j goller.Job) error {
+		lock.Lock()
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		lock.Unlock()
+
+		time.Sleep(5 * time.Millisecond)
+
+		lock.Lock()
+		running--
+		lock.Unlock()
+
+		if j.ID() == "msg-3" {
+			return errors.New("broke")
+		}
+		return nil
+	})
+
+	if maxRunning != 2 {
+		t.Errorf("expected at most `2` handlers running at once but got `%d`", maxRunning)
+	}
+	if len(limiter.samples) != 10 {
+		t.Fatalf("expected `10` samples but got `%d`", len(limiter.samples))
+	}
+
+	errored := 0
+	for _, sample := range limiter.samples {
+		if sample.Err != nil {
+			errored++
+		}
+		if sample.Latency < 5*time.Millisecond {
+			t.Errorf("expected latency of at least 5ms but got `%s`", sample.Latency)
+		}
+	}
+	if errored != 1 {
+		t.Errorf("expected `1` errored sample but got `%d`", errored)
+	}
+}
+
+// singleReleaseSQSClient counts jobs released one at a time, which is clamped to the minimum visibility timeout.
+type singleReleaseSQSClient struct {
+	*queueSQSClient
+	single int32
+}
+
+func (c *singleReleaseSQSClient) ChangeMessageVisibilityRequest(input *sqs.ChangeMessageVisibilityInput) sqs.ChangeMessageVisibilityRequest {
+	atomic.AddInt32(&c.single, 1)
+	return c.queueSQSClient.ChangeMessageVisibilityRequest(input)
+}
+
+func TestConcurrencyLimiterReleasesWaitingJobsOnCancel(t *testing.T) {
+	svc := &singleReleaseSQSClient{queueSQSClient: newQueueSQSClient(3)}
+
+	cfg := goller.NewDefaultConfig("foo", 1)
+	cfg.RunOnce()
+	cfg.Consumer.RetrievalMaxNumberOfMessages = 3
+	cfg.Consumer.ConcurrencyLimiter = &fixedLimiter{limit: 1}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	started := make(chan struct{})
+
+	var lock sync.Mutex
+	handled := 0
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		w := goller.NewFromConfig(svc, cfg)
+		w.Listen(ctx, func(ctx context.Context, j goller.Job) error {
+			lock.Lock()
+			handled++
+			lock.Unlock()
+
+			close(started)
+			<-ctx.Done()
+			return ctx.Err()
+		})
+	}()
+
+	<-started
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected jobs waiting on a slot to give up once cancelled")
+	}
+
+	if handled != 1 {
+		t.Errorf("expected `1` job handled but got `%d`", handled)
+	}
+	if released := svc.Released(); len(released) != 2 {
+		t.Errorf("expected the `2` waiting jobs released but got `%v`", released)
+	}
+	if single := atomic.LoadInt32(&svc.single); single != 0 {
+		t.Errorf("expected waiting jobs to be visible straight away, but `%d` were released with the minimum visibility timeout", single)
+	}
+}
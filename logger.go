@@ -0,0 +1,52 @@
+package goller
+
+import "context"
+
+// Fields is a set of key/value pairs attached to a log message.
+type Fields map[string]interface{}
+
+// Logger is the structured logger Goller writes to.
+// Adapters are provided for log/slog (NewSlogLogger, Go 1.21+), logrus (logrusadapter) and zap (zapadapter).
+type Logger interface {
+	WithField(key string, value interface{}) Logger
+	WithFields(fields Fields) Logger
+	WithError(err error) Logger
+
+	Debug(msg string)
+	Info(msg string)
+	Error(msg string)
+}
+
+// NewNopLogger discards everything written to it.
+// This is the default Goller logger.
+func NewNopLogger() Logger {
+	return nopLogger{}
+}
+
+type nopLogger struct{}
+
+func (l nopLogger) WithField(key string, value interface{}) Logger { return l }
+func (l nopLogger) WithFields(fields Fields) Logger                { return l }
+func (l nopLogger) WithError(err error) Logger                     { return l }
+func (l nopLogger) Debug(msg string)                               {}
+func (l nopLogger) Info(msg string)                                {}
+func (l nopLogger) Error(msg string)                               {}
+
+type loggerKey struct{}
+
+// ContextWithLogger stores the job logger on the handler context,
+// for running handlers outside of a Worker.
+func ContextWithLogger(ctx context.Context, logger Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// LoggerFromContext returns the logger for the job being handled.
+// It is enriched with the job ID, queue and number of tries.
+// When called outside of a handler a logger that discards everything is returned.
+func LoggerFromContext(ctx context.Context) Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(Logger); ok {
+		return logger
+	}
+
+	return NewNopLogger()
+}
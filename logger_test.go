@@ -0,0 +1,61 @@
+package goller_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/rcrowe/goller"
+	"github.com/rcrowe/goller/logrusadapter"
+	"github.com/sirupsen/logrus"
+)
+
+func TestLoggerFromContextOutsideHandler(t *testing.T) {
+	// Should not panic
+	goller.LoggerFromContext(context.Background()).WithField("foo", "bar").Info("hello")
+}
+
+func TestLoggerFromContextInHandler(t *testing.T) {
+	svc := &receiveSQSClient{
+		Output: sqs.ReceiveMessageOutput{
+			Messages: []sqs.Message{
+				{
+					MessageId: aws.String("abc123"),
+					Attributes: map[string]string{
+						string(sqs.MessageSystemAttributeNameApproximateReceiveCount): "3",
+					},
+				},
+			},
+		},
+	}
+
+	var buf bytes.Buffer
+	logger := logrus.New()
+	logger.Out = &buf
+
+	cfg := goller.NewDefaultConfig("https://sqs/123/some-queue", 1)
+	cfg.RunOnce()
+
+	w := goller.NewFromConfig(svc, cfg)
+	w.WithLogger(logrusadapter.New(logger))
+	w.Listen(context.Background(), func(ctx context.Context, j goller.Job) error {
+		goller.LoggerFromContext(ctx).Info("from the handler")
+		return nil
+	})
+
+	var line string
+	for _, l := range strings.Split(buf.String(), "\n") {
+		if strings.Contains(l, "from the handler") {
+			line = l
+		}
+	}
+
+	for _, expected := range []string{"jid=abc123", "queue=some-queue", "tries=2"} {
+		if !strings.Contains(line, expected) {
+			t.Errorf("expected `%s` in the log but saw `%s`", expected, line)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+// Package logrusadapter lets Goller write to logrus.
+package logrusadapter
+
+import (
+	"github.com/rcrowe/goller"
+	"github.com/sirupsen/logrus"
+)
+
+// New wraps either a *logrus.Logger or a *logrus.Entry.
+func New(logger logrus.FieldLogger) goller.Logger {
+	return &logrusLogger{log: logger}
+}
+
+type logrusLogger struct {
+	log logrus.FieldLogger
+}
+
+func (l *logrusLogger) WithField(key string, value interface{}) goller.Logger {
+	return &logrusLogger{log: l.log.WithField(key, value)}
+}
+
+func (l *logrusLogger) WithFields(fields goller.Fields) goller.Logger {
+	return &logrusLogger{log: l.log.WithFields(logrus.Fields(fields))}
+}
+
+func (l *logrusLogger) WithError(err error) goller.Logger {
+	return &logrusLogger{log: l.log.WithError(err)}
+}
+
+func (l *logrusLogger) Debug(msg string) { l.log.Debug(msg) }
+func (l *logrusLogger) Info(msg string)  { l.log.Info(msg) }
+func (l *logrusLogger) Error(msg string) { l.log.Error(msg) }
@@ -0,0 +1,51 @@
+package logrusadapter_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/rcrowe/goller"
+	"github.com/rcrowe/goller/logrusadapter"
+	"github.com/sirupsen/logrus"
+)
+
+func TestLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := logrus.New()
+	logger.Level = logrus.DebugLevel
+	logger.Out = &buf
+
+	logrusadapter.New(logger).
+		WithField("foo", "bar").
+		WithFields(goller.Fields{"count": 3}).
+		WithError(errors.New("oh no")).
+		Debug("something broke")
+
+	out := buf.String()
+	for _, expected := range []string{"level=debug", "foo=bar", "count=3", "error=\"oh no\"", "something broke"} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("expected `%s` in the log but saw `%s`", expected, out)
+		}
+	}
+}
+
+func TestEntry(t *testing.T) {
+	var buf bytes.Buffer
+	logger := logrus.New()
+	logger.Out = &buf
+
+	entry := logger.WithFields(logrus.Fields{
+		"service": "some-service",
+	})
+
+	logrusadapter.New(entry).WithField("foo", "bar").Info("hello")
+
+	out := buf.String()
+	for _, expected := range []string{"level=info", "service=some-service", "foo=bar", "hello"} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("expected `%s` in the log but saw `%s`", expected, out)
+		}
+	}
+}
@@ -0,0 +1,13 @@
+package goller
+
+// Middleware wraps a HandlerFunc to add behaviour before or after the job is handled.
+type Middleware func(next HandlerFunc) HandlerFunc
+
+// Chain wraps the handler with the middleware, the first middleware being the outermost.
+func Chain(handler HandlerFunc, middleware ...Middleware) HandlerFunc {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		handler = middleware[i](handler)
+	}
+
+	return handler
+}
@@ -0,0 +1,32 @@
+package goller_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/rcrowe/goller"
+)
+
+func TestChain(t *testing.T) {
+	var calls []string
+	record := func(name string) goller.Middleware {
+		return func(next goller.HandlerFunc) goller.HandlerFunc {
+			return func(ctx context.Context, j goller.Job) error {
+				calls = append(calls, name)
+				return next(ctx, j)
+			}
+		}
+	}
+
+	handler := goller.Chain(func(ctx context.Context, j goller.Job) error {
+		calls = append(calls, "handler")
+		return nil
+	}, record("first"), record("second"))
+
+	handler(context.Background(), nil)
+
+	if order := strings.Join(calls, ","); order != "first,second,handler" {
+		t.Errorf("expected `first,second,handler` but got `%s`", order)
+	}
+}
@@ -47,11 +47,164 @@ var (
 		Help:      "Counter for number of panics when calling job handler.",
 	})
 
+	jobExpiredTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "goller",
+		Name:      "job_expired_total",
+		Help:      "Counter for number of jobs deleted for being older than the maximum message age.",
+	})
+
+	scheduleHopTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "goller",
+		Name:      "schedule_hop_total",
+		Help:      "Counter for number of scheduled jobs sent back to SQS as they were not yet due.",
+	})
+
+	shutdownReleasedTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "goller",
+		Name:      "shutdown_released_total",
+		Help:      "Counter for number of unfinished jobs released back to SQS when the shutdown deadline was reached.",
+	})
+
 	jobErrorTotal = prometheus.NewCounter(prometheus.CounterOpts{
 		Namespace: "goller",
 		Name:      "job_error_total",
 		Help:      "Counter for number of errors when calling job handler.",
 	})
+
+	// Batch
+	batchSize = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "goller",
+		Name:      "batch_size",
+		Help:      "Number of jobs passed to the last batch handler.",
+	})
+
+	// Concurrency
+	concurrencyLimit = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "goller",
+		Name:      "concurrency_limit",
+		Help:      "Number of handlers allowed to run at once by the concurrency limiter.",
+	})
+
+	concurrencyInFlight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "goller",
+		Name:      "concurrency_in_flight",
+		Help:      "Number of handlers running under the concurrency limiter.",
+	})
+
+	// Rate limiting
+	rateLimitWaitedTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "goller",
+		Name:      "rate_limit_waited_total",
+		Help:      "Counter for number of jobs that waited on the rate limiter.",
+	})
+
+	rateLimitReleasedTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "goller",
+		Name:      "rate_limit_released_total",
+		Help:      "Counter for number of jobs released back to SQS by the rate limiter.",
+	})
+
+	rateLimitWaitTimer = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "goller",
+		Name:      "rate_limit_wait_timer",
+		Help:      "Time a job last waited on the rate limiter.",
+	})
+
+	// Circuit breaker
+	circuitState = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "goller",
+		Name:      "circuit_state",
+		Help:      "State of the circuit breaker. 0 closed, 1 half-open, 2 open.",
+	})
+
+	circuitOpenedTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "goller",
+		Name:      "circuit_opened_total",
+		Help:      "Counter for number of times the circuit breaker opened.",
+	})
+
+	circuitRequeuedTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "goller",
+		Name:      "circuit_requeued_total",
+		Help:      "Counter for number of jobs sent back to SQS while the circuit was open.",
+	})
+
+	// Idempotency
+	idempotencyDuplicateTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "goller",
+		Name:      "idempotency_duplicate_total",
+		Help:      "Counter for number of duplicate jobs skipped.",
+	})
+
+	idempotencyStoreErrorTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "goller",
+		Name:      "idempotency_store_error_total",
+		Help:      "Counter for number of errors when talking to the idempotency store.",
+	})
+
+	// Workflow
+	workflowFollowUpTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "goller",
+		Name:      "workflow_follow_up_total",
+		Help:      "Counter for number of follow-up messages published by workflow handlers.",
+	})
+
+	// Dry-run
+	dryRunProcessedTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "goller",
+		Name:      "dry_run_processed_total",
+		Help:      "Counter for number of jobs handled successfully in dry-run mode.",
+	})
+
+	dryRunErrorTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "goller",
+		Name:      "dry_run_error_total",
+		Help:      "Counter for number of jobs that failed in dry-run mode.",
+	})
+
+	dryRunDeleteTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "goller",
+		Name:      "dry_run_delete_total",
+		Help:      "Counter for number of deletes recorded, but not sent, in dry-run mode.",
+	})
+
+	dryRunReleaseTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "goller",
+		Name:      "dry_run_release_total",
+		Help:      "Counter for number of releases recorded, but not sent, in dry-run mode.",
+	})
+
+	dryRunBackoffTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "goller",
+		Name:      "dry_run_backoff_total",
+		Help:      "Counter for number of backoffs recorded, but not sent, in dry-run mode.",
+	})
+
+	// Recording
+	recordedTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "goller",
+		Name:      "recorded_total",
+		Help:      "Counter for number of jobs written to a recording.",
+	})
+
+	// Publisher
+	sqsPublishTimer = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "goller",
+		Name:      "sqs_publish_timer",
+		Help:      "Time it takes to receive a response back from the SQS send message request.",
+	})
+
+	publishedTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "goller",
+		Name:      "published_total",
+		Help:      "Counter for number of messages sent to SQS.",
+	})
+
+	publishErrorTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "goller",
+		Name:      "publish_error_total",
+		Help:      "Counter for number of errors when sending messages to SQS.",
+	})
 )
 
 func init() {
@@ -66,4 +219,46 @@ func init() {
 	prometheus.MustRegister(jobProcessedTotal)
 	prometheus.MustRegister(jobPanicTotal)
 	prometheus.MustRegister(jobErrorTotal)
+	prometheus.MustRegister(jobExpiredTotal)
+	prometheus.MustRegister(scheduleHopTotal)
+	prometheus.MustRegister(shutdownReleasedTotal)
+
+	// Batch
+	prometheus.MustRegister(batchSize)
+
+	// Concurrency
+	prometheus.MustRegister(concurrencyLimit)
+	prometheus.MustRegister(concurrencyInFlight)
+
+	// Rate limiting
+	prometheus.MustRegister(rateLimitWaitedTotal)
+	prometheus.MustRegister(rateLimitReleasedTotal)
+	prometheus.MustRegister(rateLimitWaitTimer)
+
+	// Circuit breaker
+	prometheus.MustRegister(circuitState)
+	prometheus.MustRegister(circuitOpenedTotal)
+	prometheus.MustRegister(circuitRequeuedTotal)
+
+	// Idempotency
+	prometheus.MustRegister(idempotencyDuplicateTotal)
+	prometheus.MustRegister(idempotencyStoreErrorTotal)
+
+	// Workflow
+	prometheus.MustRegister(workflowFollowUpTotal)
+
+	// Dry-run
+	prometheus.MustRegister(dryRunProcessedTotal)
+	prometheus.MustRegister(dryRunErrorTotal)
+	prometheus.MustRegister(dryRunDeleteTotal)
+	prometheus.MustRegister(dryRunReleaseTotal)
+	prometheus.MustRegister(dryRunBackoffTotal)
+
+	// Recording
+	prometheus.MustRegister(recordedTotal)
+
+	// Publisher
+	prometheus.MustRegister(sqsPublishTimer)
+	prometheus.MustRegister(publishedTotal)
+	prometheus.MustRegister(publishErrorTotal)
 }
@@ -0,0 +1,140 @@
+package goller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/sqsiface"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Publisher sends messages to the SQS queue that Goller consumes from.
+// The trace context is written to the message attributes so the consumer
+// span is linked to the producer.
+type Publisher interface {
+	WithTracerProvider(tp trace.TracerProvider)
+	Publish(ctx context.Context, msg Message) (string, error)
+}
+
+// MaxMessageAttributes is the most attributes SQS allows on a message.
+//
+// Goller's own attributes count towards it:
+//   - tracing adds traceparent, & tracestate when set (up to 2)
+//   - NotBefore adds NotBeforeAttribute (1)
+//   - workflow follow-ups add CorrelationIDAttribute, ParentIDAttribute & StepAttribute (3)
+//
+// Workers sending a copy of the message back to the queue, i.e. scheduled jobs & CircuitRequeue, add
+// HopsAttribute, SentAtAttribute & TriesAttribute (up to 3). A copy over the limit can't be sent,
+// so the job is released instead.
+const MaxMessageAttributes = 10
+
+// ErrTooManyAttributes is returned by Publish when the message would go over MaxMessageAttributes.
+var ErrTooManyAttributes = errors.New("too many message attributes")
+
+// Message is sent to SQS by a Publisher.
+type Message struct {
+	// The payload of the job.
+	Body string
+
+	// Custom attributes sent alongside the body.
+	// Goller's attributes share the SQS limit, see MaxMessageAttributes.
+	Attributes map[string]sqs.MessageAttributeValue
+
+	// The number of seconds to delay the message.
+	// Max 15 minutes. Zero value disables setting.
+	DelaySeconds int64
+
+	// Schedules the job, however far in the future.
+	// Workers send the message back to the queue until it's due.
+	// Overrides DelaySeconds. Zero value disables setting.
+	NotBefore time.Time
+}
+
+// NewPublisher sends messages to the queue URL.
+func NewPublisher(svc sqsiface.SQSAPI, queueURL string) Publisher {
+	p := &sqsPublisher{
+		queueURL: queueURL,
+		svc:      svc,
+	}
+	p.WithTracerProvider(otel.GetTracerProvider())
+
+	return p
+}
+
+type sqsPublisher struct {
+	queueURL string
+	svc      sqsiface.SQSAPI
+	tracer   trace.Tracer
+}
+
+// WithTracerProvider overrides the global OpenTelemetry tracer provider.
+func (p *sqsPublisher) WithTracerProvider(tp trace.TracerProvider) {
+	p.tracer = tp.Tracer(tracerName, trace.WithInstrumentationVersion(VERSION))
+}
+
+// Publish the message returning the ID given to it by SQS.
+func (p *sqsPublisher) Publish(ctx context.Context, msg Message) (string, error) {
+	ctx, span := p.tracer.Start(
+		ctx,
+		queueName(p.queueURL)+" publish",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(messagingAttributes(p.queueURL, "publish")...),
+	)
+	defer span.End()
+
+	// Copy so the callers attributes aren't changed underneath them
+	attrs := make(map[string]sqs.MessageAttributeValue, len(msg.Attributes)+3)
+	for k, v := range msg.Attributes {
+		attrs[k] = v
+	}
+	tracePropagator.Inject(ctx, messageCarrier(attrs))
+
+	if !msg.NotBefore.IsZero() {
+		msg.DelaySeconds = notBeforeAttributes(attrs, msg.NotBefore)
+	}
+
+	if len(attrs) > MaxMessageAttributes {
+		err := fmt.Errorf("%w: %d of %d, including Goller's own", ErrTooManyAttributes, len(attrs), MaxMessageAttributes)
+		publishErrorTotal.Inc()
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return "", err
+	}
+
+	input := &sqs.SendMessageInput{
+		MessageAttributes: attrs,
+		MessageBody:       aws.String(msg.Body),
+		QueueUrl:          aws.String(p.queueURL),
+	}
+	if msg.DelaySeconds > 0 {
+		input.DelaySeconds = aws.Int64(msg.DelaySeconds)
+	}
+
+	req := p.svc.SendMessageRequest(input)
+	req.SetContext(ctx)
+
+	start := time.Now()
+	resp, err := req.Send()
+	sqsPublishTimer.Set(time.Since(start).Seconds())
+
+	if err != nil {
+		publishErrorTotal.Inc()
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return "", err
+	}
+
+	publishedTotal.Inc()
+	span.SetAttributes(attribute.String("messaging.message.id", aws.StringValue(resp.MessageId)))
+
+	return aws.StringValue(resp.MessageId), nil
+}
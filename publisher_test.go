@@ -0,0 +1,42 @@
+package goller_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/rcrowe/goller"
+)
+
+func attributes(count int) map[string]sqs.MessageAttributeValue {
+	attrs := make(map[string]sqs.MessageAttributeValue, count)
+	for i := 0; i < count; i++ {
+		attrs[fmt.Sprintf("attr-%d", i)] = sqs.MessageAttributeValue{DataType: aws.String("String"), StringValue: aws.String("value")}
+	}
+
+	return attrs
+}
+
+func TestPublishTooManyAttributes(t *testing.T) {
+	svc := &sendSQSClient{Output: sqs.SendMessageOutput{MessageId: aws.String("abc")}}
+	p := goller.NewPublisher(svc, "https://sqs/123/some-queue")
+
+	// Goller's not before attribute takes the last one
+	msg := goller.Message{Body: "hello", Attributes: attributes(9), NotBefore: time.Now().Add(time.Hour)}
+	if _, err := p.Publish(context.Background(), msg); err != nil {
+		t.Fatalf("expected `10` attributes to be published but got `%s`", err)
+	}
+
+	svc.Input = nil
+	msg.Attributes = attributes(10)
+	if _, err := p.Publish(context.Background(), msg); !errors.Is(err, goller.ErrTooManyAttributes) {
+		t.Errorf("expected `%s` but got `%v`", goller.ErrTooManyAttributes, err)
+	}
+	if svc.Input != nil {
+		t.Error("expected message not to be sent")
+	}
+}
@@ -0,0 +1,187 @@
+package goller
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"time"
+)
+
+// RateLimitMode decides what happens to a job over the rate limit.
+type RateLimitMode int
+
+const (
+	// RateLimitWait blocks the handler until a token is available.
+	// Jobs still waiting when the context is done are released back to the queue straight away.
+	RateLimitWait RateLimitMode = iota
+
+	// RateLimitRelease puts the job back on the queue until a token should be available.
+	// The job is received again once released, which counts towards Tries() & the queue's redrive policy;
+	// set maxReceiveCount with that in mind, or use RateLimitWait.
+	RateLimitRelease
+)
+
+// ErrInvalidRateLimit is returned when the rate isn't above 0.
+var ErrInvalidRateLimit = errors.New("rate limit must be above 0")
+
+// RateLimit configures a token bucket.
+type RateLimit struct {
+	// Number of jobs allowed per second.
+	// Must be above 0.
+	Rate float64
+
+	// Number of jobs allowed in a single burst.
+	// Default 1.
+	Burst int
+
+	// Optional, gives each key its own bucket; i.e. a tenant ID.
+	// See AttributeKey(...).
+	Key func(j Job) string
+
+	// What to do with jobs over the limit.
+	// Default RateLimitWait.
+	Mode RateLimitMode
+}
+
+// AttributeKey rate limits on the value of the message attribute.
+func AttributeKey(attr string) func(j Job) string {
+	return func(j Job) string {
+		v, _ := j.Attribute(attr)
+		return v
+	}
+}
+
+// maxRateLimitKeys is the number of buckets kept before idle ones are removed.
+const maxRateLimitKeys = 10000
+
+// RateLimiter limits the number of handler calls using token buckets.
+// It can be used across the whole worker with ConsumerConfig.RateLimiter,
+// or wrap individual handlers with Middleware.
+type RateLimiter struct {
+	cfg     RateLimit
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter creates a token bucket rate limiter.
+// Returns ErrInvalidRateLimit when the rate isn't above 0.
+func NewRateLimiter(cfg RateLimit) (*RateLimiter, error) {
+	if cfg.Rate <= 0 || math.IsNaN(cfg.Rate) || math.IsInf(cfg.Rate, 0) {
+		return nil, ErrInvalidRateLimit
+	}
+	if cfg.Burst < 1 {
+		cfg.Burst = 1
+	}
+
+	return &RateLimiter{
+		cfg:     cfg,
+		buckets: make(map[string]*tokenBucket),
+	}, nil
+}
+
+// Middleware applies the rate limit to the handler.
+func (l *RateLimiter) Middleware(next HandlerFunc) HandlerFunc {
+	return func(ctx context.Context, j Job) error {
+		key := ""
+		if l.cfg.Key != nil {
+			key = l.cfg.Key(j)
+		}
+
+		reserve := l.cfg.Mode == RateLimitWait
+		wait := l.take(key, reserve, time.Now())
+		if wait <= 0 {
+			return next(ctx, j)
+		}
+
+		logger := LoggerFromContext(ctx).WithFields(Fields{
+			"key":  key,
+			"wait": wait,
+		})
+
+		if !reserve {
+			logger.Debug("rate limited. releasing job")
+			rateLimitReleasedTotal.Inc()
+
+			return j.Release(int64(math.Ceil(wait.Seconds())))
+		}
+
+		logger.Debug("rate limited. waiting")
+		rateLimitWaitedTotal.Inc()
+		rateLimitWaitTimer.Set(wait.Seconds())
+
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+
+		select {
+		case <-ctx.Done():
+			// The job wasn't handled, so give back the token it reserved & put it back on the queue
+			l.refund(key)
+
+			logger.Debug("context done waiting for the rate limit. releasing job")
+			if err := releaseJob(j, logger); err != nil {
+				logger.WithError(err).Error("unable to release job")
+			}
+			return ctx.Err()
+		case <-timer.C:
+			return next(ctx, j)
+		}
+	}
+}
+
+// take a token from the bucket, returning how long until it's available.
+// Reserving takes the token even when it's not yet available.
+func (l *RateLimiter) take(key string, reserve bool, now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b, ok := l.buckets[key]
+	if !ok {
+		if len(l.buckets) >= maxRateLimitKeys {
+			l.prune(now)
+		}
+
+		b = &tokenBucket{tokens: float64(l.cfg.Burst), last: now}
+		l.buckets[key] = b
+	}
+
+	// Refill
+	b.tokens = math.Min(float64(l.cfg.Burst), b.tokens+now.Sub(b.last).Seconds()*l.cfg.Rate)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0
+	}
+
+	wait := time.Duration((1 - b.tokens) / l.cfg.Rate * float64(time.Second))
+	if reserve {
+		b.tokens--
+	}
+
+	return wait
+}
+
+// refund a reserved token that wasn't used.
+func (l *RateLimiter) refund(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if b, ok := l.buckets[key]; ok {
+		b.tokens = math.Min(float64(l.cfg.Burst), b.tokens+1)
+	}
+}
+
+// prune removes buckets that would have refilled, as they're the same as a new bucket.
+func (l *RateLimiter) prune(now time.Time) {
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.cfg.Rate >= float64(l.cfg.Burst) {
+			delete(l.buckets, key)
+		}
+	}
+}
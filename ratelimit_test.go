@@ -0,0 +1,201 @@
+package goller_test
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/rcrowe/goller"
+)
+
+func TestRateLimitWait(t *testing.T) {
+	msgs := []sqs.Message{}
+	for i := 0; i < 3; i++ {
+		msgs = append(msgs, sqs.Message{MessageId: aws.String(fmt.Sprintf("msg-%d", i))})
+	}
+	svc := &receiveSQSClient{Output: sqs.ReceiveMessageOutput{Messages: msgs}}
+
+	cfg := goller.NewDefaultConfig("foo", 1)
+	cfg.RunOnce()
+	limiter, err := goller.NewRateLimiter(goller.RateLimit{Rate: 50})
+	if err != nil {
+		t.Fatalf("expected no error but got `%s`", err)
+	}
+	cfg.Consumer.RateLimiter = limiter
+
+	var calls int32
+	start := time.Now()
+
+	w := goller.NewFromConfig(svc, cfg)
+	w.Listen(context.Background(), func(ctx context.Context, j goller.Job) error {
+		atomic.AddInt32(&calls, 1)
+		return nil
+	})
+
+	// First is free, then 20ms per token
+	if elapsed := time.Since(start); elapsed < 35*time.Millisecond {
+		t.Errorf("expected rate limit to take at least 35ms but took `%s`", elapsed)
+	}
+	if calls != 3 {
+		t.Errorf("expected all `3` jobs to be handled but got `%d`", calls)
+	}
+}
+
+func TestRateLimitWaitContextDone(t *testing.T) {
+	limiter, err := goller.NewRateLimiter(goller.RateLimit{Rate: 0.1})
+	if err != nil {
+		t.Fatalf("expected no error but got `%s`", err)
+	}
+	handler := limiter.Middleware(func(ctx context.Context, j goller.Job) error {
+		return nil
+	})
+
+	cfg := goller.NewDefaultConfig("foo", 1)
+	j := goller.NewJob(cfg, goller.NewNopLogger(), sqs.Message{MessageId: aws.String("123")}, newQueueSQSClient(0))
+
+	if err := handler(context.Background(), j); err != nil {
+		t.Fatalf("expected first call to pass but got `%s`", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := handler(ctx, j); err != context.Canceled {
+		t.Errorf("expected context.Canceled but got `%v`", err)
+	}
+	if !j.Handled() {
+		t.Error("expected job to be released")
+	}
+}
+
+func TestRateLimitWaitReleasesOnContextDone(t *testing.T) {
+	svc := newQueueSQSClient(2)
+
+	cfg := goller.NewDefaultConfig("foo", 1)
+	cfg.RunOnce()
+	cfg.Consumer.RetrievalMaxNumberOfMessages = 2
+	limiter, err := goller.NewRateLimiter(goller.RateLimit{Rate: 0.1})
+	if err != nil {
+		t.Fatalf("expected no error but got `%s`", err)
+	}
+	cfg.Consumer.RateLimiter = limiter
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	var calls int32
+	goller.NewFromConfig(svc, cfg).Listen(ctx, func(ctx context.Context, j goller.Job) error {
+		atomic.AddInt32(&calls, 1)
+		return j.Delete()
+	})
+
+	// The job waiting on a token is put back on the queue straight away
+	if calls != 1 {
+		t.Errorf("expected `1` job to be handled but got `%d`", calls)
+	}
+	if released := svc.Released(); len(released) != 1 {
+		t.Errorf("expected the waiting job to be released but got `%v`", released)
+	}
+}
+
+func TestRateLimitWaitRefundsOnContextDone(t *testing.T) {
+	limiter, err := goller.NewRateLimiter(goller.RateLimit{Rate: 10})
+	if err != nil {
+		t.Fatalf("expected no error but got `%s`", err)
+	}
+	handler := limiter.Middleware(func(ctx context.Context, j goller.Job) error {
+		return nil
+	})
+
+	cfg := goller.NewDefaultConfig("foo", 1)
+	j := goller.NewJob(cfg, goller.NewNopLogger(), sqs.Message{MessageId: aws.String("123")}, newQueueSQSClient(0))
+
+	if err := handler(context.Background(), j); err != nil {
+		t.Fatalf("expected first call to pass but got `%s`", err)
+	}
+
+	// Gives up on its reserved token
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := handler(ctx, j); err != context.DeadlineExceeded {
+		t.Fatalf("expected context.DeadlineExceeded but got `%v`", err)
+	}
+
+	// A token is 100ms, so without the refund this would wait another token
+	time.Sleep(110 * time.Millisecond)
+
+	start := time.Now()
+	if err := handler(context.Background(), j); err != nil {
+		t.Fatalf("expected no error but got `%s`", err)
+	}
+	if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+		t.Errorf("expected refunded token to be used straight away but waited `%s`", elapsed)
+	}
+}
+
+func TestRateLimitInvalid(t *testing.T) {
+	for _, rate := range []float64{0, -1, math.NaN(), math.Inf(1)} {
+		if _, err := goller.NewRateLimiter(goller.RateLimit{Rate: rate}); err != goller.ErrInvalidRateLimit {
+			t.Errorf("expected ErrInvalidRateLimit for rate `%v` but got `%v`", rate, err)
+		}
+	}
+}
+
+func TestRateLimitReleasePerKey(t *testing.T) {
+	cfg := goller.NewDefaultConfig("foo", 1)
+	limiter, err := goller.NewRateLimiter(goller.RateLimit{
+		Rate: 0.05,
+		Key:  goller.AttributeKey("tenant"),
+		Mode: goller.RateLimitRelease,
+	})
+	if err != nil {
+		t.Fatalf("expected no error but got `%s`", err)
+	}
+
+	calls := map[string]int{}
+	handler := limiter.Middleware(func(ctx context.Context, j goller.Job) error {
+		tenant, _ := j.Attribute("tenant")
+		calls[tenant]++
+		return nil
+	})
+
+	job := func(tenant string) (goller.Job, *releaseSQSClient) {
+		svc := &releaseSQSClient{}
+		msg := sqs.Message{
+			MessageId: aws.String("123"),
+			MessageAttributes: map[string]sqs.MessageAttributeValue{
+				"tenant": {DataType: aws.String("String"), StringValue: aws.String(tenant)},
+			},
+		}
+		return goller.NewJob(cfg, goller.NewNopLogger(), msg, svc), svc
+	}
+
+	// Each tenant gets their own bucket
+	for _, tenant := range []string{"a", "b"} {
+		j, _ := job(tenant)
+		if err := handler(context.Background(), j); err != nil {
+			t.Fatalf("expected no error but got `%s`", err)
+		}
+	}
+
+	// Tenant a is now over the limit, token in 20 seconds
+	j, svc := job("a")
+	if err := handler(context.Background(), j); err != nil {
+		t.Fatalf("expected no error but got `%s`", err)
+	}
+
+	if calls["a"] != 1 || calls["b"] != 1 {
+		t.Errorf("expected each tenant to be handled once but got `%v`", calls)
+	}
+	if !j.Handled() {
+		t.Error("expected rate limited job to be released")
+	}
+	if timeout := aws.Int64Value(svc.Input.VisibilityTimeout); timeout != 20 {
+		t.Errorf("expected job to be released for `20` seconds but got `%d`", timeout)
+	}
+}
@@ -0,0 +1,114 @@
+package goller
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+)
+
+// RecordedMessage is a line of a recording, see NewRecordMiddleware(...) & Replay(...).
+type RecordedMessage struct {
+	ID               string                    `json:"id"`
+	Body             string                    `json:"body"`
+	Attributes       map[string]AttributeValue `json:"attributes,omitempty"`
+	SystemAttributes map[string]string         `json:"system_attributes,omitempty"`
+	ReceivedAt       time.Time                 `json:"received_at"`
+}
+
+// SQS system attributes that are recorded, when they were requested.
+var recordedSystemAttributes = []string{
+	string(sqs.MessageSystemAttributeNameSenderId),
+	string(sqs.MessageSystemAttributeNameSentTimestamp),
+	string(sqs.MessageSystemAttributeNameApproximateReceiveCount),
+	string(sqs.MessageSystemAttributeNameApproximateFirstReceiveTimestamp),
+	string(sqs.MessageSystemAttributeNameSequenceNumber),
+	string(sqs.MessageSystemAttributeNameMessageDeduplicationId),
+	string(sqs.MessageSystemAttributeNameMessageGroupId),
+	AWSTraceHeaderAttribute,
+}
+
+// RecordConfig configures the record middleware.
+type RecordConfig struct {
+	// Where the recording is written, a JSON line per message. Writes are serialised across consumers.
+	Writer io.Writer
+
+	// Optional, only jobs it returns true for are recorded; i.e. a single tenant.
+	// Zero value records every job.
+	Filter func(j Job) bool
+}
+
+// NewRecordMiddleware writes each job to the recording before it's handled, so it can be replayed later.
+// Recording never stops the job being handled; failed writes are logged.
+//
+// The recording holds message bodies as they are, keep it as safe as the queue itself.
+func NewRecordMiddleware(cfg RecordConfig) Middleware {
+	var mu sync.Mutex
+	enc := json.NewEncoder(cfg.Writer)
+
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, j Job) error {
+			if cfg.Filter == nil || cfg.Filter(j) {
+				mu.Lock()
+				err := enc.Encode(recordMessage(j))
+				mu.Unlock()
+
+				if err != nil {
+					LoggerFromContext(ctx).WithError(err).Error("unable to record job")
+				} else {
+					recordedTotal.Inc()
+				}
+			}
+
+			return next(ctx, j)
+		}
+	}
+}
+
+func recordMessage(j Job) RecordedMessage {
+	body, _ := j.Body()
+
+	rec := RecordedMessage{
+		ID:               j.ID(),
+		Body:             body,
+		Attributes:       j.Attributes(),
+		SystemAttributes: make(map[string]string),
+		ReceivedAt:       time.Now(),
+	}
+
+	// Kept apart from message attributes, which may share a name
+	for _, name := range recordedSystemAttributes {
+		if v, ok := j.SystemAttribute(name); ok {
+			rec.SystemAttributes[name] = v
+		}
+	}
+
+	return rec
+}
+
+// message rebuilds the SQS message that was recorded.
+func (rec RecordedMessage) message() sqs.Message {
+	msg := sqs.Message{
+		MessageId:         aws.String(rec.ID),
+		ReceiptHandle:     aws.String("replay-" + rec.ID),
+		Body:              aws.String(rec.Body),
+		Attributes:        rec.SystemAttributes,
+		MessageAttributes: make(map[string]sqs.MessageAttributeValue, len(rec.Attributes)),
+	}
+
+	for name, attr := range rec.Attributes {
+		v := sqs.MessageAttributeValue{DataType: aws.String(attr.DataType)}
+		if attr.Type() == AttributeTypeBinary {
+			v.BinaryValue = attr.BinaryValue
+		} else {
+			v.StringValue = aws.String(attr.StringValue)
+		}
+		msg.MessageAttributes[name] = v
+	}
+
+	return msg
+}
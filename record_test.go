@@ -0,0 +1,162 @@
+package goller_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/rcrowe/goller"
+)
+
+func recordedJob(id string) goller.Job {
+	msg := sqs.Message{
+		MessageId: aws.String(id),
+		Body:      aws.String(`{"order": "` + id + `"}`),
+		Attributes: map[string]string{
+			"ApproximateReceiveCount": "3",
+			"SentTimestamp":           "1500000000000",
+			"MessageGroupId":          "group",
+		},
+		MessageAttributes: map[string]sqs.MessageAttributeValue{
+			"tenant": {DataType: aws.String("String"), StringValue: aws.String("acme")},
+			"count":  {DataType: aws.String("Number.int"), StringValue: aws.String("5")},
+			"blob":   {DataType: aws.String("Binary"), BinaryValue: []byte{0, 1, 2}},
+		},
+	}
+
+	return goller.NewJob(goller.NewDefaultConfig(testQueue, 1), goller.NewNopLogger(), msg, nil)
+}
+
+func TestRecordMiddleware(t *testing.T) {
+	buf := &bytes.Buffer{}
+	called := false
+
+	handler := goller.NewRecordMiddleware(goller.RecordConfig{Writer: buf})(func(ctx context.Context, j goller.Job) error {
+		called = true
+		return errors.New("handler errored")
+	})
+
+	if err := handler(context.Background(), recordedJob("1")); err == nil {
+		t.Fatal("expected the handler error to be returned")
+	}
+	if !called {
+		t.Fatal("expected the handler to be called")
+	}
+
+	var rec goller.RecordedMessage
+	if err := json.Unmarshal(buf.Bytes(), &rec); err != nil {
+		t.Fatal(err)
+	}
+
+	if rec.ID != "1" || rec.Body != `{"order": "1"}` {
+		t.Fatalf("unexpected message recorded %+v", rec)
+	}
+	if attr := rec.Attributes["blob"]; attr.DataType != "Binary" || !bytes.Equal(attr.BinaryValue, []byte{0, 1, 2}) {
+		t.Fatalf("expected binary attribute recorded, got %+v", attr)
+	}
+	if rec.SystemAttributes["ApproximateReceiveCount"] != "3" || rec.SystemAttributes["MessageGroupId"] != "group" {
+		t.Fatalf("expected system attributes recorded, got %v", rec.SystemAttributes)
+	}
+	if rec.ReceivedAt.IsZero() {
+		t.Fatal("expected received time")
+	}
+}
+
+func TestRecordMiddlewareAttributeSharesName(t *testing.T) {
+	buf := &bytes.Buffer{}
+	msg := sqs.Message{
+		MessageId:  aws.String("1"),
+		Body:       aws.String("hello"),
+		Attributes: map[string]string{"SenderId": "AIDA"},
+		MessageAttributes: map[string]sqs.MessageAttributeValue{
+			"SenderId": {DataType: aws.String("String"), StringValue: aws.String("spoofed")},
+		},
+	}
+	j := goller.NewJob(goller.NewDefaultConfig(testQueue, 1), goller.NewNopLogger(), msg, nil)
+
+	handler := goller.NewRecordMiddleware(goller.RecordConfig{Writer: buf})(func(ctx context.Context, j goller.Job) error {
+		return nil
+	})
+	handler(context.Background(), j)
+
+	var rec goller.RecordedMessage
+	if err := json.Unmarshal(buf.Bytes(), &rec); err != nil {
+		t.Fatal(err)
+	}
+
+	if rec.SystemAttributes["SenderId"] != "AIDA" {
+		t.Errorf("expected system attribute to be recorded but got `%v`", rec.SystemAttributes)
+	}
+	if attr := rec.Attributes["SenderId"]; attr.StringValue != "spoofed" {
+		t.Errorf("expected message attribute to be recorded but got `%+v`", attr)
+	}
+}
+
+func TestRecordMiddlewareFilter(t *testing.T) {
+	buf := &bytes.Buffer{}
+
+	handler := goller.NewRecordMiddleware(goller.RecordConfig{
+		Writer: buf,
+		Filter: func(j goller.Job) bool { return j.ID() == "2" },
+	})(func(ctx context.Context, j goller.Job) error { return nil })
+
+	for _, id := range []string{"1", "2", "3"} {
+		handler(context.Background(), recordedJob(id))
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1 || !strings.Contains(lines[0], `"id":"2"`) {
+		t.Fatalf("expected only job 2 recorded, got %v", lines)
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestRecordMiddlewareWriteFailure(t *testing.T) {
+	called := false
+
+	handler := goller.NewRecordMiddleware(goller.RecordConfig{Writer: failingWriter{}})(func(ctx context.Context, j goller.Job) error {
+		called = true
+		return nil
+	})
+
+	if err := handler(context.Background(), recordedJob("1")); err != nil {
+		t.Fatal(err)
+	}
+	if !called {
+		t.Fatal("expected the job to be handled even though recording failed")
+	}
+}
+
+func TestRecordMiddlewareConcurrent(t *testing.T) {
+	buf := &bytes.Buffer{}
+	handler := goller.NewRecordMiddleware(goller.RecordConfig{Writer: buf})(func(ctx context.Context, j goller.Job) error { return nil })
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			handler(context.Background(), recordedJob("1"))
+		}()
+	}
+	wg.Wait()
+
+	// Every line is a whole message
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var rec goller.RecordedMessage
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			t.Fatalf("interleaved line %q: %s", line, err)
+		}
+	}
+}
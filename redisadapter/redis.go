@@ -0,0 +1,99 @@
+// Package redisadapter lets Goller keep idempotency state in Redis,
+// or anything else that speaks the Redis protocol.
+package redisadapter
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"github.com/rcrowe/goller"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	processing = "processing:"
+	completed  = "completed"
+)
+
+// Only remove the lease if it's still held by the token.
+var releaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// Only complete the job if no one else has since taken the lease.
+var completeScript = redis.NewScript(`
+local state = redis.call("GET", KEYS[1])
+if state and state ~= ARGV[1] and state ~= ARGV[2] then
+	return 0
+end
+redis.call("SET", KEYS[1], ARGV[2], "PX", ARGV[3])
+return 1
+`)
+
+// New wraps a *redis.Client, *redis.ClusterClient or *redis.Ring.
+// Keys are namespaced with the prefix.
+func New(client redis.Cmdable, prefix string) goller.IdempotencyStore {
+	return &redisStore{client: client, prefix: prefix}
+}
+
+type redisStore struct {
+	client redis.Cmdable
+	prefix string
+}
+
+func (s *redisStore) Acquire(ctx context.Context, key string, lease time.Duration) (string, error) {
+	key = s.prefix + key
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(b)
+
+	// The key may expire between SETNX & GET, so have one more go
+	for i := 0; i < 2; i++ {
+		ok, err := s.client.SetNX(ctx, key, processing+token, lease).Result()
+		if err != nil {
+			return "", err
+		}
+		if ok {
+			return token, nil
+		}
+
+		state, err := s.client.Get(ctx, key).Result()
+		if err == redis.Nil {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+
+		if state == completed {
+			return "", goller.ErrJobCompleted
+		}
+		return "", goller.ErrJobInProgress
+	}
+
+	return "", goller.ErrJobInProgress
+}
+
+func (s *redisStore) Complete(ctx context.Context, key, token string, ttl time.Duration) error {
+	ok, err := completeScript.Run(ctx, s.client, []string{s.prefix + key}, processing+token, completed, ttl.Milliseconds()).Int()
+	if err != nil {
+		return err
+	}
+	if ok == 0 {
+		return goller.ErrLeaseLost
+	}
+
+	return nil
+}
+
+func (s *redisStore) Release(ctx context.Context, key, token string) error {
+	return releaseScript.Run(ctx, s.client, []string{s.prefix + key}, processing+token).Err()
+}
@@ -0,0 +1,112 @@
+package redisadapter_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/rcrowe/goller"
+	"github.com/rcrowe/goller/redisadapter"
+	"github.com/redis/go-redis/v9"
+)
+
+func newStore(t *testing.T) (*miniredis.Miniredis, goller.IdempotencyStore) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	return server, redisadapter.New(client, "goller:")
+}
+
+func TestStore(t *testing.T) {
+	server, store := newStore(t)
+	ctx := context.Background()
+
+	token, err := store.Acquire(ctx, "abc", time.Minute)
+	if err != nil {
+		t.Fatalf("expected lease but got `%s`", err)
+	}
+	if _, err := store.Acquire(ctx, "abc", time.Minute); err != goller.ErrJobInProgress {
+		t.Errorf("expected ErrJobInProgress but got `%v`", err)
+	}
+	if !server.Exists("goller:abc") {
+		t.Error("expected key to be prefixed")
+	}
+
+	if err := store.Complete(ctx, "abc", token, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Acquire(ctx, "abc", time.Minute); err != goller.ErrJobCompleted {
+		t.Errorf("expected ErrJobCompleted but got `%v`", err)
+	}
+
+	// Releasing doesn't undo completion
+	if err := store.Release(ctx, "abc", token); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Acquire(ctx, "abc", time.Minute); err != goller.ErrJobCompleted {
+		t.Errorf("expected ErrJobCompleted after release but got `%v`", err)
+	}
+
+	server.FastForward(2 * time.Hour)
+	if _, err := store.Acquire(ctx, "abc", time.Minute); err != nil {
+		t.Errorf("expected lease once completion expired but got `%s`", err)
+	}
+}
+
+func TestStoreRelease(t *testing.T) {
+	server, store := newStore(t)
+	ctx := context.Background()
+
+	token, err := store.Acquire(ctx, "abc", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Release(ctx, "abc", token); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Acquire(ctx, "abc", time.Minute); err != nil {
+		t.Errorf("expected lease after release but got `%s`", err)
+	}
+
+	// Lease expires
+	server.FastForward(2 * time.Minute)
+	if _, err := store.Acquire(ctx, "abc", time.Minute); err != nil {
+		t.Errorf("expected lease once expired but got `%s`", err)
+	}
+}
+
+func TestStoreStaleToken(t *testing.T) {
+	server, store := newStore(t)
+	ctx := context.Background()
+
+	// The first holder's lease expired & was taken by another consumer
+	stale, err := store.Acquire(ctx, "abc", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.FastForward(2 * time.Minute)
+
+	token, err := store.Acquire(ctx, "abc", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Release(ctx, "abc", stale); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Acquire(ctx, "abc", time.Minute); err != goller.ErrJobInProgress {
+		t.Errorf("expected stale release to leave the lease but got `%v`", err)
+	}
+	if err := store.Complete(ctx, "abc", stale, time.Hour); err != goller.ErrLeaseLost {
+		t.Errorf("expected ErrLeaseLost completing with a stale token but got `%v`", err)
+	}
+
+	if err := store.Complete(ctx, "abc", token, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Acquire(ctx, "abc", time.Minute); err != goller.ErrJobCompleted {
+		t.Errorf("expected ErrJobCompleted but got `%v`", err)
+	}
+}
@@ -0,0 +1,383 @@
+package goller
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/sqsiface"
+)
+
+// TriesAttribute holds the number of attempts made on a job before it was redriven from a dead letter queue.
+// Job.Tries() adds it to the receive count, so Backoff() carries on where it left off.
+const TriesAttribute = "goller-tries"
+
+// RedriveConfig configures moving messages from a dead letter queue back to the queue they failed on.
+type RedriveConfig struct {
+	// The dead letter queue messages are moved from.
+	DeadLetterQueueURL string
+
+	// The queue messages are moved to, usually the queue the dead letter queue is attached to.
+	QueueURL string
+
+	// Only move messages with these attribute values.
+	// Zero value disables the setting.
+	Attributes map[string]string
+
+	// Only move messages first sent at least this long ago.
+	// Zero value disables the setting.
+	MinAge time.Duration
+
+	// Only move messages first sent at most this long ago.
+	// Zero value disables the setting.
+	MaxAge time.Duration
+
+	// Only move messages with a matching body.
+	// Zero value disables the setting.
+	Body *regexp.Regexp
+
+	// Maximum number of messages to move.
+	// Zero value disables the setting.
+	Max int64
+
+	// Count & log the messages that would be moved, without moving them.
+	DryRun bool
+
+	// Number of messages moved per second.
+	// Zero value disables the setting.
+	Rate float64
+
+	// Remove the attributes Goller adds when rescheduling a job (not-before & hops),
+	// so the job is handled straight away. Workflow & tracing attributes are kept.
+	StripAttributes bool
+
+	// Start the job's tries again from 0.
+	// By default the tries made before the message was dead-lettered are carried over, see TriesAttribute.
+	ResetTries bool
+
+	// Remembers the messages that have been sent to QueueURL, making an interrupted redrive safe to run again.
+	// Without it, a message sent but not deleted from the dead letter queue is sent again by the next run.
+	// See NewFileIdempotencyStore(...).
+	Checkpoint IdempotencyStore
+
+	// How long messages are hidden on the dead letter queue during the redrive, in seconds.
+	// Messages that aren't moved are made visible again once the redrive finishes.
+	// Should be longer than the redrive takes. Default 5 minutes.
+	VisibilityTimeout int64
+
+	// Default NewNopLogger().
+	Log Logger
+}
+
+// RedriveResult counts what happened to the messages on the dead letter queue.
+type RedriveResult struct {
+	// Messages matching the filters. In a dry-run, the number that would have been moved.
+	Matched int64
+
+	// Messages sent to QueueURL & deleted from the dead letter queue.
+	Moved int64
+
+	// Messages that matched but couldn't be moved, left on the dead letter queue.
+	Failed int64
+
+	// Messages not matching the filters, left on the dead letter queue.
+	Skipped int64
+}
+
+// How long the checkpoint remembers a moved message.
+const redriveCheckpointTTL = 14 * 24 * time.Hour
+
+// Redrive moves messages from the dead letter queue back to the queue, until the dead letter queue is empty,
+// Max messages have been moved or the context is done.
+//
+// Each message is sent as a new copy, keeping its body & attributes, then deleted from the dead letter queue.
+// The moved message starts with a fresh receive count, so it goes through the queue's redrive policy again.
+func Redrive(ctx context.Context, svc sqsiface.SQSAPI, cfg RedriveConfig) (RedriveResult, error) {
+	if cfg.VisibilityTimeout <= 0 {
+		cfg.VisibilityTimeout = 300
+	}
+	if cfg.Log == nil {
+		cfg.Log = NewNopLogger()
+	}
+
+	r := &redriver{
+		cfg:  cfg,
+		svc:  svc,
+		job:  NewDefaultConfig(cfg.DeadLetterQueueURL, 1),
+		seen: make(map[string]bool),
+	}
+	if cfg.Rate > 0 {
+		limiter, err := NewRateLimiter(RateLimit{Rate: cfg.Rate})
+		if err != nil {
+			return RedriveResult{}, err
+		}
+		r.limiter = limiter
+	}
+
+	err := r.run(ctx)
+
+	// Leave everything that wasn't moved as it was found
+	r.release()
+
+	cfg.Log.WithFields(Fields{
+		"matched": r.result.Matched,
+		"moved":   r.result.Moved,
+		"failed":  r.result.Failed,
+		"skipped": r.result.Skipped,
+		"dry_run": cfg.DryRun,
+	}).Info("redrive finished")
+
+	return r.result, err
+}
+
+type redriver struct {
+	cfg     RedriveConfig
+	svc     sqsiface.SQSAPI
+	job     *Config
+	limiter *RateLimiter
+	result  RedriveResult
+
+	// Messages received this run, by ID, so they're only counted once
+	seen map[string]bool
+
+	// Receipt handles of the messages left on the dead letter queue
+	held []string
+}
+
+func (r *redriver) run(ctx context.Context) error {
+	for ctx.Err() == nil {
+		if r.cfg.Max > 0 && r.result.Matched >= r.cfg.Max {
+			return nil
+		}
+
+		req := r.svc.ReceiveMessageRequest(&sqs.ReceiveMessageInput{
+			AttributeNames:        []sqs.QueueAttributeName{sqs.QueueAttributeNameAll},
+			MaxNumberOfMessages:   aws.Int64(10),
+			MessageAttributeNames: []string{"All"},
+			QueueUrl:              aws.String(r.cfg.DeadLetterQueueURL),
+			VisibilityTimeout:     aws.Int64(r.cfg.VisibilityTimeout),
+			WaitTimeSeconds:       aws.Int64(1),
+		})
+
+		resp, err := req.Send()
+		if err != nil {
+			return err
+		}
+
+		if len(resp.Messages) == 0 {
+			return nil
+		}
+
+		for _, msg := range resp.Messages {
+			if err := r.handle(ctx, msg); err != nil {
+				return err
+			}
+		}
+	}
+
+	return ctx.Err()
+}
+
+// handle moves the message if it matches, otherwise holds on to it until the redrive finishes.
+func (r *redriver) handle(ctx context.Context, msg sqs.Message) error {
+	j := NewJob(r.job, r.cfg.Log, msg, r.svc)
+	logger := r.cfg.Log.WithField("jid", j.ID())
+
+	// Visible again after taking longer than the visibility timeout
+	if r.seen[j.ID()] {
+		r.hold(msg)
+		return nil
+	}
+	r.seen[j.ID()] = true
+
+	if !r.matches(j) || (r.cfg.Max > 0 && r.result.Matched >= r.cfg.Max) {
+		r.result.Skipped++
+		r.hold(msg)
+		return nil
+	}
+
+	if r.cfg.DryRun {
+		logger.Info("dry-run. would redrive message")
+		r.result.Matched++
+		r.hold(msg)
+		return nil
+	}
+
+	if err := r.wait(ctx); err != nil {
+		r.hold(msg)
+		return err
+	}
+	r.result.Matched++
+
+	if err := r.move(ctx, j, msg); err != nil {
+		logger.WithError(err).Error("unable to redrive message")
+		r.result.Failed++
+		r.hold(msg)
+		return nil
+	}
+
+	logger.Debug("redrove message")
+	r.result.Moved++
+
+	return nil
+}
+
+func (r *redriver) matches(j Job) bool {
+	for name, value := range r.cfg.Attributes {
+		if v, ok := j.Attribute(name); !ok || v != value {
+			return false
+		}
+	}
+
+	if r.cfg.MinAge > 0 && j.Age() < r.cfg.MinAge {
+		return false
+	}
+	if r.cfg.MaxAge > 0 && j.Age() > r.cfg.MaxAge {
+		return false
+	}
+
+	if r.cfg.Body != nil {
+		body, _ := j.Body()
+		if !r.cfg.Body.MatchString(body) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// wait for the rate limit.
+func (r *redriver) wait(ctx context.Context) error {
+	if r.limiter == nil {
+		return nil
+	}
+
+	wait := r.limiter.take("", true, time.Now())
+	if wait <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// move sends a copy of the message to the queue, then deletes it from the dead letter queue.
+func (r *redriver) move(ctx context.Context, j Job, msg sqs.Message) error {
+	checkpoint := r.cfg.Checkpoint
+
+	sent := false
+	token := ""
+	if checkpoint != nil {
+		var err error
+		token, err = checkpoint.Acquire(ctx, j.ID(), time.Duration(r.cfg.VisibilityTimeout)*time.Second)
+		if errors.Is(err, ErrJobCompleted) {
+			// Sent by an earlier run that didn't get to delete it
+			r.cfg.Log.WithField("jid", j.ID()).Info("message already redriven. deleting from dead letter queue")
+			sent = true
+		} else if err != nil {
+			return err
+		}
+	}
+
+	if !sent {
+		if err := r.send(j, msg); err != nil {
+			if checkpoint != nil {
+				if releaseErr := checkpoint.Release(ctx, j.ID(), token); releaseErr != nil {
+					r.cfg.Log.WithError(releaseErr).Error("unable to release checkpoint")
+				}
+			}
+			return err
+		}
+
+		if checkpoint != nil {
+			if err := checkpoint.Complete(ctx, j.ID(), token, redriveCheckpointTTL); err != nil {
+				r.cfg.Log.WithError(err).Error("unable to checkpoint redriven message. it may be sent again")
+			}
+		}
+	}
+
+	req := r.svc.DeleteMessageRequest(&sqs.DeleteMessageInput{
+		QueueUrl:      aws.String(r.cfg.DeadLetterQueueURL),
+		ReceiptHandle: msg.ReceiptHandle,
+	})
+
+	_, err := req.Send()
+	return err
+}
+
+func (r *redriver) send(j Job, msg sqs.Message) error {
+	input := &sqs.SendMessageInput{
+		MessageAttributes: r.attributes(j, msg),
+		MessageBody:       msg.Body,
+		QueueUrl:          aws.String(r.cfg.QueueURL),
+	}
+
+	// FIFO queues need a group, dedupe on the dead-lettered message so a retried send isn't duplicated
+	if group := j.GroupID(); group != "" {
+		input.MessageGroupId = aws.String(group)
+		input.MessageDeduplicationId = aws.String(j.ID())
+	}
+
+	req := r.svc.SendMessageRequest(input)
+
+	_, err := req.Send()
+	return err
+}
+
+// attributes of the moved message.
+func (r *redriver) attributes(j Job, msg sqs.Message) map[string]sqs.MessageAttributeValue {
+	attrs := make(map[string]sqs.MessageAttributeValue, len(msg.MessageAttributes)+1)
+	for k, v := range msg.MessageAttributes {
+		attrs[k] = v
+	}
+
+	if r.cfg.StripAttributes {
+		delete(attrs, NotBeforeAttribute)
+		delete(attrs, HopsAttribute)
+	}
+
+	// The moved message is sent afresh, so MaxMessageAge doesn't expire it straight away
+	delete(attrs, SentAtAttribute)
+
+	delete(attrs, TriesAttribute)
+	if !r.cfg.ResetTries {
+		setTries(attrs, j)
+	}
+
+	return attrs
+}
+
+// setTries carries the job's tries over to a copy of its message, see TriesAttribute.
+func setTries(attrs map[string]sqs.MessageAttributeValue, j Job) {
+	if tries, err := j.Tries(); err == nil && tries > 0 {
+		attrs[TriesAttribute] = sqs.MessageAttributeValue{
+			DataType:    aws.String(AttributeTypeNumber),
+			StringValue: aws.String(strconv.FormatInt(tries, 10)),
+		}
+	}
+}
+
+func (r *redriver) hold(msg sqs.Message) {
+	r.held = append(r.held, aws.StringValue(msg.ReceiptHandle))
+}
+
+// release the held messages back onto the dead letter queue straight away.
+func (r *redriver) release() {
+	if len(r.held) == 0 {
+		return
+	}
+
+	releaseMessages(r.svc, r.cfg.DeadLetterQueueURL, r.cfg.Log, r.held)
+	r.held = nil
+}
@@ -0,0 +1,333 @@
+package goller_test
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/rcrowe/goller"
+)
+
+const (
+	testDLQ   = "https://queue/dlq"
+	testQueue = "https://queue/jobs"
+)
+
+// deadLetter is a message on the dead letter queue, sent age ago & received the number of times.
+func deadLetter(id, body string, age time.Duration, receives int, attrs map[string]string) sqs.Message {
+	msg := sqs.Message{
+		MessageId:     aws.String(id),
+		ReceiptHandle: aws.String("handle-" + id),
+		Body:          aws.String(body),
+		Attributes: map[string]string{
+			"SentTimestamp":           strconv.FormatInt(time.Now().Add(-age).UnixNano()/int64(time.Millisecond), 10),
+			"ApproximateReceiveCount": strconv.Itoa(receives),
+		},
+		MessageAttributes: map[string]sqs.MessageAttributeValue{},
+	}
+	for name, value := range attrs {
+		msg.MessageAttributes[name] = sqs.MessageAttributeValue{
+			DataType:    aws.String(goller.AttributeTypeString),
+			StringValue: aws.String(value),
+		}
+	}
+
+	return msg
+}
+
+// failingSendClient fails to send messages with the body.
+type failingSendClient struct {
+	*queueSQSClient
+	body string
+}
+
+func (c *failingSendClient) SendMessageRequest(input *sqs.SendMessageInput) sqs.SendMessageRequest {
+	if aws.StringValue(input.MessageBody) != c.body {
+		return c.queueSQSClient.SendMessageRequest(input)
+	}
+
+	return sqs.SendMessageRequest{
+		Request: &aws.Request{
+			Data:  &sqs.SendMessageOutput{},
+			Error: errors.New("send failed"),
+		},
+	}
+}
+
+func sentBodies(c *queueSQSClient) []string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	bodies := []string{}
+	for _, sent := range c.sent {
+		bodies = append(bodies, aws.StringValue(sent.MessageBody))
+	}
+	return bodies
+}
+
+func TestRedriveFilters(t *testing.T) {
+	svc := newQueueSQSClient(0)
+	svc.messages = []sqs.Message{
+		deadLetter("1", `{"order": 1}`, time.Hour, 4, map[string]string{"tenant": "acme"}),
+		deadLetter("2", `{"order": 2}`, time.Hour, 4, map[string]string{"tenant": "other"}),
+		deadLetter("3", `{"invoice": 3}`, time.Hour, 4, map[string]string{"tenant": "acme"}),
+		deadLetter("4", `{"order": 4}`, time.Minute, 4, map[string]string{"tenant": "acme"}),
+		deadLetter("5", `{"order": 5}`, 2*time.Hour, 4, map[string]string{"tenant": "acme"}),
+	}
+
+	result, err := goller.Redrive(context.Background(), svc, goller.RedriveConfig{
+		DeadLetterQueueURL: testDLQ,
+		QueueURL:           testQueue,
+		Attributes:         map[string]string{"tenant": "acme"},
+		MinAge:             30 * time.Minute,
+		Body:               regexp.MustCompile(`"order"`),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := goller.RedriveResult{Matched: 2, Moved: 2, Skipped: 3}
+	if result != expected {
+		t.Fatalf("expected %+v, got %+v", expected, result)
+	}
+
+	if bodies := sentBodies(svc); len(bodies) != 2 || bodies[0] != `{"order": 1}` || bodies[1] != `{"order": 5}` {
+		t.Fatalf("unexpected messages sent %v", bodies)
+	}
+	if queue := aws.StringValue(svc.sent[0].QueueUrl); queue != testQueue {
+		t.Fatalf("expected message sent to %s, got %s", testQueue, queue)
+	}
+	if len(svc.deleted) != 2 || svc.deleted[0] != "handle-1" || svc.deleted[1] != "handle-5" {
+		t.Fatalf("unexpected messages deleted %v", svc.deleted)
+	}
+	if released := svc.Released(); len(released) != 3 {
+		t.Fatalf("expected skipped messages released back to the dead letter queue, got %v", released)
+	}
+}
+
+func TestRedriveDryRun(t *testing.T) {
+	svc := newQueueSQSClient(3)
+
+	result, err := goller.Redrive(context.Background(), svc, goller.RedriveConfig{
+		DeadLetterQueueURL: testDLQ,
+		QueueURL:           testQueue,
+		DryRun:             true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result != (goller.RedriveResult{Matched: 3}) {
+		t.Fatalf("expected 3 matched, got %+v", result)
+	}
+	if len(svc.sent) != 0 || len(svc.deleted) != 0 {
+		t.Fatalf("expected nothing sent or deleted, got %d sent & %d deleted", len(svc.sent), len(svc.deleted))
+	}
+	if released := svc.Released(); len(released) != 3 {
+		t.Fatalf("expected messages released back to the dead letter queue, got %v", released)
+	}
+}
+
+func TestRedriveMax(t *testing.T) {
+	svc := newQueueSQSClient(25)
+
+	result, err := goller.Redrive(context.Background(), svc, goller.RedriveConfig{
+		DeadLetterQueueURL: testDLQ,
+		QueueURL:           testQueue,
+		Max:                12,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The second receive gets 10 messages, 8 of them over the max
+	expected := goller.RedriveResult{Matched: 12, Moved: 12, Skipped: 8}
+	if result != expected {
+		t.Fatalf("expected %+v, got %+v", expected, result)
+	}
+	if len(svc.Receives()) != 2 {
+		t.Fatalf("expected to stop receiving at the max, got %d receives", len(svc.Receives()))
+	}
+}
+
+func TestRedriveFailed(t *testing.T) {
+	svc := &failingSendClient{queueSQSClient: newQueueSQSClient(3), body: "body-1"}
+
+	result, err := goller.Redrive(context.Background(), svc, goller.RedriveConfig{
+		DeadLetterQueueURL: testDLQ,
+		QueueURL:           testQueue,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := goller.RedriveResult{Matched: 3, Moved: 2, Failed: 1}
+	if result != expected {
+		t.Fatalf("expected %+v, got %+v", expected, result)
+	}
+	if released := svc.Released(); len(released) != 1 || released[0] != "handle-1" {
+		t.Fatalf("expected failed message left on the dead letter queue, got %v", released)
+	}
+}
+
+func TestRedriveAttributes(t *testing.T) {
+	tests := map[string]struct {
+		cfg      goller.RedriveConfig
+		stripped bool
+		tries    string
+	}{
+		"default": {
+			tries: "7",
+		},
+		"strip": {
+			cfg:      goller.RedriveConfig{StripAttributes: true},
+			stripped: true,
+			tries:    "7",
+		},
+		"reset tries": {
+			cfg: goller.RedriveConfig{ResetTries: true},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			// 3 receives on the dead letter queue, plus the redrive, after 4 tries on an earlier redrive
+			svc := newQueueSQSClient(0)
+			svc.messages = []sqs.Message{deadLetter("1", "body", time.Hour, 4, map[string]string{
+				"tenant":                      "acme",
+				goller.CorrelationIDAttribute: "workflow",
+				goller.NotBeforeAttribute:     "1",
+				goller.HopsAttribute:          "3",
+				goller.TriesAttribute:         "4",
+				goller.SentAtAttribute:        "1",
+			})}
+
+			cfg := test.cfg
+			cfg.DeadLetterQueueURL = testDLQ
+			cfg.QueueURL = testQueue
+
+			if _, err := goller.Redrive(context.Background(), svc, cfg); err != nil {
+				t.Fatal(err)
+			}
+
+			attrs := svc.sent[0].MessageAttributes
+			for _, kept := range []string{"tenant", goller.CorrelationIDAttribute} {
+				if _, ok := attrs[kept]; !ok {
+					t.Fatalf("expected %s to be kept", kept)
+				}
+			}
+
+			_, hasNotBefore := attrs[goller.NotBeforeAttribute]
+			_, hasHops := attrs[goller.HopsAttribute]
+			if hasNotBefore == test.stripped || hasHops == test.stripped {
+				t.Fatalf("expected stripped %t, got not-before %t & hops %t", test.stripped, hasNotBefore, hasHops)
+			}
+
+			if tries := aws.StringValue(attrs[goller.TriesAttribute].StringValue); tries != test.tries {
+				t.Fatalf("expected tries %q, got %q", test.tries, tries)
+			}
+
+			// Moved messages start their age again
+			if _, ok := attrs[goller.SentAtAttribute]; ok {
+				t.Fatal("expected sent at to be removed")
+			}
+		})
+	}
+}
+
+func TestRedriveResume(t *testing.T) {
+	checkpoint := goller.NewMemoryIdempotencyStore(10)
+	ctx := context.Background()
+
+	// An earlier run sent message 1, but didn't delete it from the dead letter queue
+	token, err := checkpoint.Acquire(ctx, "msg-1", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := checkpoint.Complete(ctx, "msg-1", token, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	svc := newQueueSQSClient(3)
+	result, err := goller.Redrive(ctx, svc, goller.RedriveConfig{
+		DeadLetterQueueURL: testDLQ,
+		QueueURL:           testQueue,
+		Checkpoint:         checkpoint,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result != (goller.RedriveResult{Matched: 3, Moved: 3}) {
+		t.Fatalf("expected 3 moved, got %+v", result)
+	}
+	if bodies := sentBodies(svc); len(bodies) != 2 || bodies[0] != "body-0" || bodies[1] != "body-2" {
+		t.Fatalf("expected message 1 not to be sent again, got %v", bodies)
+	}
+	if len(svc.deleted) != 3 {
+		t.Fatalf("expected every message deleted, got %v", svc.deleted)
+	}
+
+	// Moved messages are remembered
+	if _, err := checkpoint.Acquire(ctx, "msg-2", time.Minute); err != goller.ErrJobCompleted {
+		t.Fatalf("expected msg-2 to be checkpointed, got %v", err)
+	}
+}
+
+func TestRedriveRate(t *testing.T) {
+	svc := newQueueSQSClient(3)
+
+	start := time.Now()
+	result, err := goller.Redrive(context.Background(), svc, goller.RedriveConfig{
+		DeadLetterQueueURL: testDLQ,
+		QueueURL:           testQueue,
+		Rate:               20,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Moved != 3 {
+		t.Fatalf("expected 3 moved, got %+v", result)
+	}
+
+	// The first is sent straight away, then 1 every 50ms
+	if took := time.Since(start); took < 100*time.Millisecond {
+		t.Fatalf("expected rate limit to slow the redrive, took %s", took)
+	}
+}
+
+func TestRedriveCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	svc := newQueueSQSClient(3)
+	result, err := goller.Redrive(ctx, svc, goller.RedriveConfig{
+		DeadLetterQueueURL: testDLQ,
+		QueueURL:           testQueue,
+	})
+	if err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if result != (goller.RedriveResult{}) {
+		t.Fatalf("expected nothing moved, got %+v", result)
+	}
+}
+
+func TestTriesAfterRedrive(t *testing.T) {
+	msg := deadLetter("1", "body", 0, 3, map[string]string{goller.TriesAttribute: "5"})
+	j := goller.NewJob(goller.NewDefaultConfig(testQueue, 1), goller.NewNopLogger(), msg, nil)
+
+	tries, err := j.Tries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tries != 7 {
+		t.Fatalf("expected 2 tries plus 5 before the redrive, got %d", tries)
+	}
+}
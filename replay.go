@@ -0,0 +1,193 @@
+package goller
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Calls a handler can make on a replayed job.
+const (
+	ReplayDelete  = "delete"
+	ReplayRelease = "release"
+	ReplayBackoff = "backoff"
+)
+
+// ReplayCall is a call the handler made on a replayed job.
+type ReplayCall struct {
+	// ReplayDelete, ReplayRelease or ReplayBackoff.
+	Method string
+
+	// The visibility timeout the job would have been released with, 0 for deletes.
+	Seconds int64
+}
+
+// ReplayJob is a recorded message given to the handler in place of a real job.
+// Nothing is sent to SQS, the calls made on the job are recorded instead.
+type ReplayJob struct {
+	Job
+	cfg     *Config
+	mu      sync.Mutex
+	calls   []ReplayCall
+	handled bool
+}
+
+// NewReplayJob from the recorded message.
+// The config decides how Release & Backoff calculate the visibility timeout, default NewDefaultConfig(...).
+func NewReplayJob(cfg *Config, rec RecordedMessage) *ReplayJob {
+	if cfg == nil {
+		cfg = NewDefaultConfig("", 1)
+	}
+
+	return &ReplayJob{
+		Job: NewJob(cfg, NewNopLogger(), rec.message(), nil),
+		cfg: cfg,
+	}
+}
+
+// Calls made on the job so far.
+func (j *ReplayJob) Calls() []ReplayCall {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return append([]ReplayCall{}, j.calls...)
+}
+
+// Handled returns whether Delete, Release or Backoff has been called.
+func (j *ReplayJob) Handled() bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return j.handled
+}
+
+// Delete records the delete.
+func (j *ReplayJob) Delete() error {
+	return j.record(ReplayDelete, 0)
+}
+
+// Release records the release, clamped to the visibility timeout limits like a real job.
+func (j *ReplayJob) Release(secs int64) error {
+	return j.record(ReplayRelease, j.clamp(secs))
+}
+
+// Backoff records the release the job would have had for its number of tries.
+func (j *ReplayJob) Backoff() error {
+	tries, err := j.Tries()
+	if err != nil {
+		return err
+	}
+
+	return j.record(ReplayBackoff, j.clamp(j.cfg.Job.BackoffCalc(tries)))
+}
+
+// clamp the visibility timeout to the configured limits.
+func (j *ReplayJob) clamp(secs int64) int64 {
+	if secs < j.cfg.Job.MinVisibilityTimeout {
+		return j.cfg.Job.MinVisibilityTimeout
+	}
+	if secs > j.cfg.Job.MaxVisibilityTimeout {
+		return j.cfg.Job.MaxVisibilityTimeout
+	}
+
+	return secs
+}
+
+func (j *ReplayJob) record(method string, secs int64) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.handled {
+		return ErrAlreadyHandled
+	}
+
+	j.handled = true
+	j.calls = append(j.calls, ReplayCall{Method: method, Seconds: secs})
+
+	return nil
+}
+
+// ReplayConfig configures Replay.
+type ReplayConfig struct {
+	// Decides how Release & Backoff calculate the visibility timeout.
+	// Default NewDefaultConfig(...).
+	Config *Config
+
+	// Given to the handler through the context.
+	// Default NewNopLogger().
+	Log Logger
+}
+
+// ReplayResult is what the handler did with a replayed message.
+type ReplayResult struct {
+	Message RecordedMessage
+
+	// Returned by the handler, or the panic it raised.
+	Err error
+
+	// Delete, Release & Backoff calls made on the job.
+	Calls []ReplayCall
+
+	// Whether the job was deleted or released, as a real worker would see it.
+	Handled bool
+}
+
+// Replay feeds each recorded message through the handler, one at a time, using a ReplayJob.
+// Reproduces a production failure locally, without touching SQS.
+//
+// Returns early when the recording can't be read or the context is done.
+func Replay(ctx context.Context, cfg ReplayConfig, r io.Reader, handler HandlerFunc) ([]ReplayResult, error) {
+	if cfg.Log == nil {
+		cfg.Log = NewNopLogger()
+	}
+
+	results := []ReplayResult{}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for line := 1; scanner.Scan(); line++ {
+		if ctx.Err() != nil {
+			return results, ctx.Err()
+		}
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var rec RecordedMessage
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return results, fmt.Errorf("line %d of recording: %w", line, err)
+		}
+
+		j := NewReplayJob(cfg.Config, rec)
+		logger := cfg.Log.WithField("jid", rec.ID)
+
+		err := replayJob(ContextWithLogger(ctx, logger), handler, j)
+		if err != nil {
+			logger.WithError(err).Error("handler errored")
+		}
+
+		results = append(results, ReplayResult{
+			Message: rec,
+			Err:     err,
+			Calls:   j.Calls(),
+			Handled: j.Handled(),
+		})
+	}
+
+	return results, scanner.Err()
+}
+
+// replayJob calls the handler, turning a panic into an error.
+func replayJob(ctx context.Context, handler HandlerFunc, j Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %s", r)
+		}
+	}()
+
+	return handler(ctx, j)
+}
@@ -0,0 +1,166 @@
+package goller_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/rcrowe/goller"
+)
+
+// recording of the jobs, as written by the record middleware.
+func recording(t *testing.T, jobs ...goller.Job) *bytes.Buffer {
+	buf := &bytes.Buffer{}
+	handler := goller.NewRecordMiddleware(goller.RecordConfig{Writer: buf})(func(ctx context.Context, j goller.Job) error { return nil })
+
+	for _, j := range jobs {
+		if err := handler(context.Background(), j); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return buf
+}
+
+func TestReplay(t *testing.T) {
+	buf := recording(t, recordedJob("1"), recordedJob("2"), recordedJob("3"), recordedJob("4"))
+
+	cfg := goller.NewDefaultConfig(testQueue, 1)
+	cfg.Job.BackoffCalc = func(tries int64) int64 { return tries * 100 }
+
+	results, err := goller.Replay(context.Background(), goller.ReplayConfig{Config: cfg}, buf, func(ctx context.Context, j goller.Job) error {
+		switch j.ID() {
+		case "1":
+			return j.Delete()
+		case "2":
+			return j.Release(60)
+		case "3":
+			j.Backoff()
+			return errors.New("failed")
+		default:
+			panic("boom")
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []struct {
+		err     string
+		calls   []goller.ReplayCall
+		handled bool
+	}{
+		{calls: []goller.ReplayCall{{Method: goller.ReplayDelete}}, handled: true},
+		{calls: []goller.ReplayCall{{Method: goller.ReplayRelease, Seconds: 60}}, handled: true},
+		{err: "failed", calls: []goller.ReplayCall{{Method: goller.ReplayBackoff, Seconds: 200}}, handled: true},
+		{err: "panic: boom", calls: []goller.ReplayCall{}},
+	}
+
+	if len(results) != len(expected) {
+		t.Fatalf("expected %d results, got %d", len(expected), len(results))
+	}
+
+	for i, e := range expected {
+		r := results[i]
+
+		err := ""
+		if r.Err != nil {
+			err = r.Err.Error()
+		}
+		if err != e.err {
+			t.Errorf("job %d: expected error %q, got %q", i+1, e.err, err)
+		}
+		if !reflect.DeepEqual(r.Calls, e.calls) {
+			t.Errorf("job %d: expected calls %+v, got %+v", i+1, e.calls, r.Calls)
+		}
+		if r.Handled != e.handled {
+			t.Errorf("job %d: expected handled %t, got %t", i+1, e.handled, r.Handled)
+		}
+	}
+}
+
+func TestReplayJobMatchesRecorded(t *testing.T) {
+	original := recordedJob("1")
+	buf := recording(t, original)
+
+	_, err := goller.Replay(context.Background(), goller.ReplayConfig{}, buf, func(ctx context.Context, j goller.Job) error {
+		if j.ID() != original.ID() {
+			t.Errorf("expected ID %s, got %s", original.ID(), j.ID())
+		}
+
+		body, _ := j.Body()
+		if expected, _ := original.Body(); body != expected {
+			t.Errorf("expected body %s, got %s", expected, body)
+		}
+		if !reflect.DeepEqual(j.Attributes(), original.Attributes()) {
+			t.Errorf("expected attributes %+v, got %+v", original.Attributes(), j.Attributes())
+		}
+
+		tries, _ := j.Tries()
+		if expected, _ := original.Tries(); tries != expected {
+			t.Errorf("expected %d tries, got %d", expected, tries)
+		}
+		if !j.SentAt().Equal(original.SentAt()) || j.GroupID() != "group" {
+			t.Errorf("expected system attributes to be replayed, got sent %s & group %q", j.SentAt(), j.GroupID())
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReplayJob(t *testing.T) {
+	cfg := goller.NewDefaultConfig(testQueue, 1)
+	cfg.Job.MaxVisibilityTimeout = 100
+
+	j := goller.NewReplayJob(cfg, goller.RecordedMessage{ID: "1", Body: "body"})
+
+	if err := j.Release(1000); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.Delete(); err != goller.ErrAlreadyHandled {
+		t.Fatalf("expected ErrAlreadyHandled, got %v", err)
+	}
+
+	if calls := j.Calls(); len(calls) != 1 || calls[0].Seconds != 100 {
+		t.Fatalf("expected release clamped to the maximum, got %+v", calls)
+	}
+}
+
+func TestReplayInvalidRecording(t *testing.T) {
+	buf := recording(t, recordedJob("1"))
+	buf.WriteString("not json\n")
+
+	results, err := goller.Replay(context.Background(), goller.ReplayConfig{}, buf, func(ctx context.Context, j goller.Job) error {
+		return j.Delete()
+	})
+
+	if err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Fatalf("expected error on line 2, got %v", err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("expected the valid line replayed, got %d results", len(results))
+	}
+}
+
+func TestReplayCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	buf := recording(t, recordedJob("1"), recordedJob("2"))
+
+	results, err := goller.Replay(ctx, goller.ReplayConfig{}, buf, func(ctx context.Context, j goller.Job) error {
+		cancel()
+		return j.Delete()
+	})
+
+	if err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("expected to stop after the first job, got %d results", len(results))
+	}
+}
@@ -0,0 +1,159 @@
+package goller
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+)
+
+const (
+	// NotBeforeAttribute holds the time, in seconds since the epoch, a scheduled job is due.
+	// See Message.NotBefore.
+	NotBeforeAttribute = "goller-not-before"
+
+	// HopsAttribute counts how many times a scheduled job has been sent back to the queue.
+	HopsAttribute = "goller-hops"
+
+	// SentAtAttribute holds when the original message was sent, in milliseconds since the epoch.
+	// It's added to copies Goller sends back to the queue, so Job.SentAt() & MaxMessageAge see the real age.
+	SentAtAttribute = "goller-sent-at"
+)
+
+// SQS maximum delay on a message.
+const maxDelaySeconds = 900
+
+// errFIFODelay is returned when a copy of a FIFO queue message would be delayed,
+// FIFO queues only support a delay on the whole queue.
+var errFIFODelay = errors.New("fifo queue messages can't be delayed")
+
+// notBeforeAttributes adds the due time to the attributes,
+// returning how long to delay the message for.
+func notBeforeAttributes(attrs map[string]sqs.MessageAttributeValue, at time.Time) int64 {
+	attrs[NotBeforeAttribute] = sqs.MessageAttributeValue{
+		DataType:    aws.String(AttributeTypeNumber),
+		StringValue: aws.String(strconv.FormatInt(at.Unix(), 10)),
+	}
+
+	return hopDelay(time.Until(at))
+}
+
+// hopDelay is how long the next hop should wait for, within the SQS limit.
+func hopDelay(remaining time.Duration) int64 {
+	secs := int64(math.Ceil(remaining.Seconds()))
+	if secs > maxDelaySeconds {
+		return maxDelaySeconds
+	}
+	if secs < 0 {
+		return 0
+	}
+
+	return secs
+}
+
+// sendCopy sends the message back to the queue with the given attributes.
+// The copy starts with a fresh receive count, but keeps when the original was sent (see SentAtAttribute)
+// & its FIFO message group. A FIFO copy gets a deduplication ID of its own, as reusing the original's
+// within the deduplication interval would drop the copy. FIFO copies can't be delayed.
+func (w *sqsWorker) sendCopy(msg sqs.Message, attrs map[string]sqs.MessageAttributeValue, delay int64) error {
+	if w.dryRun() {
+		w.log.WithFields(Fields{
+			"jid":   aws.StringValue(msg.MessageId),
+			"delay": time.Duration(delay) * time.Second,
+		}).Info("dry-run. copy not sent to SQS")
+		return nil
+	}
+
+	if _, ok := attrs[SentAtAttribute]; !ok {
+		if sent, ok := msg.Attributes[string(sqs.MessageSystemAttributeNameSentTimestamp)]; ok {
+			attrs[SentAtAttribute] = sqs.MessageAttributeValue{
+				DataType:    aws.String(AttributeTypeNumber),
+				StringValue: aws.String(sent),
+			}
+		}
+	}
+
+	input := &sqs.SendMessageInput{
+		MessageAttributes: attrs,
+		MessageBody:       aws.String(aws.StringValue(msg.Body)),
+		QueueUrl:          aws.String(w.cfg.QueueURL),
+	}
+
+	group, fifo := msg.Attributes[string(sqs.MessageSystemAttributeNameMessageGroupId)]
+	if !fifo {
+		input.DelaySeconds = aws.Int64(delay)
+	} else {
+		if delay > 0 {
+			return errFIFODelay
+		}
+		input.MessageGroupId = aws.String(group)
+
+		// Unique to this copy, yet the same if sending it is retried
+		dedup := msg.Attributes[string(sqs.MessageSystemAttributeNameMessageDeduplicationId)]
+		sum := sha256.Sum256([]byte(dedup + "\n" + aws.StringValue(msg.MessageId)))
+		input.MessageDeduplicationId = aws.String(hex.EncodeToString(sum[:]))
+	}
+
+	req := w.svc.SendMessageRequest(input)
+
+	_, err := req.Send()
+	return err
+}
+
+// notDue hops a scheduled job back on to the queue when it's not yet due.
+//
+// Each hop sends a copy of the message, delayed for up to 15 minutes, & deletes the original.
+// Releasing the job instead would count towards Tries() & the queue's redrive policy.
+func (w *sqsWorker) notDue(j Job, msg sqs.Message, logger Logger) bool {
+	if _, ok := msg.MessageAttributes[NotBeforeAttribute]; !ok {
+		return false
+	}
+
+	due, err := j.AttributeTime(NotBeforeAttribute)
+	if err != nil {
+		logger.WithError(err).Error("invalid not before attribute. handling job now")
+		return false
+	}
+
+	remaining := time.Until(due)
+	if remaining <= 0 {
+		return false
+	}
+
+	logger = logger.WithField("due", due)
+	scheduleHopTotal.Inc()
+
+	hops, _ := j.AttributeInt(HopsAttribute)
+
+	attrs := make(map[string]sqs.MessageAttributeValue, len(msg.MessageAttributes)+1)
+	for k, v := range msg.MessageAttributes {
+		attrs[k] = v
+	}
+	attrs[HopsAttribute] = sqs.MessageAttributeValue{
+		DataType:    aws.String(AttributeTypeNumber),
+		StringValue: aws.String(strconv.FormatInt(hops+1, 10)),
+	}
+
+	if err := w.sendCopy(msg, attrs, hopDelay(remaining)); err != nil {
+		// e.g. FIFO queues don't support per message delays
+		logger.WithError(err).Error("unable to send scheduled job back to the queue, releasing instead")
+		if err := j.Release(int64(math.Ceil(remaining.Seconds()))); err != nil {
+			logger.WithError(err).Error("unable to release scheduled job")
+		}
+		return true
+	}
+
+	if err := j.Delete(); err != nil {
+		logger.WithError(err).Error("unable to delete scheduled job")
+		return true
+	}
+
+	logger.WithField("hops", hops+1).Debug("scheduled job not due. sent back to the queue")
+
+	return true
+}
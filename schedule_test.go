@@ -0,0 +1,161 @@
+package goller_test
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/rcrowe/goller"
+)
+
+func notBefore(at time.Time) sqs.MessageAttributeValue {
+	return sqs.MessageAttributeValue{
+		DataType:    aws.String("Number"),
+		StringValue: aws.String(strconv.FormatInt(at.Unix(), 10)),
+	}
+}
+
+func TestPublishNotBefore(t *testing.T) {
+	svc := &sendSQSClient{Output: sqs.SendMessageOutput{MessageId: aws.String("abc")}}
+	at := time.Now().Add(72 * time.Hour)
+
+	_, err := goller.NewPublisher(svc, "https://sqs/123/some-queue").Publish(context.Background(), goller.Message{
+		Body:      "follow up",
+		NotBefore: at,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if delay := aws.Int64Value(svc.Input.DelaySeconds); delay != 900 {
+		t.Errorf("expected the maximum delay but got `%d`", delay)
+	}
+	if v := aws.StringValue(svc.Input.MessageAttributes[goller.NotBeforeAttribute].StringValue); v != strconv.FormatInt(at.Unix(), 10) {
+		t.Errorf("expected not before attribute `%d` but got `%s`", at.Unix(), v)
+	}
+}
+
+func listenScheduled(svc *queueSQSClient) []string {
+	cfg := goller.NewDefaultConfig("foo", 1)
+	cfg.RunOnce()
+	cfg.Consumer.RetrievalMaxNumberOfMessages = int64(len(svc.messages))
+
+	handled := []string{}
+	goller.NewFromConfig(svc, cfg).Listen(context.Background(), func(ctx context.Context, j goller.Job) error {
+		handled = append(handled, j.ID())
+		return j.Delete()
+	})
+
+	return handled
+}
+
+func TestScheduledJobNotDue(t *testing.T) {
+	svc := newQueueSQSClient(1)
+	svc.messages[0].Attributes = map[string]string{"ApproximateReceiveCount": "1"}
+	svc.messages[0].MessageAttributes = map[string]sqs.MessageAttributeValue{
+		goller.NotBeforeAttribute: notBefore(time.Now().Add(48 * time.Hour)),
+		goller.HopsAttribute:      {DataType: aws.String("Number"), StringValue: aws.String("4")},
+		"tenant":                  {DataType: aws.String("String"), StringValue: aws.String("acme")},
+	}
+
+	if handled := listenScheduled(svc); len(handled) != 0 {
+		t.Errorf("expected job not to be handled until due but got `%v`", handled)
+	}
+
+	if len(svc.sent) != 1 {
+		t.Fatalf("expected job to be sent back to the queue but got `%d`", len(svc.sent))
+	}
+	sent := svc.sent[0]
+	if aws.Int64Value(sent.DelaySeconds) != 900 {
+		t.Errorf("expected hop of `900` seconds but got `%d`", aws.Int64Value(sent.DelaySeconds))
+	}
+	if hops := aws.StringValue(sent.MessageAttributes[goller.HopsAttribute].StringValue); hops != "5" {
+		t.Errorf("expected hop count to be incremented but got `%s`", hops)
+	}
+	if tenant := aws.StringValue(sent.MessageAttributes["tenant"].StringValue); tenant != "acme" {
+		t.Errorf("expected attributes to be kept but got `%s`", tenant)
+	}
+	if len(svc.deleted) != 1 {
+		t.Errorf("expected original to be deleted but got `%v`", svc.deleted)
+	}
+}
+
+func TestScheduledJobKeepsSentAt(t *testing.T) {
+	sent := time.Now().Add(-3 * time.Hour).Truncate(time.Millisecond)
+
+	svc := newQueueSQSClient(1)
+	svc.messages[0].Attributes = map[string]string{
+		"SentTimestamp": strconv.FormatInt(sent.UnixNano()/int64(time.Millisecond), 10),
+	}
+	svc.messages[0].MessageAttributes = map[string]sqs.MessageAttributeValue{
+		goller.NotBeforeAttribute: notBefore(time.Now().Add(time.Hour)),
+	}
+
+	listenScheduled(svc)
+
+	if len(svc.sent) != 1 {
+		t.Fatalf("expected job to be sent back to the queue but got `%d`", len(svc.sent))
+	}
+	copied := sqs.Message{MessageId: aws.String("copy"), MessageAttributes: svc.sent[0].MessageAttributes}
+	if j := goller.NewJob(goller.NewDefaultConfig("foo", 1), goller.NewNopLogger(), copied, nil); !j.SentAt().Equal(sent) {
+		t.Errorf("expected copy to keep the original sent at `%s` but got `%s`", sent, j.SentAt())
+	}
+}
+
+func TestScheduledJobFIFO(t *testing.T) {
+	svc := newQueueSQSClient(1)
+	svc.messages[0].Attributes = map[string]string{
+		"ApproximateReceiveCount": "1",
+		"MessageGroupId":          "tenant-1",
+		"MessageDeduplicationId":  "abc",
+	}
+	svc.messages[0].MessageAttributes = map[string]sqs.MessageAttributeValue{
+		goller.NotBeforeAttribute: notBefore(time.Now().Add(time.Hour)),
+	}
+
+	listenScheduled(svc)
+
+	// FIFO messages can't be delayed, so the job is released until it's due
+	if len(svc.sent) != 0 {
+		t.Errorf("expected no copy to be sent but got `%v`", svc.sent)
+	}
+	if len(svc.released) != 1 || len(svc.deleted) != 0 {
+		t.Errorf("expected job to be released but got released `%v`, deleted `%v`", svc.released, svc.deleted)
+	}
+}
+
+func TestScheduledJobLastHop(t *testing.T) {
+	svc := newQueueSQSClient(1)
+	svc.messages[0].MessageAttributes = map[string]sqs.MessageAttributeValue{
+		goller.NotBeforeAttribute: notBefore(time.Now().Add(time.Minute)),
+	}
+
+	listenScheduled(svc)
+
+	if len(svc.sent) != 1 {
+		t.Fatalf("expected job to be sent back to the queue but got `%d`", len(svc.sent))
+	}
+	if delay := aws.Int64Value(svc.sent[0].DelaySeconds); delay < 58 || delay > 60 {
+		t.Errorf("expected hop until due but got `%d`", delay)
+	}
+}
+
+func TestScheduledJobDue(t *testing.T) {
+	svc := newQueueSQSClient(2)
+	svc.messages[0].MessageAttributes = map[string]sqs.MessageAttributeValue{
+		goller.NotBeforeAttribute: notBefore(time.Now().Add(-time.Minute)),
+	}
+	svc.messages[1].MessageAttributes = map[string]sqs.MessageAttributeValue{
+		goller.NotBeforeAttribute: {DataType: aws.String("String"), StringValue: aws.String("garbage")},
+	}
+
+	if handled := listenScheduled(svc); len(handled) != 2 {
+		t.Errorf("expected due & invalid jobs to be handled but got `%v`", handled)
+	}
+	if len(svc.sent) != 0 {
+		t.Errorf("expected nothing to be sent back to the queue but got `%d`", len(svc.sent))
+	}
+}
@@ -0,0 +1,81 @@
+package scheduler_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rcrowe/goller/scheduler"
+)
+
+func TestParseNext(t *testing.T) {
+	// Wednesday
+	from := time.Date(2020, 1, 1, 10, 7, 30, 0, time.UTC)
+
+	for _, tc := range []struct {
+		expr     string
+		expected time.Time
+	}{
+		{"* * * * *", time.Date(2020, 1, 1, 10, 8, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2020, 1, 1, 10, 15, 0, 0, time.UTC)},
+		{"5/15 * * * *", time.Date(2020, 1, 1, 10, 20, 0, 0, time.UTC)},
+		{"0 9-17 * * MON-FRI", time.Date(2020, 1, 1, 11, 0, 0, 0, time.UTC)},
+		{"30 2 * * sat,sun", time.Date(2020, 1, 4, 2, 30, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2020, 1, 5, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 FEB *", time.Date(2020, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1,15 * 1", time.Date(2020, 1, 6, 0, 0, 0, 0, time.UTC)}, // day of month or week
+		{"@hourly", time.Date(2020, 1, 1, 11, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2020, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"@every 90s", time.Date(2020, 1, 1, 10, 9, 0, 0, time.UTC)},
+	} {
+		s, err := scheduler.Parse(tc.expr)
+		if err != nil {
+			t.Errorf("%s: unexpected error `%s`", tc.expr, err)
+			continue
+		}
+
+		if next := s.Next(from); !next.Equal(tc.expected) {
+			t.Errorf("%s: expected `%s` but got `%s`", tc.expr, tc.expected, next)
+		}
+	}
+}
+
+func TestParseNextLocation(t *testing.T) {
+	loc := time.FixedZone("UTC+2", 2*60*60)
+	s, _ := scheduler.Parse("0 9 * * *")
+
+	next := s.Next(time.Date(2020, 1, 1, 8, 0, 0, 0, time.UTC).In(loc))
+	if expected := time.Date(2020, 1, 2, 7, 0, 0, 0, time.UTC); !next.Equal(expected) {
+		t.Errorf("expected `%s` but got `%s`", expected, next)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"* * * FOO *",
+		"@every nope",
+		"@every 1ms",
+	} {
+		if _, err := scheduler.Parse(expr); err == nil {
+			t.Errorf("%q: expected error", expr)
+		}
+	}
+
+	// Impossible dates never run
+	s, err := scheduler.Parse("0 0 30 FEB *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next := s.Next(time.Now()); !next.IsZero() {
+		t.Errorf("expected no next run but got `%s`", next)
+	}
+}
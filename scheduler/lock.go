@@ -0,0 +1,178 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Locker claims a run so that it's only fired by a single replica.
+type Locker interface {
+	// Lock the key for the TTL, returning false if it's already locked.
+	// Keys are unique to a run, so are only unlocked when the run fails to publish.
+	Lock(ctx context.Context, key string, ttl time.Duration) (bool, error)
+
+	// Unlock the key so the run can be fired again.
+	Unlock(ctx context.Context, key string) error
+}
+
+// Store remembers when each job last ran.
+type Store interface {
+	LastRun(ctx context.Context, name string) (time.Time, error)
+	SetLastRun(ctx context.Context, name string, at time.Time) error
+}
+
+// NewFileLocker locks runs by creating files in the directory.
+// Works across replicas on the same host, or sharing a file system.
+func NewFileLocker(dir string) (Locker, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	return &fileLocker{dir: dir}, nil
+}
+
+type fileLocker struct {
+	dir string
+}
+
+func (l *fileLocker) Lock(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	l.prune(ttl)
+
+	f, err := os.OpenFile(filepath.Join(l.dir, url.PathEscape(key)+".lock"), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if os.IsExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return true, f.Close()
+}
+
+func (l *fileLocker) Unlock(ctx context.Context, key string) error {
+	err := os.Remove(filepath.Join(l.dir, url.PathEscape(key)+".lock"))
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	return err
+}
+
+// prune removes expired locks.
+func (l *fileLocker) prune(ttl time.Duration) {
+	files, err := ioutil.ReadDir(l.dir)
+	if err != nil {
+		return
+	}
+
+	for _, f := range files {
+		if filepath.Ext(f.Name()) == ".lock" && time.Since(f.ModTime()) > ttl {
+			os.Remove(filepath.Join(l.dir, f.Name()))
+		}
+	}
+}
+
+// NewMemoryStore only remembers last runs while the scheduler is running.
+func NewMemoryStore() Store {
+	return &memoryStore{runs: make(map[string]time.Time)}
+}
+
+type memoryStore struct {
+	mu   sync.Mutex
+	runs map[string]time.Time
+}
+
+func (s *memoryStore) LastRun(ctx context.Context, name string) (time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.runs[name], nil
+}
+
+func (s *memoryStore) SetLastRun(ctx context.Context, name string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.runs[name] = at
+	return nil
+}
+
+// NewFileStore keeps last runs in a JSON file, so missed runs are caught up after a restart.
+func NewFileStore(path string) Store {
+	return &fileStore{path: path}
+}
+
+type fileStore struct {
+	mu   sync.Mutex
+	path string
+}
+
+func (s *fileStore) LastRun(ctx context.Context, name string) (time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	runs, err := s.read()
+	return runs[name], err
+}
+
+func (s *fileStore) SetLastRun(ctx context.Context, name string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	runs, err := s.read()
+	if err != nil {
+		return err
+	}
+
+	// Another replica may have fired a later run
+	if at.Before(runs[name]) {
+		return nil
+	}
+	runs[name] = at
+
+	data, err := json.Marshal(runs)
+	if err != nil {
+		return err
+	}
+
+	// Each write has its own temporary file, so replicas sharing the path don't clobber each other's
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), s.path)
+}
+
+func (s *fileStore) read() (map[string]time.Time, error) {
+	runs := make(map[string]time.Time)
+
+	data, err := ioutil.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return runs, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return runs, json.Unmarshal(data, &runs)
+}
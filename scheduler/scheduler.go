@@ -0,0 +1,369 @@
+// Package scheduler publishes messages on a cron schedule, replacing the cron box
+// that drops messages on to the queues Goller consumes from.
+package scheduler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"text/template"
+	"time"
+
+	"github.com/rcrowe/goller"
+)
+
+// CatchUp decides what happens to runs missed while no scheduler was running.
+type CatchUp int
+
+const (
+	// CatchUpNone skips missed runs.
+	CatchUpNone CatchUp = iota
+
+	// CatchUpOnce fires a single run for the most recently missed time.
+	CatchUpOnce
+
+	// CatchUpAll fires every missed run, up to Config.MaxCatchUp.
+	CatchUpAll
+)
+
+// Job is a message published on a schedule.
+type Job struct {
+	// Unique name, used for locking & remembering the last run.
+	Name string
+
+	// Cron expression, see Parse(...).
+	Schedule string
+
+	// Time zone the schedule is in.
+	// Default UTC.
+	Location *time.Location
+
+	// Where the message is published to.
+	Publisher goller.Publisher
+
+	// Message to publish. The body is a text/template, given TemplateData.
+	Message goller.Message
+
+	// What to do with runs missed while no scheduler was running.
+	// Default CatchUpNone.
+	CatchUp CatchUp
+}
+
+// TemplateData is available to the message body template.
+type TemplateData struct {
+	// Name of the job.
+	Name string
+
+	// When the run was scheduled for, not when it was published.
+	Time time.Time
+}
+
+// Config holds scheduler configuration.
+type Config struct {
+	// Stops replicas double firing a run.
+	// Zero value disables the setting, only run a single replica.
+	Locker Locker
+
+	// How long a run stays locked for.
+	// Default 1 hour.
+	LockTTL time.Duration
+
+	// Remembers when jobs last ran, so missed runs can be caught up across restarts.
+	// Default is in memory.
+	Store Store
+
+	// Maximum number of missed runs fired per job with CatchUpAll,
+	// & of failed runs waiting to be retried per job.
+	// Default 100.
+	MaxCatchUp int
+
+	// How long after a run fails to publish before it's fired again.
+	// Default 1 minute.
+	RetryInterval time.Duration
+}
+
+// Scheduler publishes jobs on their schedule until the context is done.
+type Scheduler interface {
+	WithLogger(logger goller.Logger)
+	Run(ctx context.Context) error
+}
+
+// ErrDuplicateJob is returned when two jobs share a name.
+var ErrDuplicateJob = errors.New("duplicate job name")
+
+// New validates the jobs and their schedules.
+func New(cfg Config, jobs ...Job) (Scheduler, error) {
+	if cfg.LockTTL <= 0 {
+		cfg.LockTTL = time.Hour
+	}
+	if cfg.Store == nil {
+		cfg.Store = NewMemoryStore()
+	}
+	if cfg.MaxCatchUp < 1 {
+		cfg.MaxCatchUp = 100
+	}
+	if cfg.RetryInterval <= 0 {
+		cfg.RetryInterval = time.Minute
+	}
+
+	s := &cronScheduler{
+		cfg: cfg,
+		log: goller.NewNopLogger(),
+	}
+
+	seen := make(map[string]bool, len(jobs))
+	for _, job := range jobs {
+		if seen[job.Name] {
+			return nil, fmt.Errorf("%s: %s", ErrDuplicateJob, job.Name)
+		}
+		seen[job.Name] = true
+
+		if job.Publisher == nil {
+			return nil, fmt.Errorf("%s: publisher is required", job.Name)
+		}
+		if job.Location == nil {
+			job.Location = time.UTC
+		}
+
+		schedule, err := Parse(job.Schedule)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", job.Name, err)
+		}
+
+		body, err := template.New(job.Name).Parse(job.Message.Body)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", job.Name, err)
+		}
+
+		s.jobs = append(s.jobs, &scheduledJob{Job: job, schedule: schedule, body: body})
+	}
+
+	return s, nil
+}
+
+type cronScheduler struct {
+	cfg  Config
+	jobs []*scheduledJob
+	log  goller.Logger
+}
+
+type scheduledJob struct {
+	Job
+
+	schedule Schedule
+	body     *template.Template
+	next     time.Time
+
+	// Runs that failed to publish, fired again at retryAt
+	failed  []time.Time
+	retryAt time.Time
+}
+
+// WithLogger overrides the default logger, which discards everything.
+func (s *cronScheduler) WithLogger(logger goller.Logger) {
+	s.log = logger
+}
+
+// Run catches up on missed runs, then publishes each job as it's due.
+// Runs that fail to publish are fired again after the retry interval.
+// Returns once the context is done.
+func (s *cronScheduler) Run(ctx context.Context) error {
+	now := time.Now()
+	for _, job := range s.jobs {
+		if err := s.catchUp(ctx, job, now); err != nil {
+			return err
+		}
+		job.next = job.schedule.Next(now.In(job.Location))
+	}
+
+	for {
+		next := s.nextRun()
+		if next.IsZero() {
+			s.log.Info("no jobs scheduled")
+			<-ctx.Done()
+			return nil
+		}
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		case <-timer.C:
+		}
+
+		now := time.Now()
+		for _, job := range s.jobs {
+			if !job.retryAt.IsZero() && !job.retryAt.After(now) {
+				s.retry(ctx, job, now)
+			}
+
+			if job.next.IsZero() || job.next.After(now) {
+				continue
+			}
+
+			if !s.fire(ctx, job, job.next) {
+				s.failed(job, job.next, now)
+			}
+			job.next = job.schedule.Next(now.In(job.Location))
+		}
+	}
+}
+
+// nextRun is when the next job is due, or retried.
+func (s *cronScheduler) nextRun() time.Time {
+	var next time.Time
+	for _, job := range s.jobs {
+		for _, at := range []time.Time{job.next, job.retryAt} {
+			if !at.IsZero() && (next.IsZero() || at.Before(next)) {
+				next = at
+			}
+		}
+	}
+
+	return next
+}
+
+// failed keeps a run that didn't publish, so that it's fired again after the retry interval.
+func (s *cronScheduler) failed(job *scheduledJob, at, now time.Time) {
+	job.failed = append(job.failed, at)
+
+	// Only the most recent few are kept
+	if len(job.failed) > s.cfg.MaxCatchUp {
+		job.failed = job.failed[1:]
+	}
+
+	if job.retryAt.IsZero() {
+		job.retryAt = now.Add(s.cfg.RetryInterval)
+	}
+}
+
+// retry fires the runs that failed to publish, oldest first.
+func (s *cronScheduler) retry(ctx context.Context, job *scheduledJob, now time.Time) {
+	runs := job.failed
+	job.failed = nil
+	job.retryAt = time.Time{}
+
+	s.log.WithFields(goller.Fields{
+		"job":    job.Name,
+		"failed": len(runs),
+	}).Info("retrying failed runs")
+
+	for _, at := range runs {
+		if !s.fire(ctx, job, at) {
+			s.failed(job, at, now)
+		}
+	}
+}
+
+// catchUp fires the runs missed since the job last ran.
+func (s *cronScheduler) catchUp(ctx context.Context, job *scheduledJob, now time.Time) error {
+	last, err := s.cfg.Store.LastRun(ctx, job.Name)
+	if err != nil {
+		return fmt.Errorf("%s: unable to get last run: %s", job.Name, err)
+	}
+
+	// Never run before, so nothing was missed
+	if last.IsZero() {
+		return s.cfg.Store.SetLastRun(ctx, job.Name, now)
+	}
+
+	var missed []time.Time
+	for at := job.schedule.Next(last.In(job.Location)); !at.IsZero() && !at.After(now); at = job.schedule.Next(at) {
+		missed = append(missed, at)
+
+		// Only the most recent few are kept
+		if len(missed) > s.cfg.MaxCatchUp {
+			missed = missed[1:]
+		}
+	}
+
+	if len(missed) == 0 {
+		return nil
+	}
+
+	logger := s.log.WithFields(goller.Fields{
+		"job":    job.Name,
+		"missed": len(missed),
+	})
+
+	switch job.CatchUp {
+	case CatchUpNone:
+		logger.Info("skipping missed runs")
+		return s.cfg.Store.SetLastRun(ctx, job.Name, missed[len(missed)-1])
+	case CatchUpOnce:
+		missed = missed[len(missed)-1:]
+	}
+
+	logger.Info("catching up on missed runs")
+	for _, at := range missed {
+		if !s.fire(ctx, job, at) {
+			s.failed(job, at, now)
+		}
+	}
+
+	return nil
+}
+
+// fire publishes the run scheduled at the given time, unless another replica already has.
+// Returns false if the run should be fired again.
+func (s *cronScheduler) fire(ctx context.Context, job *scheduledJob, at time.Time) bool {
+	logger := s.log.WithFields(goller.Fields{
+		"job": job.Name,
+		"at":  at,
+	})
+
+	key := fmt.Sprintf("%s@%d", job.Name, at.Unix())
+	if s.cfg.Locker != nil {
+		ok, err := s.cfg.Locker.Lock(ctx, key, s.cfg.LockTTL)
+		if err != nil {
+			logger.WithError(err).Error("unable to lock run")
+			return false
+		}
+		if !ok {
+			logger.Debug("run already fired by another scheduler")
+			s.setLastRun(ctx, job, at, logger)
+			return true
+		}
+	}
+
+	var body bytes.Buffer
+	if err := job.body.Execute(&body, TemplateData{Name: job.Name, Time: at}); err != nil {
+		// Rendering again won't help
+		logger.WithError(err).Error("unable to render message body")
+		s.unlock(ctx, key, logger)
+		return true
+	}
+
+	msg := job.Message
+	msg.Body = body.String()
+
+	id, err := job.Publisher.Publish(ctx, msg)
+	if err != nil {
+		logger.WithError(err).Error("unable to publish scheduled job")
+		s.unlock(ctx, key, logger)
+		return false
+	}
+
+	logger.WithField("jid", id).Info("published scheduled job")
+	s.setLastRun(ctx, job, at, logger)
+	return true
+}
+
+// unlock a run that wasn't published, so it can be fired again.
+func (s *cronScheduler) unlock(ctx context.Context, key string, logger goller.Logger) {
+	if s.cfg.Locker == nil {
+		return
+	}
+
+	if err := s.cfg.Locker.Unlock(ctx, key); err != nil {
+		logger.WithError(err).Error("unable to unlock run")
+	}
+}
+
+func (s *cronScheduler) setLastRun(ctx context.Context, job *scheduledJob, at time.Time, logger goller.Logger) {
+	if err := s.cfg.Store.SetLastRun(ctx, job.Name, at); err != nil {
+		logger.WithError(err).Error("unable to save last run")
+	}
+}
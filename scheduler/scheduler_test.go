@@ -0,0 +1,313 @@
+package scheduler_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rcrowe/goller"
+	"github.com/rcrowe/goller/scheduler"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type recordingPublisher struct {
+	lock sync.Mutex
+	msgs []goller.Message
+	err  error
+
+	// Number of publishes that fail before err is returned
+	failures int
+	rejected []string
+}
+
+func (p *recordingPublisher) WithTracerProvider(tp trace.TracerProvider) {}
+
+func (p *recordingPublisher) Publish(ctx context.Context, msg goller.Message) (string, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.err != nil {
+		return "", p.err
+	}
+	if p.failures > 0 {
+		p.failures--
+		p.rejected = append(p.rejected, msg.Body)
+		return "", errors.New("sqs down")
+	}
+
+	p.msgs = append(p.msgs, msg)
+	return "abc", nil
+}
+
+func (p *recordingPublisher) Bodies() []string {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	bodies := []string{}
+	for _, msg := range p.msgs {
+		bodies = append(bodies, msg.Body)
+	}
+	return bodies
+}
+
+// runFor runs the scheduler until the timeout.
+func runFor(t *testing.T, s scheduler.Scheduler, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := s.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRun(t *testing.T) {
+	publisher := &recordingPublisher{}
+
+	s, err := scheduler.New(scheduler.Config{}, scheduler.Job{
+		Name:      "tick",
+		Schedule:  "@every 1s",
+		Publisher: publisher,
+		Message:   goller.Message{Body: `{"job": "{{.Name}}", "at": {{.Time.Unix}}}`},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	runFor(t, s, 1500*time.Millisecond)
+
+	bodies := publisher.Bodies()
+	if len(bodies) < 1 {
+		t.Fatal("expected job to be published")
+	}
+	if expected := `{"job": "tick", "at": `; bodies[0][:len(expected)] != expected {
+		t.Errorf("expected rendered body but got `%s`", bodies[0])
+	}
+}
+
+func TestCatchUp(t *testing.T) {
+	for policy, expected := range map[scheduler.CatchUp]int{
+		scheduler.CatchUpNone: 0,
+		scheduler.CatchUpOnce: 1,
+		scheduler.CatchUpAll:  3,
+	} {
+		store := scheduler.NewMemoryStore()
+		store.SetLastRun(context.Background(), "report", time.Now().Add(-3*time.Hour))
+
+		publisher := &recordingPublisher{}
+		s, err := scheduler.New(scheduler.Config{Store: store}, scheduler.Job{
+			Name:      "report",
+			Schedule:  "@hourly",
+			Publisher: publisher,
+			CatchUp:   policy,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		runFor(t, s, 10*time.Millisecond)
+
+		if actual := len(publisher.Bodies()); actual != expected {
+			t.Errorf("policy %d: expected `%d` runs but got `%d`", policy, expected, actual)
+		}
+
+		last, _ := store.LastRun(context.Background(), "report")
+		if time.Since(last) > time.Hour {
+			t.Errorf("policy %d: expected last run to move forward but got `%s`", policy, last)
+		}
+	}
+}
+
+func TestCatchUpLocked(t *testing.T) {
+	locker, err := scheduler.NewFileLocker(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	publisher := &recordingPublisher{}
+	last := time.Now().Add(-3 * time.Hour)
+
+	// Two replicas catching up on the same runs
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		store := scheduler.NewMemoryStore()
+		store.SetLastRun(context.Background(), "report", last)
+
+		s, err := scheduler.New(scheduler.Config{Locker: locker, Store: store}, scheduler.Job{
+			Name:      "report",
+			Schedule:  "@hourly",
+			Publisher: publisher,
+			CatchUp:   scheduler.CatchUpAll,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			runFor(t, s, 10*time.Millisecond)
+		}()
+	}
+	wg.Wait()
+
+	if actual := len(publisher.Bodies()); actual != 3 {
+		t.Errorf("expected each run to be published once but got `%d`", actual)
+	}
+}
+
+func TestPublishErrorKeepsLastRun(t *testing.T) {
+	store := scheduler.NewMemoryStore()
+	last := time.Now().Add(-2 * time.Hour)
+	store.SetLastRun(context.Background(), "report", last)
+
+	s, _ := scheduler.New(scheduler.Config{Store: store}, scheduler.Job{
+		Name:      "report",
+		Schedule:  "@hourly",
+		Publisher: &recordingPublisher{err: errors.New("sqs down")},
+		CatchUp:   scheduler.CatchUpAll,
+	})
+	runFor(t, s, 10*time.Millisecond)
+
+	if actual, _ := store.LastRun(context.Background(), "report"); !actual.Equal(last) {
+		t.Errorf("expected last run to stay at `%s` but got `%s`", last, actual)
+	}
+}
+
+func TestPublishErrorUnlocksRun(t *testing.T) {
+	locker, err := scheduler.NewFileLocker(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Just the one missed run
+	last := time.Now().Truncate(time.Hour).Add(-time.Minute)
+	for _, publisher := range []*recordingPublisher{{err: errors.New("sqs down")}, {}} {
+		store := scheduler.NewMemoryStore()
+		store.SetLastRun(context.Background(), "report", last)
+
+		s, _ := scheduler.New(scheduler.Config{Locker: locker, Store: store}, scheduler.Job{
+			Name:      "report",
+			Schedule:  "@hourly",
+			Publisher: publisher,
+			CatchUp:   scheduler.CatchUpAll,
+		})
+		runFor(t, s, 10*time.Millisecond)
+
+		// A replica retrying after the failure can still fire the run
+		if publisher.err == nil && len(publisher.Bodies()) != 1 {
+			t.Errorf("expected failed run to be fired again but got `%d` runs", len(publisher.Bodies()))
+		}
+	}
+}
+
+func TestPublishErrorRetried(t *testing.T) {
+	publisher := &recordingPublisher{failures: 1}
+
+	s, _ := scheduler.New(scheduler.Config{RetryInterval: 50 * time.Millisecond}, scheduler.Job{
+		Name:      "tick",
+		Schedule:  "@every 1s",
+		Publisher: publisher,
+		Message:   goller.Message{Body: "{{.Time.Unix}}"},
+	})
+	runFor(t, s, 1300*time.Millisecond)
+
+	bodies := publisher.Bodies()
+	if len(bodies) < 1 || len(publisher.rejected) != 1 || bodies[0] != publisher.rejected[0] {
+		t.Errorf("expected the failed run `%v` to be fired again but got `%v`", publisher.rejected, bodies)
+	}
+}
+
+func TestCatchUpPublishErrorRetried(t *testing.T) {
+	store := scheduler.NewMemoryStore()
+	last := time.Now().Truncate(time.Hour).Add(-time.Minute)
+	store.SetLastRun(context.Background(), "report", last)
+
+	publisher := &recordingPublisher{failures: 2}
+	s, _ := scheduler.New(scheduler.Config{Store: store, RetryInterval: 10 * time.Millisecond}, scheduler.Job{
+		Name:      "report",
+		Schedule:  "@hourly",
+		Publisher: publisher,
+		CatchUp:   scheduler.CatchUpAll,
+	})
+	runFor(t, s, 100*time.Millisecond)
+
+	if actual := len(publisher.Bodies()); actual != 1 {
+		t.Errorf("expected the missed run to be published once retried but got `%d`", actual)
+	}
+	if actual, _ := store.LastRun(context.Background(), "report"); !actual.After(last) {
+		t.Errorf("expected last run to move forward but got `%s`", actual)
+	}
+}
+
+func TestNewInvalid(t *testing.T) {
+	publisher := &recordingPublisher{}
+
+	for name, jobs := range map[string][]scheduler.Job{
+		"schedule":  {{Name: "a", Schedule: "nope", Publisher: publisher}},
+		"template":  {{Name: "a", Schedule: "@daily", Publisher: publisher, Message: goller.Message{Body: "{{"}}},
+		"publisher": {{Name: "a", Schedule: "@daily"}},
+		"duplicate": {
+			{Name: "a", Schedule: "@daily", Publisher: publisher},
+			{Name: "a", Schedule: "@hourly", Publisher: publisher},
+		},
+	} {
+		if _, err := scheduler.New(scheduler.Config{}, jobs...); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestFileStore(t *testing.T) {
+	ctx := context.Background()
+	path := t.TempDir() + "/runs.json"
+	at := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	if err := scheduler.NewFileStore(path).SetLastRun(ctx, "report", at); err != nil {
+		t.Fatal(err)
+	}
+
+	// Earlier runs don't move it backwards
+	store := scheduler.NewFileStore(path)
+	store.SetLastRun(ctx, "report", at.Add(-time.Hour))
+
+	if last, err := store.LastRun(ctx, "report"); err != nil || !last.Equal(at) {
+		t.Errorf("expected `%s` but got `%s` (%v)", at, last, err)
+	}
+	if last, _ := store.LastRun(ctx, "missing"); !last.IsZero() {
+		t.Errorf("expected zero time but got `%s`", last)
+	}
+}
+
+func TestFileStoreReplicas(t *testing.T) {
+	ctx := context.Background()
+	path := t.TempDir() + "/runs.json"
+	at := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// Replicas sharing the file write at the same time
+	var wg sync.WaitGroup
+	errs := make(chan error, 10*50)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			store := scheduler.NewFileStore(path)
+			for j := 0; j < 50; j++ {
+				errs <- store.SetLastRun(ctx, "report", at.Add(time.Duration(j*10+i)*time.Minute))
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("expected no error but got `%s`", err)
+		}
+	}
+	if last, err := scheduler.NewFileStore(path).LastRun(ctx, "report"); err != nil || last.IsZero() {
+		t.Errorf("expected a last run but got `%s` (%v)", last, err)
+	}
+}
@@ -0,0 +1,134 @@
+package goller
+
+import (
+	"context"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/sqsiface"
+)
+
+// Shutdown stops consumers receiving straight away, then waits for in-flight jobs until the context is done.
+// Jobs still in-flight at that point have their handler contexts cancelled & are released back to the
+// queue with no visibility timeout, so another worker can pick them up. Their handlers can no longer
+// delete or release them, getting ErrAlreadyHandled instead.
+//
+// Give the context a deadline, e.g. from spot.TerminationWatcher.ShutdownContext(...).
+// Returns the context's error when jobs were left unfinished.
+func (w *sqsWorker) Shutdown(ctx context.Context) error {
+	w.control.mu.Lock()
+	run := w.control.listen
+	w.control.mu.Unlock()
+
+	if run == nil {
+		return nil
+	}
+
+	logger := w.log
+	if deadline, ok := ctx.Deadline(); ok {
+		logger = logger.WithField("deadline", deadline)
+	}
+	logger.Info("shutting down safely...waiting for in-flight jobs")
+
+	atomic.StoreInt32(&run.shuttingDown, 1)
+	run.stopReceiving()
+	w.state.drain()
+
+	done := make(chan struct{})
+	go func() {
+		run.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		logger.Info("in-flight jobs finished")
+		return nil
+	case <-ctx.Done():
+	}
+
+	// Stop the handlers acknowledging jobs before they're released, so a released job
+	// isn't also deleted after another worker has picked it up
+	run.cancelHandlers()
+	handles := w.state.abandon()
+	logger.WithField("count", len(handles)).Error("shutdown deadline reached. releasing unfinished jobs")
+	shutdownReleasedTotal.Add(float64(len(handles)))
+
+	releaseMessages(w.svc, w.cfg.QueueURL, w.log, handles)
+
+	return ctx.Err()
+}
+
+// stopping returns whether Shutdown has been called.
+func (r *listenRun) stopping() bool {
+	return atomic.LoadInt32(&r.shuttingDown) == 1
+}
+
+func receiptHandles(msgs []sqs.Message) []string {
+	handles := make([]string, len(msgs))
+	for i, msg := range msgs {
+		handles[i] = aws.StringValue(msg.ReceiptHandle)
+	}
+
+	return handles
+}
+
+// releaseJob makes the job visible again straight away, see releaseMessages(...).
+// Jobs that aren't Goller's own, e.g. dry runs or wrapped by other middleware, fall back to Release(0).
+func releaseJob(j Job, log Logger) error {
+	sj, ok := j.(*sqsJob)
+	if !ok {
+		return j.Release(0)
+	}
+	if !sj.abandon() {
+		return ErrAlreadyHandled
+	}
+
+	releaseMessages(sj.svc, sj.cfg.QueueURL, log, []string{aws.StringValue(sj.msg.ReceiptHandle)})
+	return nil
+}
+
+// releaseMessages makes the messages visible again straight away.
+// Unlike Job.Release(...), the minimum visibility timeout doesn't apply.
+func releaseMessages(svc sqsiface.SQSAPI, queueURL string, log Logger, handles []string) {
+	for len(handles) > 0 {
+		n := len(handles)
+		if n > sqsBatchLimit {
+			n = sqsBatchLimit
+		}
+
+		entries := make([]sqs.ChangeMessageVisibilityBatchRequestEntry, n)
+		for i, handle := range handles[:n] {
+			entries[i] = sqs.ChangeMessageVisibilityBatchRequestEntry{
+				Id:                aws.String(strconv.Itoa(i)),
+				ReceiptHandle:     aws.String(handle),
+				VisibilityTimeout: aws.Int64(0),
+			}
+		}
+
+		req := svc.ChangeMessageVisibilityBatchRequest(&sqs.ChangeMessageVisibilityBatchInput{
+			Entries:  entries,
+			QueueUrl: aws.String(queueURL),
+		})
+
+		start := time.Now()
+		resp, err := req.Send()
+		sqsJobTimer.Set(time.Since(start).Seconds())
+
+		if err != nil {
+			log.WithError(err).Error("unable to release messages")
+		} else {
+			for _, failed := range resp.Failed {
+				log.WithFields(Fields{
+					"code":  aws.StringValue(failed.Code),
+					"error": aws.StringValue(failed.Message),
+				}).Error("unable to release message")
+			}
+		}
+
+		handles = handles[n:]
+	}
+}
@@ -0,0 +1,212 @@
+package goller_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/rcrowe/goller"
+)
+
+func (c *queueSQSClient) ChangeMessageVisibilityBatchRequest(input *sqs.ChangeMessageVisibilityBatchInput) sqs.ChangeMessageVisibilityBatchRequest {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	out := sqs.ChangeMessageVisibilityBatchOutput{}
+	for _, entry := range input.Entries {
+		if aws.Int64Value(entry.VisibilityTimeout) == 0 {
+			c.released = append(c.released, aws.StringValue(entry.ReceiptHandle))
+		}
+		out.Successful = append(out.Successful, sqs.ChangeMessageVisibilityBatchResultEntry{Id: entry.Id})
+	}
+
+	return sqs.ChangeMessageVisibilityBatchRequest{
+		Request: &aws.Request{
+			Data: &out,
+		},
+	}
+}
+
+func (c *queueSQSClient) Released() []string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return append([]string{}, c.released...)
+}
+
+// listenUntilShutdown returns a channel that's closed once Listen returns.
+func listenUntilShutdown(w goller.Worker, handler goller.HandlerFunc) chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		w.Listen(context.Background(), handler)
+	}()
+
+	return done
+}
+
+func TestShutdownWaitsForJobs(t *testing.T) {
+	svc := newQueueSQSClient(2)
+	w := goller.NewFromConfig(svc, goller.NewDefaultConfig("foo", 1))
+
+	var lock sync.Mutex
+	finished := 0
+	done := listenUntilShutdown(w, func(ctx context.Context, j goller.Job) error {
+		time.Sleep(50 * time.Millisecond)
+
+		lock.Lock()
+		finished++
+		lock.Unlock()
+
+		return j.Delete()
+	})
+
+	waitFor(t, func() bool { return len(w.Status().InFlight) == 2 })
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if err := w.Shutdown(ctx); err != nil {
+		t.Fatalf("expected jobs to finish but got `%s`", err)
+	}
+	<-done
+
+	lock.Lock()
+	defer lock.Unlock()
+	if finished != 2 {
+		t.Errorf("expected both jobs to finish but got `%d`", finished)
+	}
+	if released := svc.Released(); len(released) != 0 {
+		t.Errorf("expected nothing to be released but got `%v`", released)
+	}
+	if !w.Status().Draining {
+		t.Error("expected worker to be draining")
+	}
+}
+
+func TestShutdownReleasesUnfinishedJobs(t *testing.T) {
+	svc := newQueueSQSClient(2)
+	w := goller.NewFromConfig(svc, goller.NewDefaultConfig("foo", 1))
+
+	done := listenUntilShutdown(w, func(ctx context.Context, j goller.Job) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	waitFor(t, func() bool { return len(w.Status().InFlight) == 2 })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	if err := w.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Errorf("expected `%s` but got `%v`", context.DeadlineExceeded, err)
+	}
+
+	// Handler contexts are cancelled so Listen returns
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected listen to return")
+	}
+
+	if released := svc.Released(); len(released) != 2 {
+		t.Errorf("expected unfinished jobs to be released but got `%v`", released)
+	}
+}
+
+func TestShutdownStopsHandlersAcknowledgingReleasedJobs(t *testing.T) {
+	svc := newQueueSQSClient(2)
+	w := goller.NewFromConfig(svc, goller.NewDefaultConfig("foo", 1))
+
+	errs := make(chan error, 2)
+	done := listenUntilShutdown(w, func(ctx context.Context, j goller.Job) error {
+		<-ctx.Done()
+
+		// Finishes up after the deadline
+		err := j.Delete()
+		errs <- err
+		return err
+	})
+
+	waitFor(t, func() bool { return len(w.Status().InFlight) == 2 })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	w.Shutdown(ctx)
+	<-done
+
+	for i := 0; i < 2; i++ {
+		if err := <-errs; err != goller.ErrAlreadyHandled {
+			t.Errorf("expected `%s` but got `%v`", goller.ErrAlreadyHandled, err)
+		}
+	}
+	if len(svc.deleted) != 0 {
+		t.Errorf("expected released jobs not to be deleted but got `%v`", svc.deleted)
+	}
+	if released := svc.Released(); len(released) != 2 {
+		t.Errorf("expected unfinished jobs to be released but got `%v`", released)
+	}
+}
+
+// gatedReceiveSQSClient holds on to the receive until the gate is opened.
+type gatedReceiveSQSClient struct {
+	*queueSQSClient
+	receiving chan struct{}
+	gate      chan struct{}
+	once      sync.Once
+}
+
+func (c *gatedReceiveSQSClient) ReceiveMessageRequest(input *sqs.ReceiveMessageInput) sqs.ReceiveMessageRequest {
+	c.once.Do(func() { close(c.receiving) })
+	<-c.gate
+
+	return c.queueSQSClient.ReceiveMessageRequest(input)
+}
+
+func TestShutdownReleasesMessagesReceivedAfter(t *testing.T) {
+	svc := &gatedReceiveSQSClient{
+		queueSQSClient: newQueueSQSClient(2),
+		receiving:      make(chan struct{}),
+		gate:           make(chan struct{}),
+	}
+	w := goller.NewFromConfig(svc, goller.NewDefaultConfig("foo", 1))
+
+	handled := make(chan string, 2)
+	done := listenUntilShutdown(w, func(ctx context.Context, j goller.Job) error {
+		handled <- j.ID()
+		return j.Delete()
+	})
+
+	<-svc.receiving
+
+	shutdown := make(chan error)
+	go func() {
+		shutdown <- w.Shutdown(context.Background())
+	}()
+
+	waitFor(t, func() bool { return w.Status().Draining })
+	close(svc.gate)
+
+	if err := <-shutdown; err != nil {
+		t.Fatal(err)
+	}
+	<-done
+
+	if len(handled) != 0 {
+		t.Errorf("expected messages received after shutdown not to be handled but got `%d`", len(handled))
+	}
+	if released := svc.Released(); len(released) != 2 {
+		t.Errorf("expected messages to be released but got `%v`", released)
+	}
+}
+
+func TestShutdownNotListening(t *testing.T) {
+	w := goller.NewFromConfig(newQueueSQSClient(0), goller.NewDefaultConfig("foo", 1))
+
+	if err := w.Shutdown(context.Background()); err != nil {
+		t.Errorf("expected no error but got `%s`", err)
+	}
+}
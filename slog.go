@@ -0,0 +1,35 @@
+//go:build go1.21
+
+package goller
+
+import "log/slog"
+
+// NewSlogLogger writes to the standard library structured logger.
+func NewSlogLogger(logger *slog.Logger) Logger {
+	return &slogLogger{log: logger}
+}
+
+type slogLogger struct {
+	log *slog.Logger
+}
+
+func (l *slogLogger) WithField(key string, value interface{}) Logger {
+	return &slogLogger{log: l.log.With(slog.Any(key, value))}
+}
+
+func (l *slogLogger) WithFields(fields Fields) Logger {
+	args := make([]interface{}, 0, len(fields))
+	for k, v := range fields {
+		args = append(args, slog.Any(k, v))
+	}
+
+	return &slogLogger{log: l.log.With(args...)}
+}
+
+func (l *slogLogger) WithError(err error) Logger {
+	return l.WithField("error", err)
+}
+
+func (l *slogLogger) Debug(msg string) { l.log.Debug(msg) }
+func (l *slogLogger) Info(msg string)  { l.log.Info(msg) }
+func (l *slogLogger) Error(msg string) { l.log.Error(msg) }
@@ -0,0 +1,31 @@
+//go:build go1.21
+
+package goller_test
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/rcrowe/goller"
+)
+
+func TestSlogLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := goller.NewSlogLogger(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+
+	logger.
+		WithField("foo", "bar").
+		WithFields(goller.Fields{"count": 3}).
+		WithError(errors.New("oh no")).
+		Error("something broke")
+
+	out := buf.String()
+	for _, expected := range []string{"level=ERROR", "foo=bar", "count=3", "error=\"oh no\"", "something broke"} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("expected `%s` in the log but saw `%s`", expected, out)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package spot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ECS task metadata endpoint v4, set within every container.
+const ecsMetadataEnv = "ECS_CONTAINER_METADATA_URI_V4"
+
+// ErrNoECSMetadata is returned when not running on ECS.
+var ErrNoECSMetadata = errors.New(ecsMetadataEnv + " not set")
+
+// ECSSource sees a notice when the task metadata endpoint reports the ECS task is stopping.
+// ECS also sends SIGTERM, so it's usually paired with a SignalSource.
+type ECSSource struct {
+	Client http.Client
+	Log    *logrus.Logger
+
+	// Task metadata endpoint.
+	// Default is ${ECS_CONTAINER_METADATA_URI_V4}/task.
+	Endpoint string
+
+	// How often the endpoint is polled.
+	// Default 5 seconds.
+	Interval time.Duration
+
+	// How long each poll waits for the endpoint to respond.
+	// Default 2 seconds.
+	Timeout time.Duration
+
+	// The container's stopTimeout, how long after the task stops before the container is killed.
+	// Default 30 seconds.
+	StopTimeout time.Duration
+}
+
+// Wait until the task's desired status is STOPPED.
+func (s *ECSSource) Wait(ctx context.Context) (Notice, error) {
+	endpoint := s.Endpoint
+	if endpoint == "" {
+		uri := os.Getenv(ecsMetadataEnv)
+		if uri == "" {
+			return Notice{}, ErrNoECSMetadata
+		}
+		endpoint = uri + "/task"
+	}
+	if s.Log == nil {
+		s.Log = logrus.New()
+		s.Log.Out = ioutil.Discard
+	}
+	interval := s.Interval
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	timeout := s.Timeout
+	if timeout <= 0 {
+		timeout = 2 * time.Second
+	}
+	stopTimeout := s.StopTimeout
+	if stopTimeout <= 0 {
+		stopTimeout = 30 * time.Second
+	}
+
+	logger := s.Log.WithField("endpoint", endpoint)
+	logger.Debug("listening for ecs task stopping")
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if status, err := s.desiredStatus(ctx, endpoint, timeout); err != nil {
+			logger.WithError(err).Error(ErrTerminationConnection)
+		} else if status == "STOPPED" {
+			notice := Notice{
+				Kind:     NoticeECS,
+				Action:   status,
+				Deadline: time.Now().Add(stopTimeout),
+			}
+			logger.WithField("when", notice.Deadline).Info("ecs task stopping")
+
+			return notice, nil
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return Notice{}, ctx.Err()
+		}
+	}
+}
+
+func (s *ECSSource) desiredStatus(ctx context.Context, endpoint string, timeout time.Duration) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", errors.New(resp.Status)
+	}
+
+	var task struct {
+		DesiredStatus string `json:"DesiredStatus"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&task); err != nil {
+		return "", err
+	}
+
+	return task.DesiredStatus, nil
+}
@@ -0,0 +1,104 @@
+package spot_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rcrowe/goller/spot"
+)
+
+func TestECSSource(t *testing.T) {
+	var lock sync.Mutex
+	polls := 0
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v4/abc/task" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		lock.Lock()
+		defer lock.Unlock()
+
+		polls++
+		if polls < 3 {
+			w.Write([]byte(`{"Cluster": "default", "DesiredStatus": "RUNNING", "KnownStatus": "RUNNING"}`))
+			return
+		}
+		w.Write([]byte(`{"Cluster": "default", "DesiredStatus": "STOPPED", "KnownStatus": "RUNNING"}`))
+	}))
+	defer server.Close()
+
+	t.Setenv("ECS_CONTAINER_METADATA_URI_V4", server.URL+"/v4/abc")
+
+	src := &spot.ECSSource{Interval: 5 * time.Millisecond, StopTimeout: time.Minute}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	notice, err := src.Wait(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if notice.Kind != spot.NoticeECS || notice.Action != "STOPPED" {
+		t.Errorf("expected ecs notice but got `%+v`", notice)
+	}
+	if time.Until(notice.Deadline) < 59*time.Second {
+		t.Errorf("expected deadline to be the stop timeout but got `%s`", notice.Deadline)
+	}
+}
+
+func TestECSSourceNotOnECS(t *testing.T) {
+	t.Setenv("ECS_CONTAINER_METADATA_URI_V4", "")
+
+	if _, err := (&spot.ECSSource{}).Wait(context.Background()); err != spot.ErrNoECSMetadata {
+		t.Errorf("expected `%s` but got `%v`", spot.ErrNoECSMetadata, err)
+	}
+}
+
+func TestECSSourceErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	src := &spot.ECSSource{Endpoint: server.URL, Interval: 5 * time.Millisecond}
+	if _, err := src.Wait(ctx); err != context.DeadlineExceeded {
+		t.Errorf("expected errors to keep polling until `%s` but got `%v`", context.DeadlineExceeded, err)
+	}
+}
+
+func TestECSSourceTimeout(t *testing.T) {
+	var lock sync.Mutex
+	polls := 0
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		polls++
+		hang := polls == 1
+		lock.Unlock()
+
+		// First poll never responds
+		if hang {
+			<-r.Context().Done()
+			return
+		}
+		w.Write([]byte(`{"DesiredStatus": "STOPPED"}`))
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	src := &spot.ECSSource{Endpoint: server.URL, Interval: 5 * time.Millisecond, Timeout: 20 * time.Millisecond}
+	if _, err := src.Wait(ctx); err != nil {
+		t.Errorf("expected the hung poll to time out & the next to see the notice but got `%v`", err)
+	}
+}
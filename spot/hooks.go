@@ -0,0 +1,57 @@
+package spot
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+)
+
+// NoticeHook runs alongside draining Goller when a notice is seen, i.e. to flush caches or deregister from
+// service discovery. The context has the notice deadline as its deadline.
+type NoticeHook func(ctx context.Context, notice Notice)
+
+// noticed records the notice & starts each hook in its own goroutine,
+// so a slow hook doesn't hold up the shutdown.
+func noticed(notice Notice, hooks []NoticeHook, log *logrus.Logger) {
+	if notice.Deadline.IsZero() {
+		noticeDeadline.Set(0)
+	} else {
+		noticeDeadline.Set(float64(notice.Deadline.Unix()))
+	}
+
+	for _, hook := range hooks {
+		go func(hook NoticeHook) {
+			ctx, cancel := notice.Context(context.Background(), 0)
+			defer cancel()
+
+			defer func() {
+				if r := recover(); r != nil && log != nil {
+					log.WithError(fmt.Errorf("panic: %s", r)).Error("notice hook paniced")
+				}
+			}()
+
+			hook(ctx, notice)
+		}(hook)
+	}
+}
+
+// WithHooks runs the hooks when the source sees a notice.
+func WithHooks(src Source, hooks ...NoticeHook) Source {
+	return &hookedSource{src: src, hooks: hooks}
+}
+
+type hookedSource struct {
+	src   Source
+	hooks []NoticeHook
+}
+
+func (s *hookedSource) Wait(ctx context.Context) (Notice, error) {
+	notice, err := s.src.Wait(ctx)
+	if err != nil {
+		return notice, err
+	}
+
+	noticed(notice, s.hooks, nil)
+	return notice, nil
+}
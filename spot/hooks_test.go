@@ -0,0 +1,131 @@
+package spot_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rcrowe/goller/spot"
+)
+
+// metricValue returns the value of the gauge or counter with the label, if given.
+func metricValue(t *testing.T, name, label, value string) float64 {
+	metricFamilies, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, metricFamily := range metricFamilies {
+		if metricFamily.GetName() != name {
+			continue
+		}
+
+		for _, metric := range metricFamily.Metric {
+			matches := label == ""
+			for _, pair := range metric.Label {
+				if pair.GetName() == label && pair.GetValue() == value {
+					matches = true
+				}
+			}
+			if !matches {
+				continue
+			}
+
+			if metric.Gauge != nil {
+				return metric.Gauge.GetValue()
+			}
+			return metric.Counter.GetValue()
+		}
+	}
+
+	return 0
+}
+
+func TestOnNotice(t *testing.T) {
+	when := time.Now().Add(2 * time.Minute).Truncate(time.Second)
+	server := terminatingServer(when)
+	defer server.Close()
+
+	deadlines := make(chan time.Time, 2)
+
+	terminator := &spot.TerminationWatcher{Endpoint: server.URL}
+	terminator.OnNotice(func(ctx context.Context, notice spot.Notice) {
+		panic("hooks don't take down the process")
+	})
+	terminator.OnNotice(func(ctx context.Context, notice spot.Notice) {
+		deadline, _ := ctx.Deadline()
+		deadlines <- deadline
+	})
+
+	if _, err := terminator.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case deadline := <-deadlines:
+		if !deadline.Equal(when) {
+			t.Errorf("expected hook context deadline `%s` but got `%s`", when, deadline)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected hook to be called")
+	}
+
+	if actual := metricValue(t, "goller_spot_notice_deadline_seconds", "", ""); actual != float64(when.Unix()) {
+		t.Errorf("expected deadline gauge `%d` but got `%v`", when.Unix(), actual)
+	}
+}
+
+func TestWithHooks(t *testing.T) {
+	called := make(chan spot.Notice, 1)
+
+	src := spot.WithHooks(fakeSource{notice: spot.Notice{Kind: spot.NoticeSignal, Action: "terminated"}}, func(ctx context.Context, notice spot.Notice) {
+		called <- notice
+	})
+
+	if _, err := src.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case notice := <-called:
+		if notice.Kind != spot.NoticeSignal {
+			t.Errorf("expected signal notice but got `%+v`", notice)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected hook to be called")
+	}
+}
+
+func TestPollErrorsByStatusCode(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	before := metricValue(t, "goller_spot_poll_error_total", "code", "503")
+
+	terminator := &spot.TerminationWatcher{Endpoint: server.URL, Interval: time.Millisecond, MaxErrors: 2}
+	terminator.Run(context.Background())
+
+	if actual := metricValue(t, "goller_spot_poll_error_total", "code", "503") - before; actual != 2 {
+		t.Errorf("expected `2` errors with code 503 but got `%v`", actual)
+	}
+}
+
+func TestSinceLastPoll(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	terminator := &spot.TerminationWatcher{Endpoint: server.URL, Interval: time.Millisecond}
+	terminator.Run(ctx)
+
+	if since := metricValue(t, "goller_spot_since_last_poll_seconds", "", ""); since <= 0 || since > 1 {
+		t.Errorf("expected a recent successful poll but got `%v` seconds ago", since)
+	}
+}
@@ -0,0 +1,165 @@
+package spot
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	metadataTokenPath = "/latest/api/token"
+	tokenTTLHeader    = "X-aws-ec2-metadata-token-ttl-seconds"
+	tokenHeader       = "X-aws-ec2-metadata-token"
+
+	// IMDS maximum session token TTL.
+	maxTokenTTL = 6 * time.Hour
+
+	// How long fetching a session token can take, leaving the rest of the poll's Timeout for IMDSv1.
+	tokenTimeout = 500 * time.Millisecond
+
+	// How long IMDSv1 is used without trying for a session token, once one couldn't be fetched.
+	tokenRetryInterval = time.Minute
+)
+
+// imdsToken caches the IMDSv2 session token until shortly before it expires,
+// and remembers when IMDSv2 is unavailable.
+type imdsToken struct {
+	mu          sync.Mutex
+	value       string
+	expires     time.Time
+	unavailable time.Time
+}
+
+func (t *imdsToken) get(now time.Time) string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if now.After(t.expires) {
+		return ""
+	}
+
+	return t.value
+}
+
+func (t *imdsToken) set(value string, expires time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.value = value
+	t.expires = expires
+}
+
+// skip returns whether IMDSv2 was recently unavailable.
+func (t *imdsToken) skip(now time.Time) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return now.Before(t.unavailable)
+}
+
+func (t *imdsToken) failed(until time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.unavailable = until
+}
+
+func (t *imdsToken) reset() {
+	t.set("", time.Time{})
+	t.failed(time.Time{})
+}
+
+// tokenEndpoint defaults to the token path on the same host as the metadata endpoint.
+func (w *TerminationWatcher) tokenEndpoint() string {
+	if w.TokenEndpoint != "" {
+		return w.TokenEndpoint
+	}
+
+	return w.metadataURL(metadataTokenPath)
+}
+
+// sessionToken returns the cached token, fetching a new one when it's about to expire.
+// Fetching has its own, shorter, timeout so a poll falling back to IMDSv1 has time left to run.
+func (w *TerminationWatcher) sessionToken(ctx context.Context) (string, error) {
+	now := time.Now()
+	if token := w.token.get(now); token != "" {
+		return token, nil
+	}
+
+	ttl := w.TokenTTL
+	if ttl <= 0 || ttl > maxTokenTTL {
+		ttl = maxTokenTTL
+	}
+
+	timeout := tokenTimeout
+	if w.Timeout/2 < timeout {
+		timeout = w.Timeout / 2
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, w.tokenEndpoint(), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set(tokenTTLHeader, strconv.Itoa(int(ttl.Seconds())))
+
+	resp, err := w.Client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token endpoint responded with %d", resp.StatusCode)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+
+	// Refresh before it expires, so a poll never goes out with a stale token
+	w.token.set(string(body), now.Add(ttl-ttl/10))
+
+	return string(body), nil
+}
+
+// get the metadata endpoint, sending the IMDSv2 session token.
+// Falls back to IMDSv1 when a token can't be fetched, unless disabled. After falling back
+// IMDSv1 is used for tokenRetryInterval before trying for a token again.
+func (w *TerminationWatcher) get(ctx context.Context, endpoint string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if w.DisableIMDSv1Fallback || !w.token.skip(time.Now()) {
+		token, err := w.sessionToken(ctx)
+		if err != nil {
+			if w.DisableIMDSv1Fallback {
+				return nil, err
+			}
+			w.Log.WithError(err).Debug(ErrTokenFallback)
+			w.token.failed(time.Now().Add(tokenRetryInterval))
+		} else {
+			req.Header.Set(tokenHeader, token)
+		}
+	}
+
+	resp, err := w.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Token no longer valid or IMDSv1 refused, fetch a new one next time
+	if resp.StatusCode == http.StatusUnauthorized {
+		w.token.reset()
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,242 @@
+package spot_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rcrowe/goller/spot"
+	"github.com/sirupsen/logrus"
+)
+
+// imds stands in for the instance metadata service, enforcing IMDSv2 when required.
+type imds struct {
+	lock     sync.Mutex
+	required bool
+	tokens   int
+	ttls     []string
+	polls    int
+	when     string
+}
+
+func (s *imds) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if r.URL.Path == "/latest/api/token" {
+		if r.Method != http.MethodPut || r.Header.Get("X-aws-ec2-metadata-token-ttl-seconds") == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		s.tokens++
+		s.ttls = append(s.ttls, r.Header.Get("X-aws-ec2-metadata-token-ttl-seconds"))
+		w.Write([]byte("token-" + strconv.Itoa(s.tokens)))
+		return
+	}
+
+	s.polls++
+	if s.required && r.Header.Get("X-aws-ec2-metadata-token") != "token-"+strconv.Itoa(s.tokens) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	if s.when == "" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	w.Write([]byte(s.when))
+}
+
+func (s *imds) Tokens() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.tokens
+}
+
+func (s *imds) Polls() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.polls
+}
+
+func TestIMDSv2Token(t *testing.T) {
+	metadata := &imds{required: true, when: "2006-01-02T15:04:05Z"}
+	server := httptest.NewServer(metadata)
+	defer server.Close()
+
+	terminator := &spot.TerminationWatcher{
+		Endpoint: server.URL + "/latest/meta-data/spot/termination-time",
+		TokenTTL: time.Minute,
+	}
+
+	select {
+	case <-terminator.Listen():
+	case <-time.After(time.Second):
+		t.Fatal("expected termination to be seen using the session token")
+	}
+
+	if metadata.ttls[0] != "60" {
+		t.Errorf("expected token TTL of `60` but got `%s`", metadata.ttls[0])
+	}
+}
+
+func TestIMDSv2TokenCached(t *testing.T) {
+	metadata := &imds{required: true}
+	server := httptest.NewServer(metadata)
+	defer server.Close()
+
+	ticker := time.NewTicker(5 * time.Millisecond)
+	terminator := &spot.TerminationWatcher{Endpoint: server.URL, Ticker: ticker}
+	terminator.Listen()
+
+	time.Sleep(50 * time.Millisecond)
+	ticker.Stop()
+
+	if metadata.Polls() < 2 {
+		t.Fatalf("expected several polls but got `%d`", metadata.Polls())
+	}
+	if metadata.Tokens() != 1 {
+		t.Errorf("expected token to be reused but fetched `%d`", metadata.Tokens())
+	}
+}
+
+func TestIMDSv2TokenRefreshedOnUnauthorized(t *testing.T) {
+	metadata := &imds{required: true}
+	server := httptest.NewServer(metadata)
+	defer server.Close()
+
+	ticker := time.NewTicker(5 * time.Millisecond)
+	terminator := &spot.TerminationWatcher{Endpoint: server.URL, Ticker: ticker}
+	terminator.Listen()
+
+	time.Sleep(20 * time.Millisecond)
+
+	// Invalidate the token the watcher holds
+	metadata.lock.Lock()
+	metadata.tokens++
+	metadata.lock.Unlock()
+
+	time.Sleep(30 * time.Millisecond)
+	ticker.Stop()
+
+	if metadata.Tokens() < 3 {
+		t.Errorf("expected a new token after being unauthorized but got `%d`", metadata.Tokens())
+	}
+}
+
+func TestIMDSv1Fallback(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if r.Header.Get("X-aws-ec2-metadata-token") != "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte("2006-01-02T15:04:05Z"))
+	}))
+	defer server.Close()
+
+	terminator := &spot.TerminationWatcher{Endpoint: server.URL}
+
+	select {
+	case <-terminator.Listen():
+	case <-time.After(time.Second):
+		t.Fatal("expected termination to be seen using IMDSv1")
+	}
+}
+
+func TestIMDSv1FallbackDisabled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Write([]byte("2006-01-02T15:04:05Z"))
+	}))
+	defer server.Close()
+
+	writer := &stackedWriter{}
+	logger := logrus.New()
+	logger.Out = writer
+	ticker := time.NewTicker(10 * time.Second)
+
+	terminator := &spot.TerminationWatcher{
+		Endpoint:              server.URL,
+		Log:                   logger,
+		Ticker:                ticker,
+		DisableIMDSv1Fallback: true,
+	}
+	ch := terminator.Listen()
+	ticker.Stop()
+
+	select {
+	case <-ch:
+		t.Fatal("expected termination not to be seen without a session token")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if !writer.Contains("token endpoint responded with 403") {
+		t.Error("expected token error to be logged")
+	}
+}
+
+func TestIMDSv1FallbackWhenTokenHangs(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut {
+			<-r.Context().Done()
+			return
+		}
+		w.Write([]byte("2006-01-02T15:04:05Z"))
+	}))
+	defer server.Close()
+
+	// Token request mustn't use up the whole poll timeout
+	terminator := &spot.TerminationWatcher{Endpoint: server.URL, Timeout: time.Second}
+
+	select {
+	case <-terminator.Listen():
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected termination to be seen using IMDSv1")
+	}
+}
+
+func TestIMDSv2UnavailableRemembered(t *testing.T) {
+	var lock sync.Mutex
+	tokens, polls := 0, 0
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+
+		if r.Method == http.MethodPut {
+			tokens++
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		polls++
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	ticker := time.NewTicker(5 * time.Millisecond)
+	terminator := &spot.TerminationWatcher{Endpoint: server.URL, Ticker: ticker}
+	terminator.Listen()
+
+	time.Sleep(50 * time.Millisecond)
+	ticker.Stop()
+
+	lock.Lock()
+	defer lock.Unlock()
+	if polls < 2 {
+		t.Fatalf("expected several polls but got `%d`", polls)
+	}
+	if tokens != 1 {
+		t.Errorf("expected IMDSv1 to be used without asking for a token again but fetched `%d`", tokens)
+	}
+}
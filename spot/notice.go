@@ -0,0 +1,124 @@
+package spot
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// NoticeKind is the metadata endpoint a notice came from.
+type NoticeKind string
+
+const (
+	// NoticeTermination is the spot/termination-time endpoint,
+	// set about 2 minutes before the instance is terminated.
+	NoticeTermination NoticeKind = "termination"
+
+	// NoticeInstanceAction is the spot/instance-action endpoint,
+	// set about 2 minutes before the instance is stopped, hibernated or terminated.
+	NoticeInstanceAction NoticeKind = "instance-action"
+
+	// NoticeRebalance is the events/recommendations/rebalance endpoint,
+	// set when the instance is at an elevated risk of interruption. Usually well before any instance action.
+	NoticeRebalance NoticeKind = "rebalance"
+
+	// NoticeLifecycle is the autoscaling/target-lifecycle-state endpoint,
+	// set to Terminated when an auto scaling group is taking the instance out of service.
+	NoticeLifecycle NoticeKind = "lifecycle"
+
+	// NoticeSignal is the process receiving a signal, see SignalSource.
+	NoticeSignal NoticeKind = "signal"
+
+	// NoticeECS is the ECS task being stopped, see ECSSource.
+	NoticeECS NoticeKind = "ecs"
+)
+
+// Instance actions.
+const (
+	ActionTerminate = "terminate"
+	ActionStop      = "stop"
+	ActionHibernate = "hibernate"
+)
+
+// Auto scaling target lifecycle state when the instance is being terminated.
+const lifecycleTerminated = "Terminated"
+
+// Metadata paths for each kind of notice.
+var noticePaths = map[NoticeKind]string{
+	NoticeTermination:    "/latest/meta-data/spot/termination-time",
+	NoticeInstanceAction: "/latest/meta-data/spot/instance-action",
+	NoticeRebalance:      "/latest/meta-data/events/recommendations/rebalance",
+	NoticeLifecycle:      "/latest/meta-data/autoscaling/target-lifecycle-state",
+}
+
+// errNotNoticed is returned when the endpoint responds, but without a notice.
+var errNotNoticed = errors.New("no notice")
+
+// Notice that the spot instance is going to be interrupted.
+type Notice struct {
+	Kind NoticeKind
+
+	// What happens to the instance; terminate, stop or hibernate.
+	// The lifecycle state or signal for those kinds of notice.
+	// Empty for rebalance recommendations.
+	Action string
+
+	// When the action happens.
+	// Zero for rebalance recommendations, which have no deadline.
+	Deadline time.Time
+}
+
+// parseNotice from the body of the metadata endpoint.
+func parseNotice(kind NoticeKind, body []byte) (Notice, error) {
+	notice := Notice{Kind: kind}
+
+	switch kind {
+	case NoticeTermination:
+		when, err := time.Parse(terminationTimeFormat, string(body))
+		if err != nil {
+			return notice, err
+		}
+
+		notice.Action = ActionTerminate
+		notice.Deadline = when
+	case NoticeInstanceAction:
+		var action struct {
+			Action string `json:"action"`
+			Time   string `json:"time"`
+		}
+		if err := json.Unmarshal(body, &action); err != nil {
+			return notice, err
+		}
+
+		when, err := time.Parse(terminationTimeFormat, action.Time)
+		if err != nil {
+			return notice, err
+		}
+
+		notice.Action = action.Action
+		notice.Deadline = when
+	case NoticeRebalance:
+		var rebalance struct {
+			NoticeTime string `json:"noticeTime"`
+		}
+		if err := json.Unmarshal(body, &rebalance); err != nil {
+			return notice, err
+		}
+		if _, err := time.Parse(terminationTimeFormat, rebalance.NoticeTime); err != nil {
+			return notice, err
+		}
+	case NoticeLifecycle:
+		state := strings.TrimSpace(string(body))
+		if state != lifecycleTerminated {
+			return notice, errNotNoticed
+		}
+
+		notice.Action = state
+	default:
+		return notice, fmt.Errorf("unknown notice kind %q", kind)
+	}
+
+	return notice, nil
+}
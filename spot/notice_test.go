@@ -0,0 +1,149 @@
+package spot_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rcrowe/goller/spot"
+)
+
+func noticeServer(instanceAction, rebalance string) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/latest/meta-data/spot/termination-time", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	mux.HandleFunc("/latest/meta-data/spot/instance-action", func(w http.ResponseWriter, r *http.Request) {
+		if instanceAction == "" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(instanceAction))
+	})
+	mux.HandleFunc("/latest/meta-data/events/recommendations/rebalance", func(w http.ResponseWriter, r *http.Request) {
+		if rebalance == "" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(rebalance))
+	})
+
+	return httptest.NewServer(mux)
+}
+
+func waitNotice(t *testing.T, server *httptest.Server, kinds ...spot.NoticeKind) (spot.Notice, bool) {
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+
+	terminator := &spot.TerminationWatcher{
+		Endpoint: server.URL + "/latest/meta-data/spot/termination-time",
+		Kinds:    kinds,
+		Ticker:   ticker,
+	}
+
+	select {
+	case notice := <-terminator.ListenNotice():
+		return notice, true
+	case <-time.After(100 * time.Millisecond):
+		return spot.Notice{}, false
+	}
+}
+
+func TestInstanceActionNotice(t *testing.T) {
+	server := noticeServer(`{"action": "stop", "time": "2017-09-18T08:22:00Z"}`, "")
+	defer server.Close()
+
+	notice, ok := waitNotice(t, server, spot.NoticeTermination, spot.NoticeInstanceAction)
+	if !ok {
+		t.Fatal("expected instance action notice")
+	}
+
+	expected := spot.Notice{
+		Kind:     spot.NoticeInstanceAction,
+		Action:   spot.ActionStop,
+		Deadline: time.Date(2017, 9, 18, 8, 22, 0, 0, time.UTC),
+	}
+	if notice != expected {
+		t.Errorf("expected `%+v` but got `%+v`", expected, notice)
+	}
+}
+
+func TestRebalanceNotice(t *testing.T) {
+	server := noticeServer("", `{"noticeTime": "2020-10-27T08:22:00Z"}`)
+	defer server.Close()
+
+	notice, ok := waitNotice(t, server, spot.NoticeRebalance)
+	if !ok {
+		t.Fatal("expected rebalance notice")
+	}
+
+	if notice.Kind != spot.NoticeRebalance || notice.Action != "" || !notice.Deadline.IsZero() {
+		t.Errorf("expected rebalance notice without a deadline but got `%+v`", notice)
+	}
+}
+
+func TestNoticeKindsNotWatched(t *testing.T) {
+	server := noticeServer(`{"action": "terminate", "time": "2017-09-18T08:22:00Z"}`, `{"noticeTime": "2020-10-27T08:22:00Z"}`)
+	defer server.Close()
+
+	if notice, ok := waitNotice(t, server); ok {
+		t.Errorf("expected only termination time to be watched by default but got `%+v`", notice)
+	}
+}
+
+func TestInvalidNotice(t *testing.T) {
+	server := noticeServer(`{"action": "stop"}`, `nope`)
+	defer server.Close()
+
+	if notice, ok := waitNotice(t, server, spot.NoticeInstanceAction, spot.NoticeRebalance); ok {
+		t.Errorf("expected invalid notices to be ignored but got `%+v`", notice)
+	}
+}
+
+func TestLifecycleNotice(t *testing.T) {
+	state := "InService"
+	var lock sync.Mutex
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/latest/meta-data/autoscaling/target-lifecycle-state" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		lock.Lock()
+		defer lock.Unlock()
+		w.Write([]byte(state))
+	}))
+	defer server.Close()
+
+	ticker := time.NewTicker(5 * time.Millisecond)
+	defer ticker.Stop()
+
+	terminator := &spot.TerminationWatcher{
+		Endpoint: server.URL,
+		Kinds:    []spot.NoticeKind{spot.NoticeLifecycle},
+		Ticker:   ticker,
+	}
+	notices := terminator.ListenNotice()
+
+	select {
+	case notice := <-notices:
+		t.Fatalf("expected no notice while in service but got `%+v`", notice)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	lock.Lock()
+	state = "Terminated"
+	lock.Unlock()
+
+	select {
+	case notice := <-notices:
+		if notice.Kind != spot.NoticeLifecycle || notice.Action != "Terminated" {
+			t.Errorf("expected lifecycle notice but got `%+v`", notice)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected lifecycle notice")
+	}
+}
@@ -0,0 +1,78 @@
+package spot
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	pollTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "goller",
+		Subsystem: "spot",
+		Name:      "poll_total",
+		Help:      "Counter for number of polls of the metadata endpoints.",
+	})
+
+	pollErrorTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "goller",
+		Subsystem: "spot",
+		Name:      "poll_error_total",
+		Help:      "Counter for number of failed polls of the metadata endpoints, by response status code.",
+	}, []string{"code"})
+
+	sinceLastPoll = prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: "goller",
+		Subsystem: "spot",
+		Name:      "since_last_poll_seconds",
+		Help:      "Time since the metadata endpoints were last polled successfully, or since the watcher started.",
+	}, func() float64 {
+		last := atomic.LoadInt64(&lastPollSuccess)
+		if last == 0 {
+			return 0
+		}
+
+		return time.Since(time.Unix(0, last)).Seconds()
+	})
+
+	noticeTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "goller",
+		Subsystem: "spot",
+		Name:      "notice_total",
+		Help:      "Counter for number of notices seen on the metadata endpoints.",
+	})
+
+	noticeDeadline = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "goller",
+		Subsystem: "spot",
+		Name:      "notice_deadline_seconds",
+		Help:      "Unix time of the deadline given by the last notice. 0 when no notice has a deadline.",
+	})
+)
+
+// Unix nanoseconds of the last successful poll.
+var lastPollSuccess int64
+
+// Poll error codes that aren't a response status code
+const (
+	pollErrorConnection = "connection"
+	pollErrorInvalid    = "invalid"
+)
+
+func init() {
+	prometheus.MustRegister(pollTotal)
+	prometheus.MustRegister(pollErrorTotal)
+	prometheus.MustRegister(sinceLastPoll)
+	prometheus.MustRegister(noticeTotal)
+	prometheus.MustRegister(noticeDeadline)
+}
+
+// pollStarted starts the clock on the time since the last successful poll.
+func pollStarted() {
+	atomic.CompareAndSwapInt64(&lastPollSuccess, 0, time.Now().UnixNano())
+}
+
+func pollSucceeded() {
+	atomic.StoreInt64(&lastPollSuccess, time.Now().UnixNano())
+}
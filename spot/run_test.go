@@ -0,0 +1,177 @@
+package spot_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rcrowe/goller/spot"
+)
+
+func counterValue(t *testing.T, name string) float64 {
+	metricFamilies, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Summed across labels
+	total := 0.0
+	for _, metricFamily := range metricFamilies {
+		if metricFamily.GetName() == name {
+			for _, metric := range metricFamily.Metric {
+				total += metric.Counter.GetValue()
+			}
+		}
+	}
+
+	return total
+}
+
+func TestRunContextDone(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+
+	polls := counterValue(t, "goller_spot_poll_total")
+
+	terminator := &spot.TerminationWatcher{Endpoint: server.URL, Interval: 5 * time.Millisecond, Jitter: time.Millisecond}
+	if _, err := terminator.Run(ctx); err != context.DeadlineExceeded {
+		t.Errorf("expected `%s` but got `%v`", context.DeadlineExceeded, err)
+	}
+
+	if counterValue(t, "goller_spot_poll_total")-polls < 2 {
+		t.Error("expected polls to be counted")
+	}
+}
+
+func TestStop(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	terminator := &spot.TerminationWatcher{Endpoint: server.URL, Interval: time.Hour}
+	notices := terminator.ListenNotice()
+
+	time.Sleep(10 * time.Millisecond)
+	terminator.Stop()
+
+	select {
+	case notice, ok := <-notices:
+		if ok {
+			t.Errorf("expected channel to be closed without a notice but got `%+v`", notice)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected watcher to stop")
+	}
+}
+
+func TestStopBeforeRun(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	terminator := &spot.TerminationWatcher{Endpoint: server.URL, Interval: time.Hour}
+	terminator.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if _, err := terminator.Run(ctx); err != context.Canceled {
+		t.Errorf("expected `%s` but got `%v`", context.Canceled, err)
+	}
+}
+
+func TestMaxErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	errors := counterValue(t, "goller_spot_poll_error_total")
+
+	terminator := &spot.TerminationWatcher{Endpoint: server.URL, Interval: time.Millisecond, MaxErrors: 3}
+	if _, err := terminator.Run(context.Background()); err != spot.ErrPollFailing {
+		t.Errorf("expected `%s` but got `%v`", spot.ErrPollFailing, err)
+	}
+
+	if actual := counterValue(t, "goller_spot_poll_error_total") - errors; actual != 3 {
+		t.Errorf("expected `3` errors to be counted but got `%v`", actual)
+	}
+}
+
+func TestListenNoticePollFailing(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	terminator := &spot.TerminationWatcher{Endpoint: server.URL, Interval: time.Millisecond, MaxErrors: 2}
+
+	select {
+	case _, ok := <-terminator.ListenNotice():
+		if ok {
+			t.Error("expected channel to be closed without a notice")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected watcher to give up")
+	}
+
+	if err := terminator.Err(); err != spot.ErrPollFailing {
+		t.Errorf("expected `%s` but got `%v`", spot.ErrPollFailing, err)
+	}
+}
+
+func TestMaxErrorsResetOnSuccess(t *testing.T) {
+	polls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		polls++
+		switch {
+		case polls == 6:
+			w.Write([]byte("2006-01-02T15:04:05Z"))
+		case polls%2 == 0:
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	terminator := &spot.TerminationWatcher{Endpoint: server.URL, Interval: time.Millisecond, MaxErrors: 2}
+	if _, err := terminator.Run(context.Background()); err != nil {
+		t.Errorf("expected successful polls to reset the error count but got `%s`", err)
+	}
+}
+
+func TestPollTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(time.Second):
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+
+	terminator := &spot.TerminationWatcher{
+		Endpoint:              server.URL,
+		Interval:              time.Millisecond,
+		Timeout:               10 * time.Millisecond,
+		MaxErrors:             2,
+		DisableIMDSv1Fallback: true,
+	}
+
+	start := time.Now()
+	if _, err := terminator.Run(context.Background()); err != spot.ErrPollFailing {
+		t.Errorf("expected `%s` but got `%v`", spot.ErrPollFailing, err)
+	}
+	if time.Since(start) > 500*time.Millisecond {
+		t.Errorf("expected polls to time out but took `%s`", time.Since(start))
+	}
+}
@@ -0,0 +1,38 @@
+package spot
+
+import (
+	"context"
+	"time"
+)
+
+// Shutdowner drains work before the instance goes away, goller.Worker satisfies it.
+type Shutdowner interface {
+	Shutdown(ctx context.Context) error
+}
+
+// Context that has the notice deadline, less the margin, as its deadline.
+// Notices without a deadline, i.e. rebalance recommendations, return a context without one.
+func (n Notice) Context(parent context.Context, margin time.Duration) (context.Context, context.CancelFunc) {
+	if n.Deadline.IsZero() {
+		return context.WithCancel(parent)
+	}
+
+	return context.WithDeadline(parent, n.Deadline.Add(-margin))
+}
+
+// ShutdownContext blocks until a notice is seen, returning a context whose deadline is
+// the notice deadline less the margin. See ShutdownContext(...).
+func (w *TerminationWatcher) ShutdownContext(parent context.Context, margin time.Duration) (context.Context, context.CancelFunc) {
+	return ShutdownContext(parent, w, margin)
+}
+
+// ListenAndShutdown will shutdown Goller when a notice is seen,
+// giving in-flight jobs until the notice deadline less the margin to finish.
+// Unfinished work released at the deadline is logged to Log.
+func (w *TerminationWatcher) ListenAndShutdown(s Shutdowner, margin time.Duration) {
+	w.mu.Lock()
+	w.defaults()
+	w.mu.Unlock()
+
+	ListenAndShutdown(w, s, margin, w.Log)
+}
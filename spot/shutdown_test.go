@@ -0,0 +1,136 @@
+package spot_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rcrowe/goller/spot"
+	"github.com/sirupsen/logrus"
+)
+
+func TestNoticeContext(t *testing.T) {
+	deadline := time.Now().Add(2 * time.Minute)
+
+	ctx, cancel := spot.Notice{Kind: spot.NoticeTermination, Deadline: deadline}.Context(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if actual, ok := ctx.Deadline(); !ok || !actual.Equal(deadline.Add(-30*time.Second)) {
+		t.Errorf("expected deadline `%s` but got `%s`", deadline.Add(-30*time.Second), actual)
+	}
+
+	ctx, cancel = spot.Notice{Kind: spot.NoticeRebalance}.Context(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if _, ok := ctx.Deadline(); ok {
+		t.Error("expected rebalance context not to have a deadline")
+	}
+}
+
+func terminatingServer(when time.Time) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(when.UTC().Format("2006-01-02T15:04:05Z")))
+	}))
+}
+
+func TestShutdownContext(t *testing.T) {
+	when := time.Now().Add(2 * time.Minute).Truncate(time.Second)
+	server := terminatingServer(when)
+	defer server.Close()
+
+	terminator := &spot.TerminationWatcher{Endpoint: server.URL}
+
+	ctx, cancel := terminator.ShutdownContext(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if actual, ok := ctx.Deadline(); !ok || !actual.Equal(when.Add(-10*time.Second)) {
+		t.Errorf("expected deadline `%s` but got `%s`", when.Add(-10*time.Second), actual)
+	}
+}
+
+func TestShutdownContextParentDone(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	terminator := &spot.TerminationWatcher{Endpoint: server.URL, Ticker: ticker}
+
+	parent, stop := context.WithCancel(context.Background())
+	stop()
+
+	ctx, cancel := terminator.ShutdownContext(parent, time.Second)
+	defer cancel()
+
+	if ctx.Err() == nil {
+		t.Error("expected context to be done with its parent")
+	}
+}
+
+type shutdownRecorder struct {
+	deadline chan time.Time
+	err      error
+}
+
+func (s *shutdownRecorder) Shutdown(ctx context.Context) error {
+	deadline, _ := ctx.Deadline()
+	s.deadline <- deadline
+	return s.err
+}
+
+// errorHook passes on the message of each error logged.
+type errorHook chan string
+
+func (h errorHook) Levels() []logrus.Level {
+	return []logrus.Level{logrus.ErrorLevel}
+}
+
+func (h errorHook) Fire(entry *logrus.Entry) error {
+	h <- entry.Message
+	return nil
+}
+
+func TestListenAndShutdown(t *testing.T) {
+	when := time.Now().Add(2 * time.Minute).Truncate(time.Second)
+	server := terminatingServer(when)
+	defer server.Close()
+
+	recorder := &shutdownRecorder{deadline: make(chan time.Time)}
+	terminator := &spot.TerminationWatcher{Endpoint: server.URL}
+	terminator.ListenAndShutdown(recorder, 15*time.Second)
+
+	select {
+	case deadline := <-recorder.deadline:
+		if !deadline.Equal(when.Add(-15 * time.Second)) {
+			t.Errorf("expected deadline `%s` but got `%s`", when.Add(-15*time.Second), deadline)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected shutdown to be called")
+	}
+}
+
+func TestListenAndShutdownUnfinished(t *testing.T) {
+	server := terminatingServer(time.Now().Add(2 * time.Minute))
+	defer server.Close()
+
+	hook := make(errorHook, 1)
+	log := logrus.New()
+	log.Out = ioutil.Discard
+	log.AddHook(hook)
+
+	recorder := &shutdownRecorder{deadline: make(chan time.Time, 1), err: context.DeadlineExceeded}
+	terminator := &spot.TerminationWatcher{Endpoint: server.URL, Log: log}
+	terminator.ListenAndShutdown(recorder, 15*time.Second)
+
+	select {
+	case msg := <-hook:
+		if msg != "unfinished work released at shutdown deadline" {
+			t.Errorf("expected unfinished work to be logged but got `%s`", msg)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected unfinished work to be logged")
+	}
+}
@@ -0,0 +1,153 @@
+package spot
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Source of notices that the instance, container or pod is about to go away.
+type Source interface {
+	// Wait blocks until a notice is seen, returning the context's error when it's done first.
+	Wait(ctx context.Context) (Notice, error)
+}
+
+// Wait until a notice is seen on the EC2 meta endpoints.
+func (w *TerminationWatcher) Wait(ctx context.Context) (Notice, error) {
+	return w.Run(ctx)
+}
+
+// SignalSource sees a notice when the process receives a signal, i.e. SIGTERM from Kubernetes or ECS.
+type SignalSource struct {
+	// Signals to listen for.
+	// Default is SIGTERM & SIGINT.
+	Signals []os.Signal
+
+	// How long the process has after the signal before it's killed, e.g. the pod's terminationGracePeriodSeconds.
+	// Zero value leaves the notice without a deadline.
+	GracePeriod time.Duration
+}
+
+// Wait until one of the signals is received.
+func (s *SignalSource) Wait(ctx context.Context) (Notice, error) {
+	signals := s.Signals
+	if len(signals) == 0 {
+		signals = []os.Signal{syscall.SIGTERM, os.Interrupt}
+	}
+
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, signals...)
+	defer signal.Stop(ch)
+
+	select {
+	case sig := <-ch:
+		notice := Notice{Kind: NoticeSignal, Action: sig.String()}
+		if s.GracePeriod > 0 {
+			notice.Deadline = time.Now().Add(s.GracePeriod)
+		}
+		return notice, nil
+	case <-ctx.Done():
+		return Notice{}, ctx.Err()
+	}
+}
+
+// Any sees the first notice from any of the sources.
+// When every source gives up without a notice, the last of their errors is returned.
+func Any(sources ...Source) Source {
+	return anySource(sources)
+}
+
+type anySource []Source
+
+func (sources anySource) Wait(ctx context.Context) (Notice, error) {
+	if len(sources) == 0 {
+		<-ctx.Done()
+		return Notice{}, ctx.Err()
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		notice Notice
+		err    error
+	}
+
+	// Buffered so the sources that lose don't block
+	results := make(chan result, len(sources))
+	for _, src := range sources {
+		go func(src Source) {
+			notice, err := src.Wait(ctx)
+			results <- result{notice, err}
+		}(src)
+	}
+
+	// Every source gave up, return the last of their errors
+	var err error
+	for range sources {
+		r := <-results
+		if r.err == nil {
+			return r.notice, nil
+		}
+		if ctx.Err() != nil {
+			return Notice{}, ctx.Err()
+		}
+		err = r.err
+	}
+
+	return Notice{}, err
+}
+
+// ShutdownContext blocks until the source sees a notice, returning a context whose deadline is
+// the notice deadline less the margin. The margin leaves time to release unfinished work.
+// When no notice is seen, i.e. the parent is done first or the source gives up, the returned context is already done.
+func ShutdownContext(parent context.Context, src Source, margin time.Duration) (context.Context, context.CancelFunc) {
+	notice, err := src.Wait(parent)
+	if err != nil {
+		ctx, cancel := context.WithCancel(parent)
+		cancel()
+		return ctx, cancel
+	}
+
+	return notice.Context(parent, margin)
+}
+
+// ListenAndCancel will cancel a context when the source sees a notice.
+// Nothing is cancelled if the source gives up without one, e.g. TerminationWatcher.Stop().
+func ListenAndCancel(src Source, cancel context.CancelFunc) {
+	go func() {
+		if _, err := src.Wait(context.Background()); err == nil {
+			cancel()
+		}
+	}()
+}
+
+// ListenAndShutdown will shutdown Goller when the source sees a notice,
+// giving in-flight jobs until the notice deadline less the margin to finish.
+// Nothing is shutdown if the source gives up without a notice.
+// Unfinished work released at the deadline is logged to log, nil logs nothing.
+func ListenAndShutdown(src Source, s Shutdowner, margin time.Duration, log *logrus.Logger) {
+	if log == nil {
+		log = logrus.New()
+		log.Out = ioutil.Discard
+	}
+
+	go func() {
+		notice, err := src.Wait(context.Background())
+		if err != nil {
+			return
+		}
+
+		ctx, cancel := notice.Context(context.Background(), margin)
+		defer cancel()
+
+		if err := s.Shutdown(ctx); err != nil {
+			log.WithError(err).Error("unfinished work released at shutdown deadline")
+		}
+	}()
+}
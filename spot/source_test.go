@@ -0,0 +1,187 @@
+package spot_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/rcrowe/goller/spot"
+	"github.com/sirupsen/logrus"
+)
+
+// fakeSource sees a notice after the delay.
+type fakeSource struct {
+	notice spot.Notice
+	after  time.Duration
+}
+
+func (s fakeSource) Wait(ctx context.Context) (spot.Notice, error) {
+	select {
+	case <-time.After(s.after):
+		return s.notice, nil
+	case <-ctx.Done():
+		return spot.Notice{}, ctx.Err()
+	}
+}
+
+// failingSource gives up without a notice, i.e. a stopped watcher.
+type failingSource struct {
+	err error
+}
+
+func (s failingSource) Wait(ctx context.Context) (spot.Notice, error) {
+	return spot.Notice{}, s.err
+}
+
+func TestSignalSource(t *testing.T) {
+	src := &spot.SignalSource{Signals: []os.Signal{syscall.SIGHUP}, GracePeriod: 30 * time.Second}
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		syscall.Kill(os.Getpid(), syscall.SIGHUP)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	notice, err := src.Wait(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if notice.Kind != spot.NoticeSignal || notice.Action != "hangup" {
+		t.Errorf("expected hangup signal notice but got `%+v`", notice)
+	}
+	if time.Until(notice.Deadline) < 29*time.Second {
+		t.Errorf("expected deadline to be the grace period but got `%s`", notice.Deadline)
+	}
+}
+
+func TestSignalSourceContextDone(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if _, err := (&spot.SignalSource{}).Wait(ctx); err != context.DeadlineExceeded {
+		t.Errorf("expected `%s` but got `%v`", context.DeadlineExceeded, err)
+	}
+}
+
+func TestAnySource(t *testing.T) {
+	src := spot.Any(
+		fakeSource{notice: spot.Notice{Kind: spot.NoticeRebalance}, after: time.Second},
+		fakeSource{notice: spot.Notice{Kind: spot.NoticeSignal}, after: 10 * time.Millisecond},
+		&spot.ECSSource{Endpoint: "~~~"},
+	)
+
+	notice, err := src.Wait(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if notice.Kind != spot.NoticeSignal {
+		t.Errorf("expected the first notice but got `%+v`", notice)
+	}
+}
+
+func TestAnySourceContextDone(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	src := spot.Any(fakeSource{after: time.Second}, fakeSource{after: time.Second})
+	if _, err := src.Wait(ctx); err != context.DeadlineExceeded {
+		t.Errorf("expected `%s` but got `%v`", context.DeadlineExceeded, err)
+	}
+}
+
+func TestAnySourceGivesUp(t *testing.T) {
+	src := spot.Any(failingSource{err: spot.ErrNoECSMetadata}, failingSource{err: spot.ErrPollFailing})
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := src.Wait(context.Background())
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err != spot.ErrNoECSMetadata && err != spot.ErrPollFailing {
+			t.Errorf("expected a source's error but got `%v`", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected Wait to return once every source gave up")
+	}
+}
+
+func TestListenAndShutdownSource(t *testing.T) {
+	deadline := time.Now().Add(time.Minute)
+	recorder := &shutdownRecorder{deadline: make(chan time.Time)}
+
+	spot.ListenAndShutdown(fakeSource{notice: spot.Notice{Kind: spot.NoticeECS, Deadline: deadline}}, recorder, 5*time.Second, nil)
+
+	select {
+	case actual := <-recorder.deadline:
+		if !actual.Equal(deadline.Add(-5 * time.Second)) {
+			t.Errorf("expected deadline `%s` but got `%s`", deadline.Add(-5*time.Second), actual)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected shutdown to be called")
+	}
+}
+
+func TestListenAndShutdownSourceLogs(t *testing.T) {
+	hook := make(errorHook, 1)
+	log := logrus.New()
+	log.Out = ioutil.Discard
+	log.AddHook(hook)
+
+	recorder := &shutdownRecorder{deadline: make(chan time.Time, 1), err: context.DeadlineExceeded}
+	spot.ListenAndShutdown(fakeSource{notice: spot.Notice{Kind: spot.NoticeECS, Deadline: time.Now().Add(time.Minute)}}, recorder, time.Second, log)
+
+	select {
+	case msg := <-hook:
+		if msg != "unfinished work released at shutdown deadline" {
+			t.Errorf("expected unfinished work to be logged but got `%s`", msg)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected unfinished work to be logged")
+	}
+}
+
+func TestListenAndCancelSource(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	spot.ListenAndCancel(fakeSource{notice: spot.Notice{Kind: spot.NoticeSignal}}, cancel)
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("expected context to be cancelled")
+	}
+}
+
+func TestSourceGivesUp(t *testing.T) {
+	src := failingSource{err: spot.ErrPollFailing}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	spot.ListenAndCancel(src, cancel)
+
+	recorder := &shutdownRecorder{deadline: make(chan time.Time)}
+	spot.ListenAndShutdown(src, recorder, time.Second, nil)
+
+	select {
+	case <-ctx.Done():
+		t.Error("expected context not to be cancelled without a notice")
+	case <-recorder.deadline:
+		t.Error("expected shutdown not to be called without a notice")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// Nothing to wait for, so don't hang on to a context without a deadline
+	shutdownCtx, shutdownCancel := spot.ShutdownContext(context.Background(), src, time.Second)
+	defer shutdownCancel()
+
+	if shutdownCtx.Err() == nil {
+		t.Error("expected shutdown context to be done without a notice")
+	}
+}
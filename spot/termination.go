@@ -2,8 +2,14 @@ package spot
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"io/ioutil"
+	"math/rand"
 	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/sirupsen/logrus"
@@ -18,9 +24,11 @@ const (
 const (
 	InstanceNotTerminating   = "spot instance not marked for termination"
 	InstanceTerminating      = "spot instance marked for termination"
+	InstanceRebalance        = "spot instance rebalance recommended"
 	ErrTerminationConnection = "failed to query termination endpoint"
 	ErrTerminationStatusCode = "unexpected endpoint response code"
 	ErrTerminationTimestamp  = "unable to parse termination timestamp from endpoint"
+	ErrTokenFallback         = "unable to fetch IMDSv2 session token, falling back to IMDSv1"
 )
 
 // TerminationWatcher listens and checks whether the spot instance is about to be terminated by AWS.
@@ -29,12 +37,122 @@ type TerminationWatcher struct {
 	Client   http.Client
 	Endpoint string
 	Log      *logrus.Logger
-	Ticker   *time.Ticker
+
+	// When set, the endpoints are polled on each tick instead of every Interval.
+	Ticker *time.Ticker
+
+	// How often the endpoints are polled.
+	// Default 5 seconds.
+	Interval time.Duration
+
+	// Random extra wait of up to Jitter between polls.
+	// Zero value disables the setting.
+	Jitter time.Duration
+
+	// How long each poll has to respond.
+	// Default 2 seconds.
+	Timeout time.Duration
+
+	// Run gives up, returning ErrPollFailing, once this many polls have failed in a row.
+	// Zero value never gives up.
+	MaxErrors int
+
+	// Notices that are watched for, the first one seen stops the watcher.
+	// Other endpoints are on the same host as Endpoint.
+	// Default is NoticeTermination.
+	Kinds []NoticeKind
+
+	// IMDSv2 session token endpoint.
+	// Default is /latest/api/token on the same host as Endpoint.
+	TokenEndpoint string
+
+	// How long each session token lasts before a new one is fetched.
+	// Max 6 hours. Default 6 hours.
+	TokenTTL time.Duration
+
+	// Only poll using IMDSv2, for instances that enforce it.
+	// By default requests are sent without a token when one can't be fetched.
+	DisableIMDSv1Fallback bool
+
+	token   imdsToken
+	mu      sync.Mutex
+	stop    context.CancelFunc
+	stopped bool
+	hooks   []NoticeHook
+	err     error
 }
 
+// ErrPollFailing is returned by Run once MaxErrors polls have failed in a row.
+var ErrPollFailing = errors.New("too many failed polls of the metadata endpoint")
+
 // Listen to the EC2 meta endpoint for termination notice.
-// To stop listening call Stop() on TerminationWatcher.Ticker.
+// The channel is only closed on termination, to stop listening call Stop().
 func (w *TerminationWatcher) Listen() chan struct{} {
+	ch := make(chan struct{})
+	notices := w.ListenNotice()
+
+	go func() {
+		if _, ok := <-notices; ok {
+			close(ch)
+		}
+	}()
+
+	return ch
+}
+
+// ListenNotice to the EC2 meta endpoints, receiving the first notice seen.
+// The channel is closed without a notice when the watcher is stopped or gives up, see Err().
+func (w *TerminationWatcher) ListenNotice() chan Notice {
+	ch := make(chan Notice, 1)
+
+	go func() {
+		defer close(ch)
+
+		notice, err := w.Run(context.Background())
+
+		w.mu.Lock()
+		w.err = err
+		w.mu.Unlock()
+
+		// Run has already logged why it gave up
+		if err == nil {
+			ch <- notice
+		}
+	}()
+
+	return ch
+}
+
+// Err is why the channel from ListenNotice() was closed without a notice,
+// context.Canceled when stopped or ErrPollFailing when MaxErrors polls failed in a row.
+func (w *TerminationWatcher) Err() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.err
+}
+
+// OnNotice registers a hook that's run when a notice is seen.
+func (w *TerminationWatcher) OnNotice(hook NoticeHook) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.hooks = append(w.hooks, hook)
+}
+
+// Stop the watcher, Run returns context.Canceled.
+// Stopping before Run is called stops it as soon as it starts, a stopped watcher can't be run again.
+func (w *TerminationWatcher) Stop() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.stopped = true
+	if w.stop != nil {
+		w.stop()
+	}
+}
+
+func (w *TerminationWatcher) defaults() {
 	if w.Endpoint == "" {
 		w.Endpoint = metadataTerminationEndpoint
 	}
@@ -42,74 +160,212 @@ func (w *TerminationWatcher) Listen() chan struct{} {
 		w.Log = logrus.New()
 		w.Log.Out = ioutil.Discard
 	}
-	if w.Ticker == nil {
-		w.Ticker = time.NewTicker(5 * time.Second)
+	if w.Interval <= 0 {
+		w.Interval = 5 * time.Second
+	}
+	if w.Timeout <= 0 {
+		w.Timeout = 2 * time.Second
+	}
+	if len(w.Kinds) == 0 {
+		w.Kinds = []NoticeKind{NoticeTermination}
 	}
+}
 
-	ch := make(chan struct{})
+// Run polls the EC2 meta endpoints until a notice is seen, the context is done or Stop() is called.
+func (w *TerminationWatcher) Run(ctx context.Context) (Notice, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
-	go func() {
+	w.mu.Lock()
+	w.defaults()
+	w.stop = cancel
+	stopped := w.stopped
+	w.mu.Unlock()
+
+	if stopped {
+		return Notice{}, context.Canceled
+	}
+
+	if w.Ticker != nil {
 		defer w.Ticker.Stop()
-		w.pollEndpoint(ch)
-	}()
+	}
 
-	return ch
-}
+	w.Log.WithField("endpoint", w.Endpoint).Debug("listening for spot instance termination")
+	pollStarted()
 
-func (w *TerminationWatcher) pollEndpoint(ch chan struct{}) {
-	logger := w.Log.WithField("endpoint", w.Endpoint)
-	logger.Debug("listening for spot instance termination")
+	failed := 0
+	for {
+		notice, err := w.pollKinds(ctx)
+		if err == nil {
+			w.mu.Lock()
+			hooks := w.hooks
+			w.mu.Unlock()
 
-	// enter straight away, then wait for ticker
-	for ; true; <-w.Ticker.C {
-		resp, err := w.Client.Get(w.Endpoint)
-		if err != nil {
-			logger.WithError(err).Error(ErrTerminationConnection)
-			continue
+			noticed(notice, hooks, w.Log)
+			return notice, nil
+		}
+		if ctx.Err() != nil {
+			return Notice{}, ctx.Err()
 		}
-		defer func() {
-			if httpBodyErr := resp.Body.Close(); httpBodyErr != nil {
-				logger.WithError(httpBodyErr).Error("failed to close HTTP body")
-			}
-		}()
-
-		// Did we get thumbs up response
-		if resp.StatusCode != http.StatusOK {
-			statusCodeLogger := logger.WithField("code", resp.StatusCode)
-			if resp.StatusCode == http.StatusNotFound {
-				statusCodeLogger.Debug(InstanceNotTerminating)
-			} else {
-				statusCodeLogger.Error(ErrTerminationStatusCode)
-			}
 
-			continue
+		if err == errNotNoticed {
+			failed = 0
+		} else if failed++; w.MaxErrors > 0 && failed >= w.MaxErrors {
+			w.Log.WithField("failed", failed).Error(ErrPollFailing.Error())
+			return Notice{}, ErrPollFailing
 		}
 
-		// Get payload
-		body, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			logger.WithError(err).Error("unable to read response from endpoint")
-			continue
+		if err := w.wait(ctx); err != nil {
+			return Notice{}, err
 		}
+	}
+}
 
-		// Endpoint response should include timestamp of when instance is terminating
-		when, err := time.Parse(terminationTimeFormat, string(body))
+// pollKinds polls the endpoint for each kind of notice, returning errNotNoticed when none are set.
+func (w *TerminationWatcher) pollKinds(ctx context.Context) (Notice, error) {
+	var failed error
+	for _, kind := range w.Kinds {
+		notice, err := w.poll(ctx, kind)
+		if err == errNotNoticed {
+			continue
+		}
 		if err != nil {
-			logger.WithError(err).Error(ErrTerminationTimestamp)
+			failed = err
 			continue
 		}
 
-		w.Log.WithField("when", when).Info(InstanceTerminating)
+		logger := w.Log.WithFields(logrus.Fields{
+			"kind":   notice.Kind,
+			"action": notice.Action,
+		})
+		if notice.Kind == NoticeRebalance {
+			logger.Info(InstanceRebalance)
+		} else {
+			logger.WithField("when", notice.Deadline).Info(InstanceTerminating)
+		}
+
+		return notice, nil
+	}
+
+	if failed != nil {
+		return Notice{}, failed
+	}
+
+	return Notice{}, errNotNoticed
+}
+
+// wait for the next poll.
+func (w *TerminationWatcher) wait(ctx context.Context) error {
+	if w.Ticker != nil {
+		select {
+		case <-w.Ticker.C:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	interval := w.Interval
+	if w.Jitter > 0 {
+		interval += time.Duration(rand.Int63n(int64(w.Jitter)))
+	}
+
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// poll the endpoint for the kind of notice, returning errNotNoticed when the notice isn't set.
+// Errors are logged.
+func (w *TerminationWatcher) poll(ctx context.Context, kind NoticeKind) (Notice, error) {
+	endpoint := w.endpoint(kind)
+	logger := w.Log.WithField("endpoint", endpoint)
+
+	ctx, cancel := context.WithTimeout(ctx, w.Timeout)
+	defer cancel()
+
+	pollTotal.Inc()
+
+	resp, err := w.get(ctx, endpoint)
+	if err != nil {
+		logger.WithError(err).Error(ErrTerminationConnection)
+		pollErrorTotal.WithLabelValues(pollErrorConnection).Inc()
+		return Notice{}, err
+	}
+	defer func() {
+		if httpBodyErr := resp.Body.Close(); httpBodyErr != nil {
+			logger.WithError(httpBodyErr).Error("failed to close HTTP body")
+		}
+	}()
+
+	// Did we get thumbs up response
+	if resp.StatusCode != http.StatusOK {
+		statusCodeLogger := logger.WithField("code", resp.StatusCode)
+		if resp.StatusCode == http.StatusNotFound {
+			statusCodeLogger.Debug(InstanceNotTerminating)
+			pollSucceeded()
+			return Notice{}, errNotNoticed
+		}
+
+		statusCodeLogger.Error(ErrTerminationStatusCode)
+		pollErrorTotal.WithLabelValues(strconv.Itoa(resp.StatusCode)).Inc()
+		return Notice{}, fmt.Errorf("endpoint responded with %d", resp.StatusCode)
+	}
+
+	// Get payload
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		logger.WithError(err).Error("unable to read response from endpoint")
+		pollErrorTotal.WithLabelValues(pollErrorConnection).Inc()
+		return Notice{}, err
+	}
 
-		close(ch)
-		return
+	// Endpoint response should include timestamp of when instance is terminating
+	notice, err := parseNotice(kind, body)
+	if err == errNotNoticed {
+		logger.Debug(InstanceNotTerminating)
+		pollSucceeded()
+		return Notice{}, err
+	}
+	if err != nil {
+		logger.WithError(err).Error(ErrTerminationTimestamp)
+		pollErrorTotal.WithLabelValues(pollErrorInvalid).Inc()
+		return Notice{}, err
 	}
+
+	pollSucceeded()
+	noticeTotal.Inc()
+	return notice, nil
+}
+
+// endpoint for the kind of notice.
+func (w *TerminationWatcher) endpoint(kind NoticeKind) string {
+	if kind == NoticeTermination {
+		return w.Endpoint
+	}
+
+	return w.metadataURL(noticePaths[kind])
+}
+
+// metadataURL is the path on the same host as Endpoint.
+func (w *TerminationWatcher) metadataURL(path string) string {
+	u, err := url.Parse(w.Endpoint)
+	if err != nil {
+		return path
+	}
+	u.Path = path
+	u.RawQuery = ""
+
+	return u.String()
 }
 
 // ListenAndCancel will cancel a context when the spot instance is marked for termination.
 func (w *TerminationWatcher) ListenAndCancel(cancel context.CancelFunc) {
-	go func() {
-		<-w.Listen()
-		cancel()
-	}()
+	ListenAndCancel(w, cancel)
 }
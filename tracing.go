@@ -0,0 +1,171 @@
+package goller
+
+import (
+	"context"
+	"path"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// tracerName identifies Goller as the instrumentation library.
+	tracerName = "github.com/rcrowe/goller"
+
+	// AWSTraceHeaderAttribute is the SQS system attribute populated by AWS X-Ray.
+	AWSTraceHeaderAttribute = "AWSTraceHeader"
+)
+
+// tracePropagator reads & writes W3C `traceparent` / `tracestate` message attributes.
+var tracePropagator = propagation.TraceContext{}
+
+// messageCarrier lets OpenTelemetry read and write SQS message attributes.
+type messageCarrier map[string]sqs.MessageAttributeValue
+
+// Get returns the string value of the message attribute.
+func (c messageCarrier) Get(key string) string {
+	if a, ok := c[key]; ok {
+		return aws.StringValue(a.StringValue)
+	}
+
+	return ""
+}
+
+// Set a string message attribute.
+func (c messageCarrier) Set(key, value string) {
+	c[key] = sqs.MessageAttributeValue{
+		DataType:    aws.String("String"),
+		StringValue: aws.String(value),
+	}
+}
+
+// Keys lists all message attributes.
+func (c messageCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+
+	return keys
+}
+
+// extractTraceContext looks for the parent span the message was sent with.
+// W3C message attributes are preferred, falling back to the X-Ray system attribute.
+func extractTraceContext(ctx context.Context, msg sqs.Message) context.Context {
+	carrier := messageCarrier(msg.MessageAttributes)
+	if carrier.Get("traceparent") != "" {
+		return tracePropagator.Extract(ctx, carrier)
+	}
+
+	if header, ok := msg.Attributes[AWSTraceHeaderAttribute]; ok {
+		if sc, ok := parseAWSTraceHeader(header); ok {
+			return trace.ContextWithRemoteSpanContext(ctx, sc)
+		}
+	}
+
+	return ctx
+}
+
+// parseAWSTraceHeader converts an X-Ray trace header into a span context.
+// Header looks like `Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8;Sampled=1`.
+func parseAWSTraceHeader(header string) (trace.SpanContext, bool) {
+	cfg := trace.SpanContextConfig{Remote: true}
+
+	for _, part := range strings.Split(header, ";") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+
+		switch kv[0] {
+		case "Root":
+			root := strings.Split(kv[1], "-")
+			if len(root) != 3 || root[0] != "1" {
+				return trace.SpanContext{}, false
+			}
+
+			traceID, err := trace.TraceIDFromHex(root[1] + root[2])
+			if err != nil {
+				return trace.SpanContext{}, false
+			}
+			cfg.TraceID = traceID
+
+		case "Parent":
+			spanID, err := trace.SpanIDFromHex(kv[1])
+			if err != nil {
+				return trace.SpanContext{}, false
+			}
+			cfg.SpanID = spanID
+
+		case "Sampled":
+			if kv[1] == "1" {
+				cfg.TraceFlags = trace.FlagsSampled
+			}
+		}
+	}
+
+	sc := trace.NewSpanContext(cfg)
+
+	return sc, sc.IsValid()
+}
+
+// queueName pulls the name of the queue off the end of the URL.
+func queueName(queueURL string) string {
+	return path.Base(queueURL)
+}
+
+// messagingAttributes follow the OpenTelemetry messaging semantic conventions.
+func messagingAttributes(queueURL, operation string) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("messaging.system", "aws_sqs"),
+		attribute.String("messaging.operation", operation),
+		attribute.String("messaging.destination.name", queueName(queueURL)),
+		attribute.String("messaging.url", queueURL),
+	}
+}
+
+// startConsumerSpan starts a span around the handler, parented to the span the message was published with.
+func startConsumerSpan(ctx context.Context, tracer trace.Tracer, queueURL string, msg sqs.Message) (context.Context, trace.Span) {
+	attrs := append(
+		messagingAttributes(queueURL, "process"),
+		attribute.String("messaging.message.id", aws.StringValue(msg.MessageId)),
+	)
+
+	return tracer.Start(
+		extractTraceContext(ctx, msg),
+		queueName(queueURL)+" process",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(attrs...),
+	)
+}
+
+// startBatchSpan starts a single span around the batch handler,
+// linked to the span each of the messages was published with.
+func startBatchSpan(ctx context.Context, tracer trace.Tracer, queueURL string, msgs []sqs.Message) (context.Context, trace.Span) {
+	parent := trace.SpanContextFromContext(ctx)
+
+	links := make([]trace.Link, 0, len(msgs))
+	for _, msg := range msgs {
+		sc := trace.SpanContextFromContext(extractTraceContext(ctx, msg))
+		if sc.IsValid() && !sc.Equal(parent) {
+			links = append(links, trace.Link{SpanContext: sc})
+		}
+	}
+
+	attrs := append(
+		messagingAttributes(queueURL, "process"),
+		attribute.Int("messaging.batch.message_count", len(msgs)),
+	)
+
+	return tracer.Start(
+		ctx,
+		queueName(queueURL)+" process",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(attrs...),
+		trace.WithLinks(links...),
+	)
+}
@@ -0,0 +1,207 @@
+package goller_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/sqsiface"
+	"github.com/rcrowe/goller"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type sendSQSClient struct {
+	sqsiface.SQSAPI
+	Input  *sqs.SendMessageInput
+	Output sqs.SendMessageOutput
+}
+
+func (c *sendSQSClient) SendMessageRequest(input *sqs.SendMessageInput) sqs.SendMessageRequest {
+	c.Input = input
+	return sqs.SendMessageRequest{
+		Request: &aws.Request{
+			Data:        &c.Output,
+			HTTPRequest: &http.Request{},
+		},
+	}
+}
+
+func listenTraced(msg sqs.Message, handler goller.HandlerFunc) (*tracetest.SpanRecorder, trace.SpanContext) {
+	svc := &receiveSQSClient{
+		Output: sqs.ReceiveMessageOutput{
+			Messages: []sqs.Message{msg},
+		},
+	}
+
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	cfg := goller.NewDefaultConfig("https://sqs/123/some-queue", 1)
+	cfg.RunOnce()
+
+	var handlerSpan trace.SpanContext
+	w := goller.NewFromConfig(svc, cfg)
+	w.WithTracerProvider(tp)
+	w.Listen(context.Background(), func(ctx context.Context, j goller.Job) error {
+		handlerSpan = trace.SpanContextFromContext(ctx)
+		return handler(ctx, j)
+	})
+
+	return recorder, handlerSpan
+}
+
+func TestHandlerSpanUsesTraceParent(t *testing.T) {
+	msg := sqs.Message{
+		MessageId: aws.String("abc123"),
+		MessageAttributes: map[string]sqs.MessageAttributeValue{
+			"traceparent": {
+				DataType:    aws.String("String"),
+				StringValue: aws.String("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"),
+			},
+		},
+	}
+
+	recorder, handlerSpan := listenTraced(msg, func(ctx context.Context, j goller.Job) error {
+		return nil
+	})
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("expected 1 span but got `%d`", len(spans))
+	}
+
+	span := spans[0]
+	if span.Name() != "some-queue process" {
+		t.Errorf("expected span name `some-queue process` but got `%s`", span.Name())
+	}
+	if span.SpanKind() != trace.SpanKindConsumer {
+		t.Errorf("expected consumer span but got `%s`", span.SpanKind())
+	}
+	if span.Parent().SpanID().String() != "00f067aa0ba902b7" {
+		t.Errorf("expected parent span `00f067aa0ba902b7` but got `%s`", span.Parent().SpanID())
+	}
+	if span.SpanContext().TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("expected trace `4bf92f3577b34da6a3ce929d0e0e4736` but got `%s`", span.SpanContext().TraceID())
+	}
+	if handlerSpan.SpanID() != span.SpanContext().SpanID() {
+		t.Error("expected consumer span to be available from the handler context")
+	}
+
+	attrs := map[string]string{}
+	for _, attr := range span.Attributes() {
+		attrs[string(attr.Key)] = attr.Value.Emit()
+	}
+	if attrs["messaging.system"] != "aws_sqs" {
+		t.Errorf("expected `messaging.system=aws_sqs` but got `%s`", attrs["messaging.system"])
+	}
+	if attrs["messaging.destination.name"] != "some-queue" {
+		t.Errorf("expected `messaging.destination.name=some-queue` but got `%s`", attrs["messaging.destination.name"])
+	}
+	if attrs["messaging.message.id"] != "abc123" {
+		t.Errorf("expected `messaging.message.id=abc123` but got `%s`", attrs["messaging.message.id"])
+	}
+}
+
+func TestHandlerSpanUsesAWSTraceHeader(t *testing.T) {
+	msg := sqs.Message{
+		MessageId: aws.String("abc123"),
+		Attributes: map[string]string{
+			goller.AWSTraceHeaderAttribute: "Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8;Sampled=1",
+		},
+	}
+
+	recorder, _ := listenTraced(msg, func(ctx context.Context, j goller.Job) error {
+		return nil
+	})
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("expected 1 span but got `%d`", len(spans))
+	}
+	if spans[0].SpanContext().TraceID().String() != "5759e988bd862e3fe1be46a994272793" {
+		t.Errorf("expected trace `5759e988bd862e3fe1be46a994272793` but got `%s`", spans[0].SpanContext().TraceID())
+	}
+	if spans[0].Parent().SpanID().String() != "53995c3f42cd8ad8" {
+		t.Errorf("expected parent span `53995c3f42cd8ad8` but got `%s`", spans[0].Parent().SpanID())
+	}
+}
+
+func TestHandlerSpanRecordsError(t *testing.T) {
+	msg := sqs.Message{MessageId: aws.String("abc123")}
+
+	recorder, _ := listenTraced(msg, func(ctx context.Context, j goller.Job) error {
+		return errors.New("handler broke")
+	})
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("expected 1 span but got `%d`", len(spans))
+	}
+	if spans[0].Status().Code != codes.Error {
+		t.Errorf("expected error status but got `%s`", spans[0].Status().Code)
+	}
+	if spans[0].Status().Description != "handler broke" {
+		t.Errorf("expected status `handler broke` but got `%s`", spans[0].Status().Description)
+	}
+}
+
+func TestPublishInjectsTraceContext(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	svc := &sendSQSClient{Output: sqs.SendMessageOutput{MessageId: aws.String("xyz789")}}
+	p := goller.NewPublisher(svc, "https://sqs/123/some-queue")
+	p.WithTracerProvider(tp)
+
+	ctx, parent := tp.Tracer("test").Start(context.Background(), "request")
+	id, err := p.Publish(ctx, goller.Message{Body: "hello"})
+	parent.End()
+
+	if err != nil {
+		t.Fatalf("expected publish to succeed but got `%s`", err)
+	}
+	if id != "xyz789" {
+		t.Errorf("expected message id `xyz789` but got `%s`", id)
+	}
+
+	traceParent := aws.StringValue(svc.Input.MessageAttributes["traceparent"].StringValue)
+	if !strings.Contains(traceParent, parent.SpanContext().TraceID().String()) {
+		t.Errorf("expected traceparent to contain trace `%s` but got `%s`", parent.SpanContext().TraceID(), traceParent)
+	}
+
+	// Consumer continues the trace the publisher started
+	recorded, _ := listenTraced(sqs.Message{
+		MessageId:         aws.String("xyz789"),
+		MessageAttributes: svc.Input.MessageAttributes,
+	}, func(ctx context.Context, j goller.Job) error {
+		return nil
+	})
+
+	spans := recorded.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("expected 1 span but got `%d`", len(spans))
+	}
+	if spans[0].SpanContext().TraceID() != parent.SpanContext().TraceID() {
+		t.Errorf("expected consumer trace `%s` but got `%s`", parent.SpanContext().TraceID(), spans[0].SpanContext().TraceID())
+	}
+
+	var producer sdktrace.ReadOnlySpan
+	for _, span := range recorder.Ended() {
+		if span.SpanKind() == trace.SpanKindProducer {
+			producer = span
+		}
+	}
+	if producer == nil {
+		t.Fatal("expected a producer span to be recorded")
+	}
+	if spans[0].Parent().SpanID() != producer.SpanContext().SpanID() {
+		t.Error("expected consumer span to be parented to the producer span")
+	}
+}
@@ -0,0 +1,170 @@
+package goller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+)
+
+const (
+	// CorrelationIDAttribute is shared by every job in a workflow,
+	// the message ID of the job that started it.
+	CorrelationIDAttribute = "goller-correlation-id"
+
+	// ParentIDAttribute is the message ID of the job that published the follow-up.
+	ParentIDAttribute = "goller-parent-id"
+
+	// StepAttribute counts how many jobs deep into the workflow the follow-up is.
+	StepAttribute = "goller-step"
+)
+
+// ErrWorkflowCycle is returned for jobs past WorkflowConfig.MaxSteps, without calling the handler.
+var ErrWorkflowCycle = errors.New("workflow exceeded maximum steps")
+
+// WorkflowHandlerFunc handles the job, returning follow-up messages to publish once it has succeeded.
+// The job shouldn't be deleted by the handler, that's done once every follow-up has been published.
+type WorkflowHandlerFunc func(ctx context.Context, j Job) ([]FollowUp, error)
+
+// FollowUp is a message published after the current job succeeds.
+type FollowUp struct {
+	// Where the message is sent, usually the queue for the next step.
+	Publisher Publisher
+
+	// The message to send. Workflow attributes are added alongside your own.
+	Message Message
+}
+
+// WorkflowConfig configures the workflow handler.
+type WorkflowConfig struct {
+	// Maximum number of steps in a workflow, stopping jobs that publish each other forever.
+	// Jobs past it fail with ErrWorkflowCycle before the handler is called. Like any failed job they're
+	// received again after the visibility timeout, reaching the dead letter queue once the queue's
+	// redrive policy maxReceiveCount is exceeded. Default 25.
+	MaxSteps int64
+}
+
+// NewWorkflowHandler publishes the follow-ups returned by the handler before deleting the job.
+//
+// If any follow-up fails to publish, the job is backed off & handled again; follow-ups that
+// had already been published are sent a second time. Use WorkflowKey with the idempotency middleware
+// on the next step to skip them.
+//
+// Dry runs log the follow-ups instead of publishing them, see IsDryRun(...).
+func NewWorkflowHandler(cfg WorkflowConfig, handler WorkflowHandlerFunc) HandlerFunc {
+	if cfg.MaxSteps <= 0 {
+		cfg.MaxSteps = 25
+	}
+
+	return func(ctx context.Context, j Job) error {
+		logger := LoggerFromContext(ctx).WithFields(Fields{
+			"correlation_id": CorrelationID(j),
+			"step":           Step(j),
+		})
+		ctx = ContextWithLogger(ctx, logger)
+
+		if step := Step(j); step > cfg.MaxSteps {
+			return fmt.Errorf("%w: step %d of %d", ErrWorkflowCycle, step, cfg.MaxSteps)
+		}
+
+		followUps, err := handler(ctx, j)
+		if err != nil {
+			return err
+		}
+
+		if j.Handled() {
+			if len(followUps) > 0 {
+				logger.Error("job handled by the workflow handler. not publishing follow-ups")
+			}
+			return nil
+		}
+
+		step := Step(j) + 1
+		for i, f := range followUps {
+			msg := f.Message
+			msg.Attributes = workflowAttributes(j, msg.Attributes, step)
+
+			if IsDryRun(ctx) {
+				logger.WithField("follow_up", i).Info("dry-run. follow-up not published")
+				continue
+			}
+
+			id, err := f.Publisher.Publish(ctx, msg)
+			if err != nil {
+				if err := j.Backoff(); err != nil {
+					logger.WithError(err).Error("unable to backoff job")
+				}
+				return fmt.Errorf("unable to publish follow-up %d: %w", i, err)
+			}
+
+			workflowFollowUpTotal.Inc()
+			logger.WithField("follow_up_id", id).Debug("follow-up published")
+		}
+
+		return j.Delete()
+	}
+}
+
+// workflowAttributes copies the attributes, adding those that link the follow-up to the job.
+func workflowAttributes(j Job, attrs map[string]sqs.MessageAttributeValue, step int64) map[string]sqs.MessageAttributeValue {
+	out := make(map[string]sqs.MessageAttributeValue, len(attrs)+3)
+	for k, v := range attrs {
+		out[k] = v
+	}
+
+	out[CorrelationIDAttribute] = sqs.MessageAttributeValue{
+		DataType:    aws.String(AttributeTypeString),
+		StringValue: aws.String(CorrelationID(j)),
+	}
+	out[ParentIDAttribute] = sqs.MessageAttributeValue{
+		DataType:    aws.String(AttributeTypeString),
+		StringValue: aws.String(j.ID()),
+	}
+	out[StepAttribute] = sqs.MessageAttributeValue{
+		DataType:    aws.String(AttributeTypeNumber),
+		StringValue: aws.String(strconv.FormatInt(step, 10)),
+	}
+
+	return out
+}
+
+// CorrelationID of the workflow the job belongs to.
+// Jobs not published as a follow-up start a new workflow, using their own ID.
+func CorrelationID(j Job) string {
+	if id, ok := j.Attribute(CorrelationIDAttribute); ok && id != "" {
+		return id
+	}
+
+	return j.ID()
+}
+
+// ParentID is the message ID of the job that published this one, empty if it started the workflow.
+func ParentID(j Job) string {
+	id, _ := j.Attribute(ParentIDAttribute)
+	return id
+}
+
+// Step in the workflow, 0 for the job that started it.
+func Step(j Job) int64 {
+	step, _ := j.AttributeInt(StepAttribute)
+	return step
+}
+
+// WorkflowKey dedupes follow-ups sent again after their parent was retried.
+// The same parent publishing the same body is treated as a duplicate.
+func WorkflowKey(j Job) string {
+	parent := ParentID(j)
+	if parent == "" {
+		return ""
+	}
+
+	body, _ := j.Body()
+	sum := sha256.Sum256([]byte(parent + "\n" + body))
+
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,222 @@
+package goller_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/rcrowe/goller"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type stubPublisher struct {
+	msgs []goller.Message
+	err  error
+}
+
+func (p *stubPublisher) WithTracerProvider(tp trace.TracerProvider) {}
+
+func (p *stubPublisher) Publish(ctx context.Context, msg goller.Message) (string, error) {
+	if p.err != nil {
+		return "", p.err
+	}
+
+	p.msgs = append(p.msgs, msg)
+	return "follow-up", nil
+}
+
+func newWorkflowJob(id string, attrs map[string]string) (goller.Job, *writeSQSClient) {
+	svc := &writeSQSClient{}
+	msg := sqs.Message{
+		MessageId:         aws.String(id),
+		Body:              aws.String("body"),
+		MessageAttributes: map[string]sqs.MessageAttributeValue{},
+		Attributes: map[string]string{
+			string(sqs.MessageSystemAttributeNameApproximateReceiveCount): "1",
+		},
+	}
+	for k, v := range attrs {
+		msg.MessageAttributes[k] = sqs.MessageAttributeValue{DataType: aws.String("String"), StringValue: aws.String(v)}
+	}
+
+	return goller.NewJob(goller.NewDefaultConfig("foo", 1), goller.NewNopLogger(), msg, svc), svc
+}
+
+func attr(msg goller.Message, name string) string {
+	return aws.StringValue(msg.Attributes[name].StringValue)
+}
+
+func TestWorkflowPublishesFollowUps(t *testing.T) {
+	resize := &stubPublisher{}
+	notify := &stubPublisher{}
+
+	handler := goller.NewWorkflowHandler(goller.WorkflowConfig{}, func(ctx context.Context, j goller.Job) ([]goller.FollowUp, error) {
+		return []goller.FollowUp{
+			{Publisher: resize, Message: goller.Message{
+				Body:       "resize",
+				Attributes: map[string]sqs.MessageAttributeValue{"tenant": {DataType: aws.String("String"), StringValue: aws.String("acme")}},
+			}},
+			{Publisher: notify, Message: goller.Message{Body: "notify"}},
+		}, nil
+	})
+
+	j, svc := newWorkflowJob("abc", nil)
+	if err := handler(context.Background(), j); err != nil {
+		t.Fatalf("expected no error but got `%s`", err)
+	}
+
+	if !svc.Deleted {
+		t.Error("expected job to be deleted")
+	}
+	if len(resize.msgs) != 1 || len(notify.msgs) != 1 {
+		t.Fatalf("expected both follow-ups to be published but got `%d` & `%d`", len(resize.msgs), len(notify.msgs))
+	}
+
+	msg := resize.msgs[0]
+	for name, expected := range map[string]string{
+		goller.CorrelationIDAttribute: "abc",
+		goller.ParentIDAttribute:      "abc",
+		goller.StepAttribute:          "1",
+		"tenant":                      "acme",
+	} {
+		if actual := attr(msg, name); actual != expected {
+			t.Errorf("expected %s `%s` but got `%s`", name, expected, actual)
+		}
+	}
+}
+
+func TestWorkflowDryRun(t *testing.T) {
+	svc := newBatchSQSClient(1)
+	publisher := &stubPublisher{}
+
+	cfg := goller.NewDefaultConfig("foo", 1)
+	cfg.RunOnce()
+	cfg.DryRun(goller.DryRunTimeout)
+
+	goller.NewFromConfig(svc, cfg).Listen(context.Background(), goller.NewWorkflowHandler(goller.WorkflowConfig{}, func(ctx context.Context, j goller.Job) ([]goller.FollowUp, error) {
+		return []goller.FollowUp{{Publisher: publisher, Message: goller.Message{Body: "resize"}}}, nil
+	}))
+
+	if len(publisher.msgs) != 0 {
+		t.Errorf("expected follow-ups not to be published in a dry run but got `%d`", len(publisher.msgs))
+	}
+	if len(svc.deleted) != 0 {
+		t.Errorf("expected nothing deleted but got `%v`", svc.deleted)
+	}
+}
+
+func TestWorkflowCarriesCorrelationID(t *testing.T) {
+	next := &stubPublisher{}
+	handler := goller.NewWorkflowHandler(goller.WorkflowConfig{}, func(ctx context.Context, j goller.Job) ([]goller.FollowUp, error) {
+		return []goller.FollowUp{{Publisher: next, Message: goller.Message{Body: "next"}}}, nil
+	})
+
+	j, _ := newWorkflowJob("def", map[string]string{
+		goller.CorrelationIDAttribute: "abc",
+		goller.ParentIDAttribute:      "xyz",
+		goller.StepAttribute:          "2",
+	})
+	if goller.CorrelationID(j) != "abc" || goller.ParentID(j) != "xyz" || goller.Step(j) != 2 {
+		t.Errorf("expected workflow accessors to read attributes")
+	}
+
+	if err := handler(context.Background(), j); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := next.msgs[0]
+	if attr(msg, goller.CorrelationIDAttribute) != "abc" {
+		t.Errorf("expected correlation id to be carried but got `%s`", attr(msg, goller.CorrelationIDAttribute))
+	}
+	if attr(msg, goller.ParentIDAttribute) != "def" {
+		t.Errorf("expected parent to be the current job but got `%s`", attr(msg, goller.ParentIDAttribute))
+	}
+	if attr(msg, goller.StepAttribute) != "3" {
+		t.Errorf("expected step to be incremented but got `%s`", attr(msg, goller.StepAttribute))
+	}
+}
+
+func TestWorkflowPublishErrorKeepsJob(t *testing.T) {
+	handler := goller.NewWorkflowHandler(goller.WorkflowConfig{}, func(ctx context.Context, j goller.Job) ([]goller.FollowUp, error) {
+		return []goller.FollowUp{{Publisher: &stubPublisher{err: errors.New("sqs down")}}}, nil
+	})
+
+	j, svc := newWorkflowJob("abc", nil)
+	if err := handler(context.Background(), j); err == nil {
+		t.Error("expected publish error")
+	}
+	if svc.Deleted {
+		t.Error("expected job not to be deleted")
+	}
+	if !j.Handled() || svc.Released == nil {
+		t.Fatal("expected job to be backed off")
+	}
+	if timeout := aws.Int64Value(svc.Released.VisibilityTimeout); timeout <= 0 {
+		t.Errorf("expected a backoff visibility timeout but got %d", timeout)
+	}
+}
+
+func TestWorkflowHandlerError(t *testing.T) {
+	next := &stubPublisher{}
+	handler := goller.NewWorkflowHandler(goller.WorkflowConfig{}, func(ctx context.Context, j goller.Job) ([]goller.FollowUp, error) {
+		return []goller.FollowUp{{Publisher: next}}, errors.New("failed")
+	})
+
+	j, svc := newWorkflowJob("abc", nil)
+	if err := handler(context.Background(), j); err == nil {
+		t.Error("expected handler error")
+	}
+	if svc.Deleted || len(next.msgs) != 0 {
+		t.Error("expected failed job not to be deleted or publish follow-ups")
+	}
+}
+
+func TestWorkflowCycle(t *testing.T) {
+	next := &stubPublisher{}
+	called := false
+	handler := goller.NewWorkflowHandler(goller.WorkflowConfig{MaxSteps: 3}, func(ctx context.Context, j goller.Job) ([]goller.FollowUp, error) {
+		called = true
+		return []goller.FollowUp{{Publisher: next}}, nil
+	})
+
+	// Past the last step the handler isn't called, so its side effects don't happen
+	j, svc := newWorkflowJob("abc", map[string]string{goller.StepAttribute: "4"})
+	if err := handler(context.Background(), j); !errors.Is(err, goller.ErrWorkflowCycle) {
+		t.Errorf("expected `%s` but got `%v`", goller.ErrWorkflowCycle, err)
+	}
+	if called {
+		t.Error("expected handler not to be called")
+	}
+	if svc.Deleted || len(next.msgs) != 0 {
+		t.Error("expected job not to be deleted or publish follow-ups")
+	}
+
+	// Last step of the workflow still runs
+	j, svc = newWorkflowJob("abc", map[string]string{goller.StepAttribute: "3"})
+	if err := handler(context.Background(), j); err != nil || !svc.Deleted {
+		t.Errorf("expected final step to be deleted but got `%v`", err)
+	}
+	if !called || len(next.msgs) != 1 {
+		t.Error("expected final step to be handled")
+	}
+}
+
+func TestWorkflowKey(t *testing.T) {
+	root, _ := newWorkflowJob("abc", nil)
+	if key := goller.WorkflowKey(root); key != "" {
+		t.Errorf("expected no key for the first job but got `%s`", key)
+	}
+
+	first, _ := newWorkflowJob("one", map[string]string{goller.ParentIDAttribute: "abc"})
+	again, _ := newWorkflowJob("two", map[string]string{goller.ParentIDAttribute: "abc"})
+	other, _ := newWorkflowJob("three", map[string]string{goller.ParentIDAttribute: "def"})
+
+	if goller.WorkflowKey(first) != goller.WorkflowKey(again) {
+		t.Error("expected follow-ups republished by the same parent to share a key")
+	}
+	if goller.WorkflowKey(first) == goller.WorkflowKey(other) {
+		t.Error("expected follow-ups from different parents to have different keys")
+	}
+}
@@ -0,0 +1,37 @@
+// Package zapadapter lets Goller write to zap.
+package zapadapter
+
+import (
+	"github.com/rcrowe/goller"
+	"go.uber.org/zap"
+)
+
+// New wraps a zap logger.
+func New(logger *zap.Logger) goller.Logger {
+	return &zapLogger{log: logger}
+}
+
+type zapLogger struct {
+	log *zap.Logger
+}
+
+func (l *zapLogger) WithField(key string, value interface{}) goller.Logger {
+	return &zapLogger{log: l.log.With(zap.Any(key, value))}
+}
+
+func (l *zapLogger) WithFields(fields goller.Fields) goller.Logger {
+	zapFields := make([]zap.Field, 0, len(fields))
+	for k, v := range fields {
+		zapFields = append(zapFields, zap.Any(k, v))
+	}
+
+	return &zapLogger{log: l.log.With(zapFields...)}
+}
+
+func (l *zapLogger) WithError(err error) goller.Logger {
+	return &zapLogger{log: l.log.With(zap.Error(err))}
+}
+
+func (l *zapLogger) Debug(msg string) { l.log.Debug(msg) }
+func (l *zapLogger) Info(msg string)  { l.log.Info(msg) }
+func (l *zapLogger) Error(msg string) { l.log.Error(msg) }
@@ -0,0 +1,46 @@
+package zapadapter_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/rcrowe/goller"
+	"github.com/rcrowe/goller/zapadapter"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestLogger(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+
+	zapadapter.New(zap.New(core)).
+		WithField("foo", "bar").
+		WithFields(goller.Fields{"count": 3}).
+		WithError(errors.New("oh no")).
+		Error("something broke")
+
+	entries := logs.All()
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 log entry but got `%d`", len(entries))
+	}
+
+	entry := entries[0]
+	if entry.Level != zapcore.ErrorLevel {
+		t.Errorf("expected error level but got `%s`", entry.Level)
+	}
+	if entry.Message != "something broke" {
+		t.Errorf("expected message `something broke` but got `%s`", entry.Message)
+	}
+
+	fields := entry.ContextMap()
+	if fields["foo"] != "bar" {
+		t.Errorf("expected `foo=bar` but got `%v`", fields["foo"])
+	}
+	if fields["count"] != int64(3) {
+		t.Errorf("expected `count=3` but got `%v`", fields["count"])
+	}
+	if fields["error"] != "oh no" {
+		t.Errorf("expected `error=oh no` but got `%v`", fields["error"])
+	}
+}